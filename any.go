@@ -0,0 +1,212 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds support for google.protobuf.Any, an envelope that carries
+// a serialized message along with a type URL naming its type. The payload
+// is decoded by resolving the type URL to a schema, usually in a Registry.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// AnyName is the name of the Any well-known type
+const AnyName = "google.protobuf.Any"
+
+// anyTypeURLPrefix is prepended to type names that are packed without one
+const anyTypeURLPrefix = "type.googleapis.com/"
+
+// Field numbers of the Any message
+const (
+	anyTypeURLField FieldNum = 1
+	anyValueField   FieldNum = 2
+)
+
+// ErrInvalidAny is returned when an Any message has no type URL
+var ErrInvalidAny = errors.New("Invalid Any message")
+
+// AnyResolver finds the message schema named by the type URL of an Any
+type AnyResolver interface {
+	ResolveAny(typeURL string) (*ProtoFieldMap, bool)
+}
+
+// AnyValue is an expanded Any message, holding the type URL and the
+// decoded fields of the packed message
+type AnyValue struct {
+	TypeURL string
+	Values  []FieldValue
+}
+
+// anyTypeName returns the type name from a type URL, which is everything
+// after the last slash
+func anyTypeName(typeURL string) string {
+	return typeURL[strings.LastIndex(typeURL, "/")+1:]
+}
+
+// ResolveAny looks up the message schema named by the last path component
+// of typeURL, like "mypackage.MyMessage" in
+// "type.googleapis.com/mypackage.MyMessage"
+func (r *Registry) ResolveAny(typeURL string) (*ProtoFieldMap, bool) {
+	return r.GetMessage(anyTypeName(typeURL))
+}
+
+// newAnySchema creates the schema of the Any message
+func newAnySchema() *ProtoFieldMap {
+	fm := NewProtoFieldMap()
+	fm.SetName(AnyName)
+	fm.Add(anyTypeURLField, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.SetFieldName(anyTypeURLField, "type_url")
+	fm.Add(anyValueField, descriptor.FieldDescriptorProto_TYPE_BYTES)
+	fm.SetFieldName(anyValueField, "value")
+	return fm.Freeze()
+}
+
+// isAnySchema indicates if fm describes an Any message, either the
+// built-in schema or one loaded by name
+func isAnySchema(fm *ProtoFieldMap) bool {
+	return normalizeTypeName(fm.Name()) == AnyName
+}
+
+// PackAny wraps m in an Any message of the type typeName. The type URL is
+// "type.googleapis.com/" followed by typeName, unless typeName already
+// holds a URL with a slash.
+func PackAny(typeName string, m *WireMessage) (*WireMessage, error) {
+	if typeName == "" {
+		return nil, fmt.Errorf("%w: empty type name", ErrInvalidAny)
+	}
+	typeURL := typeName
+	if !strings.Contains(typeURL, "/") {
+		typeURL = anyTypeURLPrefix + normalizeTypeName(typeName)
+	}
+	buf, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	any := NewWireMessage()
+	any.EncodeString(anyTypeURLField, typeURL)
+	// Like proto3, an empty value is left out
+	if len(buf) > 0 {
+		any.EncodeBytes(anyValueField, buf)
+	}
+	return any, nil
+}
+
+// UnpackAny decodes the message packed in the Any message m, using the
+// schema resolver finds for its type URL.
+// ErrUnresolvedType is returned if the type is unknown to resolver.
+func UnpackAny(m *WireMessage, resolver AnyResolver) (*DynamicMessage, error) {
+	typeURL, ok := m.DecodeString(anyTypeURLField)
+	if !ok || typeURL == "" {
+		return nil, fmt.Errorf("%w: missing type URL", ErrInvalidAny)
+	}
+	value, _ := m.DecodeBytes(anyValueField)
+	return unpackAny(typeURL, value, resolver, m.mode)
+}
+
+func unpackAny(typeURL string, value []byte, resolver AnyResolver, mode wireMode) (*DynamicMessage, error) {
+	fm, ok := resolver.ResolveAny(typeURL)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolvedType, typeURL)
+	}
+	d := NewDynamicMessage(fm)
+	if err := d.Unmarshal(value); err != nil {
+		return nil, err
+	}
+	d.wire.mode = mode
+	return d, nil
+}
+
+// ExpandAny replaces every decoded Any message in values, which were decoded
+// with fm, by an AnyValue holding the fields of the packed message.
+// Any messages nested in other messages, repeated fields, maps and the packed
+// messages themselves are expanded recursively.
+// ErrUnresolvedType is returned if a type is unknown to resolver.
+func (fm *ProtoFieldMap) ExpandAny(values []FieldValue, resolver AnyResolver) ([]FieldValue, error) {
+	result := make([]FieldValue, len(values))
+	for i, fv := range values {
+		v, err := fm.expandAnyField(fv.Field, fv.Value, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", fv.Field, err)
+		}
+		result[i] = FieldValue{fv.Field, v}
+	}
+	return result, nil
+}
+
+// expandAnyField expands the decoded value of field
+func (fm *ProtoFieldMap) expandAnyField(field FieldNum, value interface{}, resolver AnyResolver) (interface{}, error) {
+	if entry, isMap := fm.maps[field]; isMap {
+		entries, ok := value.(map[interface{}]interface{})
+		if !ok || entry.valueMsg == nil {
+			return value, nil
+		}
+		expanded := make(map[interface{}]interface{}, len(entries))
+		for k, v := range entries {
+			var err error
+			if expanded[k], err = expandAny(entry.valueMsg, v, resolver); err != nil {
+				return nil, err
+			}
+		}
+		return expanded, nil
+	}
+
+	sub, ok := fm.field2msg[field]
+	if !ok {
+		return value, nil
+	}
+	if vals, ok := value.([]interface{}); ok {
+		expanded := make([]interface{}, len(vals))
+		for i, v := range vals {
+			var err error
+			if expanded[i], err = expandAny(sub, v, resolver); err != nil {
+				return nil, err
+			}
+		}
+		return expanded, nil
+	}
+	return expandAny(sub, value, resolver)
+}
+
+// expandAny expands a single decoded message of the schema sub
+func expandAny(sub *ProtoFieldMap, value interface{}, resolver AnyResolver) (interface{}, error) {
+	vals, ok := value.([]FieldValue)
+	if !ok {
+		// Already converted, like a well-known type
+		return value, nil
+	}
+	if !isAnySchema(sub) {
+		return sub.ExpandAny(vals, resolver)
+	}
+
+	var typeURL string
+	var buf []byte
+	for _, fv := range vals {
+		switch fv.Field {
+		case anyTypeURLField:
+			typeURL, _ = fv.Value.(string)
+		case anyValueField:
+			buf, _ = fv.Value.([]byte)
+		}
+	}
+	if typeURL == "" {
+		return nil, fmt.Errorf("%w: missing type URL", ErrInvalidAny)
+	}
+	d, err := unpackAny(typeURL, buf, resolver, wireMode{})
+	if err != nil {
+		return nil, err
+	}
+	inner, err := d.Fields()
+	if err != nil {
+		return nil, err
+	}
+	if inner, err = d.fm.ExpandAny(inner, resolver); err != nil {
+		return nil, err
+	}
+	return AnyValue{TypeURL: typeURL, Values: inner}, nil
+}
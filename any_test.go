@@ -0,0 +1,138 @@
+package dproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// newAnyTestRegistry creates a Registry with a message test.Point and a
+// message test.Envelope, whose field 2 is itself an Any
+func newAnyTestRegistry(t *testing.T) *Registry {
+	point := NewProtoFieldMap()
+	point.SetName("test.Point")
+	point.Add(1, descriptor.FieldDescriptorProto_TYPE_SINT32)
+	point.Add(2, descriptor.FieldDescriptorProto_TYPE_STRING)
+
+	envelope := NewProtoFieldMap()
+	envelope.SetName("test.Envelope")
+	envelope.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+	envelope.AddWellKnown(2, AnyName)
+
+	r := NewRegistry()
+	if !r.AddMessage(point) || !r.AddMessage(envelope) {
+		t.Fatal("Failed to add messages")
+	}
+	return r
+}
+
+func TestPackUnpackAny(t *testing.T) {
+	r := newAnyTestRegistry(t)
+	m := NewWireMessage()
+	m.EncodeSint32(1, -3)
+	m.EncodeString(2, "p")
+
+	any, err := PackAny("test.Point", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := any.DecodeString(1); url != "type.googleapis.com/test.Point" {
+		t.Errorf("Unexpected type URL %q", url)
+	}
+
+	d, err := UnpackAny(any, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Schema().Name() != "test.Point" {
+		t.Errorf("Resolved the wrong schema %q", d.Schema().Name())
+	}
+	values, err := d.Fields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FieldValue{{1, int32(-3)}, {2, "p"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	// A full type URL is kept as is
+	any, err = PackAny("example.com/types/test.Point", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnpackAny(any, r); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnpackAnyErrors(t *testing.T) {
+	r := newAnyTestRegistry(t)
+	if _, err := PackAny("", NewWireMessage()); !errors.Is(err, ErrInvalidAny) {
+		t.Errorf("Expected ErrInvalidAny for an empty type name, got %v", err)
+	}
+	if _, err := UnpackAny(NewWireMessage(), r); !errors.Is(err, ErrInvalidAny) {
+		t.Errorf("Expected ErrInvalidAny for a missing type URL, got %v", err)
+	}
+	any, err := PackAny("test.Missing", NewWireMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnpackAny(any, r); !errors.Is(err, ErrUnresolvedType) {
+		t.Errorf("Expected ErrUnresolvedType, got %v", err)
+	}
+}
+
+func TestExpandAnyNested(t *testing.T) {
+	r := newAnyTestRegistry(t)
+	point := NewWireMessage()
+	point.EncodeSint32(1, 7)
+	inner, err := PackAny("test.Point", point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := NewWireMessage()
+	envelope.EncodeString(1, "inner")
+	if err := envelope.EncodeMessage(2, inner); err != nil {
+		t.Fatal(err)
+	}
+	outer, err := PackAny("test.Envelope", envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A message with a repeated Any field
+	fm := NewProtoFieldMap()
+	fm.AddWellKnown(1, AnyName)
+	fm.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	m := NewWireMessage()
+	if err := m.AppendAs(1, outer, descriptor.FieldDescriptorProto_TYPE_MESSAGE); err != nil {
+		t.Fatal(err)
+	}
+	values, err := fm.DecodeMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err = fm.ExpandAny(values, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []FieldValue{{1, []interface{}{
+		AnyValue{"type.googleapis.com/test.Envelope", []FieldValue{
+			{1, "inner"},
+			{2, AnyValue{"type.googleapis.com/test.Point", []FieldValue{{1, int32(7)}}}},
+		}},
+	}}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	// Unknown types fail the expansion
+	values, _ = fm.DecodeMessage(m)
+	if _, err := fm.ExpandAny(values, NewRegistry()); !errors.Is(err, ErrUnresolvedType) {
+		t.Errorf("Expected ErrUnresolvedType, got %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the lenient encoding mode, which converts any Go number or
+// numeric string into the exact Go type EncodeAs expects. This is handy for
+// values decoded from JSON or configuration files, where every number is a
+// float64 or string.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ErrOverflow is returned when coercing a value that does not fit in the
+// range of the target Protobuf type
+var ErrOverflow = errors.New("Value out of range")
+
+// ErrPrecisionLoss is returned when coercing a value that can not be
+// represented exactly by the target Protobuf type
+var ErrPrecisionLoss = errors.New("Value loses precision")
+
+// EncodeAsLenient is like EncodeAs, but first converts value with CoerceAs
+func (m *WireMessage) EncodeAsLenient(field FieldNum, value interface{}, pbtype descriptor.FieldDescriptorProto_Type) error {
+	v, err := CoerceAs(value, pbtype)
+	if err != nil {
+		return err
+	}
+	return m.EncodeAs(field, v, pbtype)
+}
+
+// AppendAsLenient is like AppendAs, but first converts value with CoerceAs
+func (m *WireMessage) AppendAsLenient(field FieldNum, value interface{}, pbtype descriptor.FieldDescriptorProto_Type) error {
+	v, err := CoerceAs(value, pbtype)
+	if err != nil {
+		return err
+	}
+	return m.AppendAs(field, v, pbtype)
+}
+
+// CoerceAs converts value into the Go type that EncodeAs expects for the
+// numeric Protobuf type pbtype. Any Go integer, float or decimal numeric
+// string, like "42" or "1e3", is accepted; "010" is ten, and prefixes like
+// 0x are rejected. Bool fields also accept the strings "true" and "false".
+// Values for other types are returned unchanged.
+//
+// ErrOverflow is returned if the value is outside the range of pbtype, and
+// ErrPrecisionLoss if a fraction would be dropped or an integer is too
+// large to be represented exactly by a float or double. Rounding a double
+// to a float is not considered a loss of precision.
+func CoerceAs(value interface{}, pbtype descriptor.FieldDescriptorProto_Type) (interface{}, error) {
+	switch pbtype {
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		n, err := coerceSigned(value, 32, pbtype)
+		return int32(n), err
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return coerceSigned(value, 64, pbtype)
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		n, err := coerceUnsigned(value, 32, pbtype)
+		return uint32(n), err
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return coerceUnsigned(value, 64, pbtype)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// Enums are int32 values, which EncodeEnum takes sign extended
+		n, err := coerceSigned(value, 32, pbtype)
+		return uint64(n), err
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		f, err := coerceFloat(value, 24, pbtype)
+		if err == nil && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			err = overflowError(value, pbtype)
+		}
+		return float32(f), err
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return coerceFloat(value, 53, pbtype)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if s, ok := value.(string); ok {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return false, fmt.Errorf("%w: %q is not a bool", ErrInvalidProtoBufType, s)
+			}
+			return b, nil
+		}
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Bool {
+			return rv.Bool(), nil
+		}
+	}
+	return value, nil
+}
+
+// number holds a Go number as one of a signed, unsigned or float value
+type number struct {
+	kind reflect.Kind // reflect.Int64, reflect.Uint64 or reflect.Float64
+	i    int64
+	u    uint64
+	f    float64
+}
+
+// toNumber classifies value, parsing it if it is a string
+func toNumber(value interface{}, pbtype descriptor.FieldDescriptorProto_Type) (number, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: reflect.Int64, i: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return number{kind: reflect.Uint64, u: rv.Uint()}, nil
+	case reflect.Float32, reflect.Float64:
+		return number{kind: reflect.Float64, f: rv.Float()}, nil
+	case reflect.String:
+		s := rv.String()
+		// Strings are decimal, so IDs with leading zeros are not read as
+		// octal. Prefixes like 0x, hexadecimal floats and Go's digit
+		// separators are rejected.
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return number{kind: reflect.Int64, i: i}, nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return number{kind: reflect.Uint64, u: u}, nil
+		}
+		if strings.ContainsAny(s, "xX_") {
+			return number{}, fmt.Errorf("%w: %q is not a decimal number", ErrInvalidProtoBufType, s)
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return number{kind: reflect.Float64, f: f}, nil
+		} else if errors.Is(err, strconv.ErrRange) {
+			return number{}, fmt.Errorf("%w: %q does not fit in a %s", ErrOverflow, s, typeString(pbtype))
+		}
+		return number{}, fmt.Errorf("%w: %q is not a number", ErrInvalidProtoBufType, s)
+	}
+	return number{}, fmt.Errorf("%w: can not use %T as a %s", ErrInvalidProtoBufType, value, typeString(pbtype))
+}
+
+// wholeFloat checks that f is a whole number within [min, max)
+func wholeFloat(value interface{}, f, min, max float64, pbtype descriptor.FieldDescriptorProto_Type) error {
+	if math.IsNaN(f) || f != math.Trunc(f) {
+		return fmt.Errorf("%w: %v is not a whole number", ErrPrecisionLoss, value)
+	}
+	if f < min || f >= max {
+		return overflowError(value, pbtype)
+	}
+	return nil
+}
+
+// overflowError reports that value does not fit in pbtype
+func overflowError(value interface{}, pbtype descriptor.FieldDescriptorProto_Type) error {
+	return fmt.Errorf("%w: %v does not fit in a %s", ErrOverflow, value, typeString(pbtype))
+}
+
+// coerceSigned converts value into a signed integer of the given bit size
+func coerceSigned(value interface{}, bits uint, pbtype descriptor.FieldDescriptorProto_Type) (int64, error) {
+	n, err := toNumber(value, pbtype)
+	if err != nil {
+		return 0, err
+	}
+	min, max := int64(-1)<<(bits-1), int64(uint64(1)<<(bits-1)-1)
+
+	switch n.kind {
+	case reflect.Int64:
+		if n.i < min || n.i > max {
+			return 0, overflowError(value, pbtype)
+		}
+		return n.i, nil
+	case reflect.Uint64:
+		if n.u > uint64(max) {
+			return 0, overflowError(value, pbtype)
+		}
+		return int64(n.u), nil
+	}
+	if err := wholeFloat(value, n.f, float64(min), -float64(min), pbtype); err != nil {
+		return 0, err
+	}
+	return int64(n.f), nil
+}
+
+// coerceUnsigned converts value into an unsigned integer of the given
+// bit size
+func coerceUnsigned(value interface{}, bits uint, pbtype descriptor.FieldDescriptorProto_Type) (uint64, error) {
+	n, err := toNumber(value, pbtype)
+	if err != nil {
+		return 0, err
+	}
+	max := uint64(math.MaxUint64) >> (64 - bits)
+
+	switch n.kind {
+	case reflect.Int64:
+		if n.i < 0 || uint64(n.i) > max {
+			return 0, overflowError(value, pbtype)
+		}
+		return uint64(n.i), nil
+	case reflect.Uint64:
+		if n.u > max {
+			return 0, overflowError(value, pbtype)
+		}
+		return n.u, nil
+	}
+	if err := wholeFloat(value, n.f, 0, math.Ldexp(1, int(bits)), pbtype); err != nil {
+		return 0, err
+	}
+	return uint64(n.f), nil
+}
+
+// coerceFloat converts value into a float64. Integers must be exactly
+// representable with a mantissa of the given number of bits.
+func coerceFloat(value interface{}, mantissa uint, pbtype descriptor.FieldDescriptorProto_Type) (float64, error) {
+	n, err := toNumber(value, pbtype)
+	if err != nil {
+		return 0, err
+	}
+	switch n.kind {
+	case reflect.Int64:
+		abs := uint64(n.i)
+		if n.i < 0 {
+			abs = -abs
+		}
+		if !exactInMantissa(abs, mantissa) {
+			return 0, fmt.Errorf("%w: %v can not be represented exactly as a %s", ErrPrecisionLoss, value, typeString(pbtype))
+		}
+		return float64(n.i), nil
+	case reflect.Uint64:
+		if !exactInMantissa(n.u, mantissa) {
+			return 0, fmt.Errorf("%w: %v can not be represented exactly as a %s", ErrPrecisionLoss, value, typeString(pbtype))
+		}
+		return float64(n.u), nil
+	}
+	return n.f, nil
+}
+
+// exactInMantissa indicates if u is representable by a binary float with a
+// mantissa of the given number of bits
+func exactInMantissa(u uint64, mantissa uint) bool {
+	for u >= uint64(1)<<mantissa {
+		if u&1 != 0 {
+			return false
+		}
+		u >>= 1
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package dproto
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestCoerceAs(t *testing.T) {
+	type myInt int16
+	tests := []struct {
+		value    interface{}
+		pbtype   descriptor.FieldDescriptorProto_Type
+		expected interface{}
+		err      error
+	}{
+		{int(5), descriptor.FieldDescriptorProto_TYPE_INT32, int32(5), nil},
+		{int64(-5), descriptor.FieldDescriptorProto_TYPE_SINT32, int32(-5), nil},
+		{float64(12), descriptor.FieldDescriptorProto_TYPE_INT64, int64(12), nil},
+		{"42", descriptor.FieldDescriptorProto_TYPE_UINT32, uint32(42), nil},
+		{"010", descriptor.FieldDescriptorProto_TYPE_INT32, int32(10), nil},
+		{"-007", descriptor.FieldDescriptorProto_TYPE_SINT64, int64(-7), nil},
+		{"18446744073709551615", descriptor.FieldDescriptorProto_TYPE_FIXED64, uint64(math.MaxUint64), nil},
+		{"1e3", descriptor.FieldDescriptorProto_TYPE_SFIXED32, int32(1000), nil},
+		{myInt(-2), descriptor.FieldDescriptorProto_TYPE_ENUM, uint64(math.MaxUint64 - 1), nil},
+		{uint64(math.MaxUint64), descriptor.FieldDescriptorProto_TYPE_UINT64, uint64(math.MaxUint64), nil},
+		{int(3), descriptor.FieldDescriptorProto_TYPE_FLOAT, float32(3), nil},
+		{"0.5", descriptor.FieldDescriptorProto_TYPE_DOUBLE, float64(0.5), nil},
+		{float64(0.1), descriptor.FieldDescriptorProto_TYPE_FLOAT, float32(0.1), nil},
+		{"true", descriptor.FieldDescriptorProto_TYPE_BOOL, true, nil},
+		{"text", descriptor.FieldDescriptorProto_TYPE_STRING, "text", nil},
+
+		{int64(math.MaxInt32 + 1), descriptor.FieldDescriptorProto_TYPE_INT32, nil, ErrOverflow},
+		{int(-1), descriptor.FieldDescriptorProto_TYPE_UINT64, nil, ErrOverflow},
+		{uint64(math.MaxInt64 + 1), descriptor.FieldDescriptorProto_TYPE_INT64, nil, ErrOverflow},
+		{float64(1 << 32), descriptor.FieldDescriptorProto_TYPE_FIXED32, nil, ErrOverflow},
+		{float64(math.MaxFloat64), descriptor.FieldDescriptorProto_TYPE_FLOAT, nil, ErrOverflow},
+		{"1e400", descriptor.FieldDescriptorProto_TYPE_DOUBLE, nil, ErrOverflow},
+		{float64(1.5), descriptor.FieldDescriptorProto_TYPE_INT32, nil, ErrPrecisionLoss},
+		{math.NaN(), descriptor.FieldDescriptorProto_TYPE_INT64, nil, ErrPrecisionLoss},
+		{int64(1<<53 + 1), descriptor.FieldDescriptorProto_TYPE_DOUBLE, nil, ErrPrecisionLoss},
+		{int32(1<<24 + 1), descriptor.FieldDescriptorProto_TYPE_FLOAT, nil, ErrPrecisionLoss},
+		{"twelve", descriptor.FieldDescriptorProto_TYPE_INT32, nil, ErrInvalidProtoBufType},
+		{"1_000", descriptor.FieldDescriptorProto_TYPE_INT32, nil, ErrInvalidProtoBufType},
+		{"0x1_0", descriptor.FieldDescriptorProto_TYPE_UINT64, nil, ErrInvalidProtoBufType},
+		{"0x10", descriptor.FieldDescriptorProto_TYPE_FIXED64, nil, ErrInvalidProtoBufType},
+		{"0b11", descriptor.FieldDescriptorProto_TYPE_INT32, nil, ErrInvalidProtoBufType},
+		{"0o17", descriptor.FieldDescriptorProto_TYPE_INT32, nil, ErrInvalidProtoBufType},
+		{"0x1p4", descriptor.FieldDescriptorProto_TYPE_DOUBLE, nil, ErrInvalidProtoBufType},
+		{"1_0.5", descriptor.FieldDescriptorProto_TYPE_DOUBLE, nil, ErrInvalidProtoBufType},
+		{true, descriptor.FieldDescriptorProto_TYPE_INT32, nil, ErrInvalidProtoBufType},
+	}
+
+	for i, test := range tests {
+		v, err := CoerceAs(test.value, test.pbtype)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("Test %d: expected %v, got %v (%v)", i, test.err, err, v)
+			}
+			continue
+		}
+		if err != nil || v != test.expected {
+			t.Errorf("Test %d: expected %#v, got %#v (%v)", i, test.expected, v, err)
+		}
+	}
+}
+
+func TestEncodeAsLenient(t *testing.T) {
+	m := NewWireMessage()
+	if err := m.EncodeAs(1, int(7), descriptor.FieldDescriptorProto_TYPE_INT32); err != ErrInvalidProtoBufType {
+		t.Errorf("Strict EncodeAs accepted an int: %v", err)
+	}
+	if err := m.EncodeAsLenient(1, float64(7), descriptor.FieldDescriptorProto_TYPE_INT32); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AppendAsLenient(2, "-1", descriptor.FieldDescriptorProto_TYPE_SINT64); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EncodeAsLenient(3, 300, descriptor.FieldDescriptorProto_TYPE_UINT32); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EncodeAsLenient(4, 1.25, descriptor.FieldDescriptorProto_TYPE_UINT32); !errors.Is(err, ErrPrecisionLoss) {
+		t.Errorf("Expected ErrPrecisionLoss, got %v", err)
+	}
+
+	if v, _ := m.DecodeInt32(1); v != 7 {
+		t.Errorf("Expected 7, got %d", v)
+	}
+	if v, _ := m.DecodeSint64(2); v != -1 {
+		t.Errorf("Expected -1, got %d", v)
+	}
+	if _, ok := m.GetField(4); ok {
+		t.Error("Failed coercion still encoded the field")
+	}
+}
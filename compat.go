@@ -0,0 +1,290 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file checks whether a changed ProtoFieldMap can still read data
+// written with the old one, and the other way around.
+
+package dproto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Severity ranks how badly an Incompatibility affects existing data
+type Severity int
+
+const (
+	// SeverityInfo marks changes that are safe, but worth knowing about
+	SeverityInfo Severity = iota
+	// SeverityWarning marks changes that are wire compatible, but may
+	// truncate values, change their meaning or break JSON
+	SeverityWarning
+	// SeverityError marks changes that break reading existing data
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Incompatibility describes a single schema change found by
+// CheckCompatibility
+type Incompatibility struct {
+	// Path is the dotted path of field names leading to the nested message
+	// that holds Field. It is empty for fields of the top level message.
+	Path     string
+	Field    FieldNum
+	Severity Severity
+	Message  string
+}
+
+func (i Incompatibility) String() string {
+	field := fmt.Sprint(i.Field)
+	if i.Path != "" {
+		field = i.Path + "." + field
+	}
+	return fmt.Sprintf("%s: field %s: %s", i.Severity, field, i.Message)
+}
+
+// CheckCompatibility compares the schema old with its replacement new and
+// reports every change that affects existing data. Nested message schemas
+// and map values are compared too.
+//
+// Checked are wire type changes (int32 to fixed32), encoding changes
+// (int32 to sint32), field numbers reused for a different field, fields
+// moved to a new number, label changes, added or removed required fields
+// and removed or renamed enum values.
+func CheckCompatibility(old, new *ProtoFieldMap) []Incompatibility {
+	c := &compatChecker{visited: make(map[[2]*ProtoFieldMap]bool)}
+	c.message("", old, new)
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		if c.issues[i].Path != c.issues[j].Path {
+			return c.issues[i].Path < c.issues[j].Path
+		}
+		return c.issues[i].Field < c.issues[j].Field
+	})
+	return c.issues
+}
+
+// compatChecker collects the Incompatibilities found while walking two
+// schemas side by side
+type compatChecker struct {
+	issues  []Incompatibility
+	visited map[[2]*ProtoFieldMap]bool
+}
+
+func (c *compatChecker) report(path string, field FieldNum, severity Severity, format string, args ...interface{}) {
+	c.issues = append(c.issues, Incompatibility{
+		Path:     path,
+		Field:    field,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// message compares the fields of two message schemas
+func (c *compatChecker) message(path string, old, new *ProtoFieldMap) {
+	// Recursive schemas only need to be compared once
+	if c.visited[[2]*ProtoFieldMap{old, new}] {
+		return
+	}
+	c.visited[[2]*ProtoFieldMap{old, new}] = true
+
+	required := descriptor.FieldDescriptorProto_LABEL_REQUIRED
+
+	for _, field := range old.GetFieldNums() {
+		oldName, oldNamed := old.GetFieldName(field)
+		if _, ok := new.Get(field); !ok {
+			if old.GetLabel(field) == required {
+				c.report(path, field, SeverityError, "required field%s was removed", spaced(oldName))
+			} else {
+				c.report(path, field, SeverityInfo, "field%s was removed, its number should be reserved", spaced(oldName))
+			}
+			if moved, ok := new.GetFieldByName(oldName); oldNamed && ok {
+				if _, existed := old.Get(moved); !existed {
+					c.report(path, field, SeverityError, "field %s moved to number %d", oldName, moved)
+				}
+			}
+			continue
+		}
+		c.field(path, field, old, new)
+	}
+
+	for _, field := range new.GetFieldNums() {
+		if _, ok := old.Get(field); !ok && new.GetLabel(field) == required {
+			name, _ := new.GetFieldName(field)
+			c.report(path, field, SeverityError, "required field%s was added", spaced(name))
+		}
+	}
+}
+
+// field compares a field that exists in both message schemas
+func (c *compatChecker) field(path string, field FieldNum, old, new *ProtoFieldMap) {
+	oldType, _ := old.Get(field)
+	newType, _ := new.Get(field)
+	oldName, oldNamed := old.GetFieldName(field)
+	newName, newNamed := new.GetFieldName(field)
+	sub := qualify(path, oldName)
+	if !oldNamed {
+		sub = qualify(path, fmt.Sprint(field))
+	}
+
+	typeChanged := false
+	oldEntry, oldIsMap := old.maps[field]
+	newEntry, newIsMap := new.maps[field]
+	switch {
+	case oldIsMap != newIsMap:
+		typeChanged = true
+		c.report(path, field, SeverityError, "changed between a map and a %s field", typeString(oldType))
+	case oldIsMap:
+		if s, ok := compareTypes(oldEntry.key, newEntry.key); ok {
+			typeChanged = typeChanged || s == SeverityError
+			c.report(path, field, s, "map key %s", describeTypeChange(oldEntry.key, newEntry.key, s))
+		}
+		if s, ok := compareTypes(oldEntry.value, newEntry.value); ok {
+			typeChanged = typeChanged || s == SeverityError
+			c.report(path, field, s, "map value %s", describeTypeChange(oldEntry.value, newEntry.value, s))
+		}
+		c.nested(path, sub, field, oldEntry.valueMsg, newEntry.valueMsg, oldEntry.valueEnum, newEntry.valueEnum)
+	default:
+		if s, ok := compareTypes(oldType, newType); ok {
+			typeChanged = s == SeverityError
+			c.report(path, field, s, "%s", describeTypeChange(oldType, newType, s))
+		}
+		oldMsg, _ := old.GetMessage(field)
+		newMsg, _ := new.GetMessage(field)
+		oldEnum, _ := old.GetEnum(field)
+		newEnum, _ := new.GetEnum(field)
+		c.nested(path, sub, field, oldMsg, newMsg, oldEnum, newEnum)
+	}
+
+	if oldNamed && newNamed && oldName != newName {
+		if typeChanged {
+			c.report(path, field, SeverityError, "number reused, field %s was replaced by %s", oldName, newName)
+		} else {
+			c.report(path, field, SeverityWarning, "renamed from %s to %s, which breaks JSON", oldName, newName)
+		}
+	}
+
+	if !oldIsMap && !newIsMap {
+		c.label(path, field, old.GetLabel(field), new.GetLabel(field))
+	}
+}
+
+// nested compares the nested message schemas or enums of a field, when
+// both versions have one. Fields of the nested messages are reported
+// under sub.
+func (c *compatChecker) nested(path, sub string, field FieldNum, oldMsg, newMsg *ProtoFieldMap, oldEnum, newEnum *ProtoEnum) {
+	if oldMsg != nil && newMsg != nil {
+		c.message(sub, oldMsg, newMsg)
+	}
+	if oldEnum != nil && newEnum != nil {
+		for _, name := range oldEnum.Names() {
+			number, _ := oldEnum.GetNumber(name)
+			if newName, ok := newEnum.GetName(number); !ok {
+				c.report(path, field, SeverityError, "enum value %s (%d) was removed", name, number)
+			} else if _, ok := newEnum.GetNumber(name); !ok {
+				c.report(path, field, SeverityWarning, "enum value %d was renamed from %s to %s", number, name, newName)
+			}
+		}
+	}
+}
+
+// label compares the labels of a field
+func (c *compatChecker) label(path string, field FieldNum, old, new descriptor.FieldDescriptorProto_Label) {
+	if old == new {
+		return
+	}
+	severity := SeverityWarning
+	if new == descriptor.FieldDescriptorProto_LABEL_REQUIRED {
+		// Existing data may not hold the field
+		severity = SeverityError
+	}
+	c.report(path, field, severity, "label changed from %s to %s", labelString(old), labelString(new))
+}
+
+// varintEncoding groups the varint types whose values are written the
+// same way
+func varintEncoding(typ descriptor.FieldDescriptorProto_Type) string {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return "zigzag"
+	}
+	return "plain"
+}
+
+// compareTypes ranks changing a field from type old to new.
+// It returns false if there is no change.
+func compareTypes(old, new descriptor.FieldDescriptorProto_Type) (Severity, bool) {
+	if old == new {
+		return SeverityInfo, false
+	}
+	oldWire, newWire := protoType2WireType[old], protoType2WireType[new]
+	if oldWire != newWire {
+		return SeverityError, true
+	}
+
+	switch oldWire {
+	case proto.WireVarint:
+		if varintEncoding(old) != varintEncoding(new) {
+			return SeverityError, true
+		}
+	case proto.WireFixed64, proto.WireFixed32:
+		// Floats reinterpreted as integers are garbage
+		isFloat := func(typ descriptor.FieldDescriptorProto_Type) bool {
+			return typ == descriptor.FieldDescriptorProto_TYPE_FLOAT || typ == descriptor.FieldDescriptorProto_TYPE_DOUBLE
+		}
+		if isFloat(old) || isFloat(new) {
+			return SeverityError, true
+		}
+	case proto.WireBytes:
+		// Strings and messages are both valid bytes, but not each other
+		if old != descriptor.FieldDescriptorProto_TYPE_BYTES && new != descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return SeverityError, true
+		}
+	}
+	return SeverityWarning, true
+}
+
+// describeTypeChange explains a type change of the given severity
+func describeTypeChange(old, new descriptor.FieldDescriptorProto_Type, severity Severity) string {
+	change := fmt.Sprintf("type changed from %s to %s", typeString(old), typeString(new))
+	switch {
+	case severity != SeverityError:
+		return change + ", values may be truncated or reinterpreted"
+	case protoType2WireType[old] != protoType2WireType[new]:
+		return change + ", which uses a different wire type"
+	}
+	return change + ", which uses a different encoding"
+}
+
+// spaced prefixes a non empty name with a space, for use in messages
+func spaced(name string) string {
+	if name == "" {
+		return ""
+	}
+	return " " + name
+}
+
+// typeString returns the lower case name of a Protobuf type, like "int32"
+func typeString(typ descriptor.FieldDescriptorProto_Type) string {
+	return strings.ToLower(strings.TrimPrefix(typ.String(), "TYPE_"))
+}
+
+// labelString returns the lower case name of a label, like "optional"
+func labelString(label descriptor.FieldDescriptorProto_Label) string {
+	return strings.ToLower(strings.TrimPrefix(label.String(), "LABEL_"))
+}
@@ -0,0 +1,153 @@
+package dproto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// compatSchema builds the base schema used by the compatibility tests
+func compatSchema() *ProtoFieldMap {
+	status := NewProtoEnum("Status")
+	status.Add("OFF", 0)
+	status.Add("ON", 1)
+	status.Add("BROKEN", 2)
+
+	inner := NewProtoFieldMap()
+	inner.Add(1, descriptor.FieldDescriptorProto_TYPE_INT64)
+	inner.SetFieldName(1, "count")
+
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT32)
+	fm.SetFieldName(1, "id")
+	fm.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REQUIRED)
+	fm.Add(2, descriptor.FieldDescriptorProto_TYPE_INT32)
+	fm.SetFieldName(2, "level")
+	fm.AddEnum(3, status)
+	fm.SetFieldName(3, "status")
+	fm.AddMessage(4, inner)
+	fm.SetFieldName(4, "inner")
+	fm.Add(5, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.SetFieldName(5, "note")
+	return fm
+}
+
+// findIssue returns the first issue for field whose message contains text
+func findIssue(issues []Incompatibility, path string, field FieldNum, text string) (Incompatibility, bool) {
+	for _, i := range issues {
+		if i.Path == path && i.Field == field && strings.Contains(i.Message, text) {
+			return i, true
+		}
+	}
+	return Incompatibility{}, false
+}
+
+func TestCheckCompatibilityIdentical(t *testing.T) {
+	if issues := CheckCompatibility(compatSchema(), compatSchema()); len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
+
+func TestCheckCompatibilityTypes(t *testing.T) {
+	tests := []struct {
+		from, to descriptor.FieldDescriptorProto_Type
+		severity Severity
+		text     string
+	}{
+		{descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_FIXED32, SeverityError, "wire type"},
+		{descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, SeverityError, "encoding"},
+		{descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SINT64, SeverityWarning, "sint64"},
+		{descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_UINT32, SeverityWarning, "truncated"},
+		{descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_FLOAT, SeverityError, "encoding"},
+		{descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64, SeverityWarning, "sfixed64"},
+		{descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES, SeverityWarning, "bytes"},
+		{descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_MESSAGE, SeverityError, "message"},
+	}
+	for _, test := range tests {
+		old, new := NewProtoFieldMap(), NewProtoFieldMap()
+		old.Add(1, test.from)
+		new.Add(1, test.to)
+		issues := CheckCompatibility(old, new)
+		if len(issues) != 1 {
+			t.Errorf("%v -> %v: expected one issue, got %v", test.from, test.to, issues)
+			continue
+		}
+		if issues[0].Severity != test.severity || !strings.Contains(issues[0].Message, test.text) {
+			t.Errorf("%v -> %v: unexpected issue %v", test.from, test.to, issues[0])
+		}
+	}
+}
+
+func TestCheckCompatibilityChanges(t *testing.T) {
+	new := compatSchema()
+	// Required field 1 is removed
+	new.RemoveByField(1)
+	// Field 2 is reused for something else
+	new.Add(2, descriptor.FieldDescriptorProto_TYPE_DOUBLE)
+	new.SetFieldName(2, "ratio")
+	// BROKEN is removed and ON is renamed
+	status := NewProtoEnum("Status")
+	status.Add("OFF", 0)
+	status.Add("ENABLED", 1)
+	new.AddEnum(3, status)
+	new.SetFieldName(3, "status")
+	// The nested count changes encoding
+	inner := NewProtoFieldMap()
+	inner.Add(1, descriptor.FieldDescriptorProto_TYPE_SINT64)
+	inner.SetFieldName(1, "count")
+	new.AddMessage(4, inner)
+	new.SetFieldName(4, "inner")
+	// note moves from 5 to 6 and becomes repeated
+	new.RemoveByField(5)
+	new.Add(6, descriptor.FieldDescriptorProto_TYPE_STRING)
+	new.SetFieldName(6, "note")
+	// A new required field is added
+	new.Add(7, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	new.SetFieldName(7, "flag")
+	new.SetLabel(7, descriptor.FieldDescriptorProto_LABEL_REQUIRED)
+
+	issues := CheckCompatibility(compatSchema(), new)
+	expected := []struct {
+		path     string
+		field    FieldNum
+		severity Severity
+		text     string
+	}{
+		{"", 1, SeverityError, "required field id was removed"},
+		{"", 2, SeverityError, "number reused"},
+		{"", 3, SeverityError, "BROKEN (2) was removed"},
+		{"", 3, SeverityWarning, "renamed from ON to ENABLED"},
+		{"inner", 1, SeverityError, "different encoding"},
+		{"", 5, SeverityError, "moved to number 6"},
+		{"", 7, SeverityError, "required field flag was added"},
+	}
+	for _, e := range expected {
+		issue, ok := findIssue(issues, e.path, e.field, e.text)
+		if !ok {
+			t.Errorf("Missing issue %q for field %d in %v", e.text, e.field, issues)
+			continue
+		}
+		if issue.Severity != e.severity {
+			t.Errorf("Expected %v for %q, got %v", e.severity, e.text, issue.Severity)
+		}
+	}
+	if issues[0].String() != "error: field 1: required field id was removed" {
+		t.Errorf("Unexpected issue string %q", issues[0].String())
+	}
+}
+
+func TestCheckCompatibilityRecursive(t *testing.T) {
+	build := func(typ descriptor.FieldDescriptorProto_Type) *ProtoFieldMap {
+		node := NewProtoFieldMap()
+		node.AddMessage(1, node)
+		node.SetFieldName(1, "child")
+		node.Add(2, typ)
+		node.SetFieldName(2, "value")
+		return node
+	}
+	issues := CheckCompatibility(build(descriptor.FieldDescriptorProto_TYPE_UINT32), build(descriptor.FieldDescriptorProto_TYPE_FLOAT))
+	if len(issues) != 1 || issues[0].Path != "" || issues[0].Severity != SeverityError {
+		t.Errorf("Unexpected issues %v", issues)
+	}
+}
@@ -0,0 +1,120 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds DecodeResult, which reports everything that happened while
+// decoding a message: every failed field and every field that is not part of
+// the schema. This is meant for monitoring clients that drift from the schema.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// UnknownFieldPolicy selects what decoding does with fields that are not
+// part of the ProtoFieldMap
+type UnknownFieldPolicy int
+
+const (
+	// UnknownFieldsIgnore skips unknown fields, like DecodeMessage
+	UnknownFieldsIgnore UnknownFieldPolicy = iota
+	// UnknownFieldsCollect lists unknown fields in DecodeResult.Unknown
+	UnknownFieldsCollect
+	// UnknownFieldsFail lists unknown fields and fails the decode with
+	// ErrUnknownField
+	UnknownFieldsFail
+)
+
+// UnknownField holds the raw wire values of a field that is not part of the
+// ProtoFieldMap, in the order they occurred
+type UnknownField struct {
+	Field   FieldNum
+	Varint  []WireVarint
+	Fixed32 []WireFixed32
+	Fixed64 []WireFixed64
+	Bytes   [][]byte
+}
+
+// DecodeResult is the outcome of ProtoFieldMap.DecodeMessageResult
+type DecodeResult struct {
+	// Values holds the fields that decoded successfully.
+	// It is nil if the decode failed because of UnknownFieldsFail.
+	Values []FieldValue
+	// Unknown holds the fields not in the ProtoFieldMap, sorted by number.
+	// It is only filled in by UnknownFieldsCollect and UnknownFieldsFail.
+	Unknown []UnknownField
+	// Err joins the errors of all fields that failed to decode, each
+	// prefixed with its field number. It is nil if there were none.
+	Err error
+}
+
+// DecodeMessageResult decodes all fields in m, like DecodeMessage, but
+// reports every field error and handles unknown fields according to policy
+func (fm *ProtoFieldMap) DecodeMessageResult(m *WireMessage, policy UnknownFieldPolicy) DecodeResult {
+	var result DecodeResult
+	var errs []error
+	fm.decodeFields(m, func(field FieldNum, value interface{}, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("field %d: %w", field, err))
+			return
+		}
+		result.Values = append(result.Values, FieldValue{field, value})
+	}, func(field FieldNum) {
+		if policy == UnknownFieldsIgnore {
+			return
+		}
+		result.Unknown = append(result.Unknown, UnknownField{
+			Field:   field,
+			Varint:  m.GetRepeatedVarint(field),
+			Fixed32: m.GetRepeatedFixed32(field),
+			Fixed64: m.GetRepeatedFixed64(field),
+			Bytes:   m.GetRepeatedBytes(field),
+		})
+		if policy == UnknownFieldsFail {
+			errs = append(errs, fmt.Errorf("%w: field %d of message %s", ErrUnknownField, field, fm.name))
+		}
+	})
+
+	if policy == UnknownFieldsFail && len(result.Unknown) > 0 {
+		result.Values = nil
+	}
+	result.Err = errors.Join(errs...)
+	return result
+}
+
+// DecodeBufferResult unmarshals buf and decodes it with DecodeMessageResult.
+// An error unmarshalling buf is returned as the Err of the result.
+func (fm *ProtoFieldMap) DecodeBufferResult(buf []byte, policy UnknownFieldPolicy) DecodeResult {
+	m, err := Unmarshal(buf)
+	if err != nil {
+		return DecodeResult{Err: err}
+	}
+	return fm.DecodeMessageResult(m, policy)
+}
+
+// decodeFields decodes every field of m in field order, passing the
+// outcome of known fields to decoded and the number of unknown ones
+// to unknown
+func (fm *ProtoFieldMap) decodeFields(m *WireMessage, decoded func(FieldNum, interface{}, error), unknown func(FieldNum)) {
+	// A field may be listed once per wire type it holds
+	fields := m.GetFieldNums()
+	sort.Slice(fields, func(i, j int) bool { return fields[i] < fields[j] })
+
+	for i, f := range fields {
+		if i > 0 && fields[i-1] == f {
+			continue
+		}
+		if _, ok := fm.field2type[f]; !ok {
+			unknown(f)
+			continue
+		}
+		// Members of a oneof that were overridden are dropped
+		if !fm.oneofWinner(m, f) {
+			continue
+		}
+		v, err := fm.decodeField(m, f)
+		decoded(f, v, err)
+	}
+}
@@ -0,0 +1,96 @@
+package dproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func newDecodeResultTestBuffer(t *testing.T) []byte {
+	m := NewWireMessage()
+	m.EncodeInt32(1, 10)
+	m.EncodeString(2, "ok")
+	m.AddBytes(3, []byte{0x0A, 0x05}) // not a valid embedded message
+	m.AddBytes(4, []byte{0x08})       // unknown
+	m.AppendVarint(5, 1)              // unknown, repeated
+	m.AppendVarint(5, 2)
+	buf, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func newDecodeResultTestMap() *ProtoFieldMap {
+	fm := NewProtoFieldMap()
+	fm.SetName("test.Result")
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT32)
+	fm.Add(2, descriptor.FieldDescriptorProto_TYPE_MESSAGE) // holds a string
+	fm.Add(3, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	return fm
+}
+
+func TestDecodeResultErrors(t *testing.T) {
+	fm := newDecodeResultTestMap()
+	result := fm.DecodeBufferResult(newDecodeResultTestBuffer(t), UnknownFieldsIgnore)
+
+	if !reflect.DeepEqual(result.Values, []FieldValue{{1, int32(10)}}) {
+		t.Errorf("Unexpected values %v", result.Values)
+	}
+	if result.Unknown != nil {
+		t.Errorf("Ignore policy collected unknown fields %v", result.Unknown)
+	}
+	if !errors.Is(result.Err, ErrMalformedProtoBuf) {
+		t.Errorf("Expected ErrMalformedProtoBuf, got %v", result.Err)
+	}
+	joined, ok := result.Err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("Expected 2 joined errors, got %v", result.Err)
+	}
+
+	// DecodeMessage still reports only the first error
+	values, err := fm.DecodeBuffer(newDecodeResultTestBuffer(t))
+	if len(values) != 1 || err == nil {
+		t.Errorf("Unexpected DecodeBuffer result %v (%v)", values, err)
+	}
+}
+
+func TestDecodeResultUnknownFields(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT32)
+
+	result := fm.DecodeBufferResult(newDecodeResultTestBuffer(t), UnknownFieldsCollect)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if len(result.Values) != 1 {
+		t.Errorf("Unexpected values %v", result.Values)
+	}
+	expected := []UnknownField{
+		{Field: 2, Bytes: [][]byte{[]byte("ok")}},
+		{Field: 3, Bytes: [][]byte{{0x0A, 0x05}}},
+		{Field: 4, Bytes: [][]byte{{0x08}}},
+		{Field: 5, Varint: []WireVarint{1, 2}},
+	}
+	if !reflect.DeepEqual(result.Unknown, expected) {
+		t.Errorf("Expected unknown fields %v, got %v", expected, result.Unknown)
+	}
+
+	result = fm.DecodeBufferResult(newDecodeResultTestBuffer(t), UnknownFieldsFail)
+	if !errors.Is(result.Err, ErrUnknownField) {
+		t.Errorf("Expected ErrUnknownField, got %v", result.Err)
+	}
+	if result.Values != nil || len(result.Unknown) != 4 {
+		t.Errorf("Unexpected failed result %+v", result)
+	}
+}
+
+func TestDecodeResultMalformed(t *testing.T) {
+	fm := newDecodeResultTestMap()
+	result := fm.DecodeBufferResult([]byte{0x0A, 0x05}, UnknownFieldsCollect)
+	if result.Err == nil || result.Values != nil {
+		t.Errorf("Expected an unmarshal error, got %+v", result)
+	}
+}
@@ -0,0 +1,403 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file builds ProtoFieldMaps from Protobuf descriptors, such as the
+// FileDescriptorSet produced by "protoc --descriptor_set_out".
+// This saves the user from rebuilding every association by hand.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ErrUnresolvedType is returned when a descriptor references a message or
+// enum type that could not be found
+var ErrUnresolvedType = errors.New("Unresolved type name")
+
+// ErrMissingImport is returned when a file descriptor imports a file that
+// was not loaded
+var ErrMissingImport = errors.New("Missing import")
+
+// NewProtoFieldMapFromDescriptor creates a ProtoFieldMap from the message
+// descriptor msg. Field names, labels, oneofs, maps and nested message or
+// enum types declared inside msg are all filled in.
+//
+// Types referenced from outside of msg are looked up in deps. Any that can't
+// be found are left as plain TYPE_MESSAGE or TYPE_ENUM associations.
+// proto2 group fields are not supported and are left out of the schema,
+// so they decode as unknown fields.
+func NewProtoFieldMapFromDescriptor(msg *descriptor.DescriptorProto, deps ...*Registry) (*ProtoFieldMap, error) {
+	l := newDescriptorLoader(deps, false)
+	l.declareMessage("", msg, false)
+	if err := l.resolve(); err != nil {
+		return nil, err
+	}
+	return l.messages[msg.GetName()], nil
+}
+
+// AddFiles loads all messages and enums declared in the file descriptors into
+// the Registry. Type names are resolved against the given files and anything
+// already in the Registry. Every import must either be in files or have been
+// loaded previously. proto2 group fields are left out, see
+// NewProtoFieldMapFromDescriptor.
+//
+// The Registry is left untouched if an error is returned.
+func (r *Registry) AddFiles(files ...*descriptor.FileDescriptorProto) error {
+	return r.addFiles("", files)
+}
+
+// AddFilesVersion is like AddFiles, but loads every message and enum under
+// the version tag, making them the current versions. Type names are resolved
+// against the given files first, so the types of one version refer to
+// each other.
+func (r *Registry) AddFilesVersion(version string, files ...*descriptor.FileDescriptorProto) error {
+	if version == "" {
+		return fmt.Errorf("empty version tag")
+	}
+	return r.addFiles(version, files)
+}
+
+func (r *Registry) addFiles(version string, files []*descriptor.FileDescriptorProto) error {
+	loading := make(map[string]bool, len(files))
+	for _, fd := range files {
+		loading[fd.GetName()] = true
+	}
+	for _, fd := range files {
+		for _, dep := range fd.GetDependency() {
+			if !loading[dep] && !r.files[dep] {
+				return fmt.Errorf("%w: %s imports %s", ErrMissingImport, fd.GetName(), dep)
+			}
+		}
+	}
+
+	l := newDescriptorLoader([]*Registry{r}, true)
+	for _, fd := range files {
+		for _, e := range fd.GetEnumType() {
+			l.declareEnum(fd.GetPackage(), e)
+		}
+		for _, msg := range fd.GetMessageType() {
+			l.declareMessage(fd.GetPackage(), msg, fd.GetSyntax() == "proto3")
+		}
+	}
+	if err := l.resolve(); err != nil {
+		return err
+	}
+
+	for name := range l.messages {
+		if _, ok := r.GetMessage(versionedName(name, version)); ok {
+			return fmt.Errorf("duplicate message %s", name)
+		}
+	}
+	for name := range l.enums {
+		if _, ok := r.GetEnum(versionedName(name, version)); ok {
+			return fmt.Errorf("duplicate enum %s", name)
+		}
+	}
+	for name, fm := range l.messages {
+		if version == "" {
+			r.messages[name] = fm
+		} else {
+			r.AddMessageVersion(fm, version)
+		}
+	}
+	for name, e := range l.enums {
+		if version == "" {
+			r.enums[name] = e
+		} else {
+			r.AddEnumVersion(e, version)
+		}
+	}
+	for name := range loading {
+		r.files[name] = true
+	}
+	return nil
+}
+
+// AddFileDescriptorSet loads all files in the FileDescriptorSet into
+// the Registry. See AddFiles.
+func (r *Registry) AddFileDescriptorSet(set *descriptor.FileDescriptorSet) error {
+	return r.AddFiles(set.GetFile()...)
+}
+
+// NewRegistryFromFileDescriptorSet creates a new Registry holding all
+// messages and enums in the FileDescriptorSet
+func NewRegistryFromFileDescriptorSet(set *descriptor.FileDescriptorSet) (*Registry, error) {
+	r := NewRegistry()
+	if err := r.AddFileDescriptorSet(set); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadFileDescriptorSet unmarshals a binary FileDescriptorSet, as written by
+// "protoc --include_imports --descriptor_set_out", into a new Registry
+func LoadFileDescriptorSet(buf []byte) (*Registry, error) {
+	set, err := unmarshalFileDescriptorSet(buf)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistryFromFileDescriptorSet(set)
+}
+
+// unmarshalFileDescriptorSet unmarshals a binary FileDescriptorSet
+func unmarshalFileDescriptorSet(buf []byte) (*descriptor.FileDescriptorSet, error) {
+	set := new(descriptor.FileDescriptorSet)
+	if err := proto.Unmarshal(buf, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// pendingMessage is a declared message whose fields have yet to be resolved
+type pendingMessage struct {
+	fm     *ProtoFieldMap
+	desc   *descriptor.DescriptorProto
+	proto3 bool
+}
+
+// descriptorLoader turns descriptors into ProtoFieldMaps in two passes.
+// First all message and enum names are declared, then all fields are
+// resolved, which allows for forward and recursive references.
+type descriptorLoader struct {
+	deps     []*Registry
+	strict   bool
+	messages map[string]*ProtoFieldMap
+	enums    map[string]*ProtoEnum
+	entries  map[string]*descriptor.DescriptorProto
+	pending  []pendingMessage
+}
+
+func newDescriptorLoader(deps []*Registry, strict bool) *descriptorLoader {
+	return &descriptorLoader{
+		deps:     deps,
+		strict:   strict,
+		messages: make(map[string]*ProtoFieldMap),
+		enums:    make(map[string]*ProtoEnum),
+		entries:  make(map[string]*descriptor.DescriptorProto),
+	}
+}
+
+// qualify joins a scope and a name into a fully qualified name
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// declareMessage declares msg and all of its nested types under scope.
+// Proto3 messages pack their repeated scalars by default.
+func (l *descriptorLoader) declareMessage(scope string, msg *descriptor.DescriptorProto, proto3 bool) {
+	name := qualify(scope, msg.GetName())
+
+	// Map entries are synthesized by protoc and are not useful on their own
+	if msg.GetOptions().GetMapEntry() {
+		l.entries[name] = msg
+		return
+	}
+
+	fm := NewProtoFieldMap()
+	fm.SetName(name)
+	l.messages[name] = fm
+	l.pending = append(l.pending, pendingMessage{fm, msg, proto3})
+
+	for _, e := range msg.GetEnumType() {
+		l.declareEnum(name, e)
+	}
+	for _, nested := range msg.GetNestedType() {
+		l.declareMessage(name, nested, proto3)
+	}
+}
+
+// declareEnum declares the enum e under scope
+func (l *descriptorLoader) declareEnum(scope string, e *descriptor.EnumDescriptorProto) {
+	name := qualify(scope, e.GetName())
+	enum := NewProtoEnum(name)
+	for _, v := range e.GetValue() {
+		enum.Add(v.GetName(), v.GetNumber())
+	}
+	l.enums[name] = enum
+}
+
+// lookup finds the fully qualified name that typeName refers to from within
+// scope, following Protobuf's scoping rules. Names starting with a dot are
+// already fully qualified. Otherwise, the innermost scope is searched first.
+func (l *descriptorLoader) lookup(scope, typeName string) (string, bool) {
+	if strings.HasPrefix(typeName, ".") {
+		name := normalizeTypeName(typeName)
+		return name, l.known(name)
+	}
+	for {
+		name := qualify(scope, typeName)
+		if l.known(name) {
+			return name, true
+		}
+		if scope == "" {
+			return "", false
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// known indicates if name is a declared or dependency message, enum or
+// map entry
+func (l *descriptorLoader) known(name string) bool {
+	if _, ok := l.getMessage(name); ok {
+		return true
+	}
+	if _, ok := l.getEnum(name); ok {
+		return true
+	}
+	_, ok := l.entries[name]
+	return ok
+}
+
+func (l *descriptorLoader) getMessage(name string) (*ProtoFieldMap, bool) {
+	if fm, ok := l.messages[name]; ok {
+		return fm, true
+	}
+	for _, r := range l.deps {
+		if fm, ok := r.GetMessage(name); ok {
+			return fm, true
+		}
+	}
+	return nil, false
+}
+
+func (l *descriptorLoader) getEnum(name string) (*ProtoEnum, bool) {
+	if e, ok := l.enums[name]; ok {
+		return e, true
+	}
+	for _, r := range l.deps {
+		if e, ok := r.GetEnum(name); ok {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// resolve fills in the fields of all declared messages
+func (l *descriptorLoader) resolve() error {
+	for _, p := range l.pending {
+		for _, r := range p.desc.GetExtensionRange() {
+			// Descriptors store the end of the range exclusively
+			if !p.fm.AddExtensionRange(FieldNum(r.GetStart()), FieldNum(r.GetEnd()-1)) {
+				return fmt.Errorf("%s: invalid extension range %d to %d", p.fm.Name(), r.GetStart(), r.GetEnd()-1)
+			}
+		}
+		for _, f := range p.desc.GetField() {
+			if f.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+				// Groups are not supported, so they are left out like
+				// unknown fields, instead of failing the whole file
+				continue
+			}
+			if err := l.resolveField(p.fm, f, p.proto3); err != nil {
+				return fmt.Errorf("%s.%s: %w", p.fm.Name(), f.GetName(), err)
+			}
+		}
+
+		// Group the oneof members by their declaration
+		oneofs := make([][]FieldNum, len(p.desc.GetOneofDecl()))
+		for _, f := range p.desc.GetField() {
+			if f.OneofIndex != nil && int(f.GetOneofIndex()) < len(oneofs) &&
+				f.GetType() != descriptor.FieldDescriptorProto_TYPE_GROUP {
+				i := f.GetOneofIndex()
+				oneofs[i] = append(oneofs[i], FieldNum(f.GetNumber()))
+			}
+		}
+		for i, decl := range p.desc.GetOneofDecl() {
+			if len(oneofs[i]) > 0 && !p.fm.AddOneof(decl.GetName(), oneofs[i]...) {
+				return fmt.Errorf("%s: invalid oneof %s", p.fm.Name(), decl.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// resolveField adds the field descriptor f to fm
+func (l *descriptorLoader) resolveField(fm *ProtoFieldMap, f *descriptor.FieldDescriptorProto, proto3 bool) error {
+	field := FieldNum(f.GetNumber())
+	typ := f.GetType()
+
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		name, ok := l.lookup(fm.Name(), f.GetTypeName())
+		if !ok {
+			if l.strict {
+				return fmt.Errorf("%w: %s", ErrUnresolvedType, f.GetTypeName())
+			}
+			fm.Add(field, typ)
+			break
+		}
+		if entry, isEntry := l.entries[name]; isEntry {
+			if err := l.resolveMapEntry(fm, field, name, entry); err != nil {
+				return err
+			}
+		} else if sub, isMsg := l.getMessage(name); isMsg {
+			fm.AddMessage(field, sub)
+		} else if enum, isEnum := l.getEnum(name); isEnum {
+			fm.AddEnum(field, enum)
+		}
+	default:
+		if !fm.Add(field, typ) {
+			return fmt.Errorf("%w: %v", ErrInvalidProtoBufType, typ)
+		}
+	}
+
+	if f.Label != nil {
+		fm.SetLabel(field, f.GetLabel())
+	}
+	if opts := f.GetOptions(); opts != nil && opts.Packed != nil {
+		fm.SetPacked(field, opts.GetPacked())
+	} else if proto3 && f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		fm.SetPacked(field, true)
+	}
+	if !fm.SetFieldName(field, f.GetName()) {
+		return fmt.Errorf("duplicate field name %s", f.GetName())
+	}
+	return nil
+}
+
+// resolveMapEntry adds field to fm as a map, using the key and value of
+// the map entry descriptor
+func (l *descriptorLoader) resolveMapEntry(fm *ProtoFieldMap, field FieldNum, name string, entry *descriptor.DescriptorProto) error {
+	var key, value *descriptor.FieldDescriptorProto
+	for _, f := range entry.GetField() {
+		switch FieldNum(f.GetNumber()) {
+		case mapEntryKeyField:
+			key = f
+		case mapEntryValueField:
+			value = f
+		}
+	}
+	if key == nil || value == nil {
+		return fmt.Errorf("malformed map entry %s", name)
+	}
+
+	e := mapEntryType{key: key.GetType(), value: value.GetType()}
+	switch e.value {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		valueName, ok := l.lookup(name, value.GetTypeName())
+		if !ok && l.strict {
+			return fmt.Errorf("%w: %s", ErrUnresolvedType, value.GetTypeName())
+		}
+		e.valueMsg, _ = l.getMessage(valueName)
+		e.valueEnum, _ = l.getEnum(valueName)
+	}
+
+	if !fm.AddMap(field, e.key, e.value) {
+		return fmt.Errorf("%w: map<%v, %v>", ErrInvalidProtoBufType, e.key, e.value)
+	}
+	fm.maps[field] = e
+	return nil
+}
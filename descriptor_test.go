@@ -0,0 +1,278 @@
+package dproto
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// testMessageFile describes testprotobuf.proto, as protoc would
+func testMessageFile() *descriptor.FileDescriptorProto {
+	optional := descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	field := func(name string, number int32, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+		return &descriptor.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  optional,
+			Type:   typ.Enum(),
+		}
+	}
+	myenum := field("myenum", 8, descriptor.FieldDescriptorProto_TYPE_ENUM)
+	myenum.TypeName = proto.String(".TestEnum")
+
+	return &descriptor.FileDescriptorProto{
+		Name:   proto.String("testprotobuf.proto"),
+		Syntax: proto.String("proto2"),
+		EnumType: []*descriptor.EnumDescriptorProto{
+			{
+				Name: proto.String("TestEnum"),
+				Value: []*descriptor.EnumValueDescriptorProto{
+					{Name: proto.String("FIRST"), Number: proto.Int32(0)},
+					{Name: proto.String("SECOND"), Number: proto.Int32(1)},
+					{Name: proto.String("THIRD"), Number: proto.Int32(2)},
+					{Name: proto.String("FOURTH"), Number: proto.Int32(3)},
+				},
+			},
+		},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("TestMessage"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("myint32", 1, descriptor.FieldDescriptorProto_TYPE_INT32),
+					field("myint64", 2, descriptor.FieldDescriptorProto_TYPE_INT64),
+					field("myuint32", 3, descriptor.FieldDescriptorProto_TYPE_UINT32),
+					field("myuint64", 4, descriptor.FieldDescriptorProto_TYPE_UINT64),
+					field("mysint32", 5, descriptor.FieldDescriptorProto_TYPE_SINT32),
+					field("mysint64", 6, descriptor.FieldDescriptorProto_TYPE_SINT64),
+					field("mybool", 7, descriptor.FieldDescriptorProto_TYPE_BOOL),
+					myenum,
+					field("myfixed64", 9, descriptor.FieldDescriptorProto_TYPE_FIXED64),
+					field("mysfixed64", 10, descriptor.FieldDescriptorProto_TYPE_SFIXED64),
+					field("mydouble", 11, descriptor.FieldDescriptorProto_TYPE_DOUBLE),
+					field("myfixed32", 12, descriptor.FieldDescriptorProto_TYPE_FIXED32),
+					field("mysfixed32", 13, descriptor.FieldDescriptorProto_TYPE_SFIXED32),
+					field("myfloat", 14, descriptor.FieldDescriptorProto_TYPE_FLOAT),
+					field("mystring", 15, descriptor.FieldDescriptorProto_TYPE_STRING),
+				},
+			},
+		},
+	}
+}
+
+func TestRegistryFromFileDescriptorSet(t *testing.T) {
+	set := &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{testMessageFile()},
+	}
+	buf, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadFileDescriptorSet(buf)
+	if err != nil {
+		t.Fatal("Error loading descriptor set: " + err.Error())
+	}
+
+	fm, ok := r.GetMessage("TestMessage")
+	if !ok {
+		t.Fatal("TestMessage was not loaded")
+	}
+	enum, ok := fm.GetEnum(8)
+	if !ok || enum.Name() != "TestEnum" {
+		t.Fatal("myenum was not resolved to TestEnum")
+	}
+	if name, _ := enum.GetName(2); name != "THIRD" {
+		t.Errorf("Expected enum value 2 to be THIRD, got %s", name)
+	}
+
+	pbuf, err := ioutil.ReadFile(protobufBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := fm.DecodeBuffer(pbuf)
+	if err != nil {
+		t.Fatal("Error Decoding: " + err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"myint32":    ans[0],
+		"mysint64":   ans[5],
+		"myfloat":    ans[12],
+		"myenum":     uint64(2),
+		"mysfixed32": ans[11],
+	}
+	for _, v := range values {
+		name, ok := fm.GetFieldName(v.Field)
+		if !ok {
+			t.Errorf("Field %d has no name", v.Field)
+		}
+		if want, ok := expected[name]; ok && want != v.Value {
+			t.Errorf("%s = %v, expected %v", name, v.Value, want)
+		}
+	}
+}
+
+func TestRegistryImportsAndNesting(t *testing.T) {
+	base := testMessageFile()
+	base.Package = proto.String("test")
+	base.MessageType[0].Field[7].TypeName = proto.String(".test.TestEnum")
+
+	repeated := descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	user := &descriptor.FileDescriptorProto{
+		Name:       proto.String("user.proto"),
+		Package:    proto.String("test.user"),
+		Dependency: []string{"testprotobuf.proto"},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Outer"),
+				Field: []*descriptor.FieldDescriptorProto{
+					{
+						Name:     proto.String("inner"),
+						Number:   proto.Int32(1),
+						Label:    repeated,
+						Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String("Inner"), // relative name
+					},
+					{
+						Name:     proto.String("tests"),
+						Number:   proto.Int32(2),
+						Label:    repeated,
+						Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String("TestsEntry"),
+					},
+				},
+				NestedType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("Inner"),
+						Field: []*descriptor.FieldDescriptorProto{
+							{
+								Name:     proto.String("e"),
+								Number:   proto.Int32(1),
+								Type:     descriptor.FieldDescriptorProto_TYPE_ENUM.Enum(),
+								TypeName: proto.String("test.TestEnum"),
+							},
+						},
+					},
+					{
+						Name:    proto.String("TestsEntry"),
+						Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+						Field: []*descriptor.FieldDescriptorProto{
+							{
+								Name:   proto.String("key"),
+								Number: proto.Int32(1),
+								Type:   descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+							},
+							{
+								Name:     proto.String("value"),
+								Number:   proto.Int32(2),
+								Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+								TypeName: proto.String(".test.TestMessage"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	r := NewRegistry()
+	if err := r.AddFiles(user); !errors.Is(err, ErrMissingImport) {
+		t.Fatalf("Expected ErrMissingImport, got %v", err)
+	}
+	if err := r.AddFiles(base, user); err != nil {
+		t.Fatal(err)
+	}
+
+	outer, ok := r.GetMessage("test.user.Outer")
+	if !ok {
+		t.Fatal("test.user.Outer was not loaded")
+	}
+	if _, ok := r.GetMessage("test.user.Outer.TestsEntry"); ok {
+		t.Error("Map entry should not be registered as a message")
+	}
+
+	inner, _ := outer.GetMessage(1)
+	values := []FieldValue{
+		{Field: 1, Value: []interface{}{
+			[]FieldValue{{Field: 1, Value: "SECOND"}},
+			[]FieldValue{{Field: 1, Value: "FOURTH"}},
+		}},
+		{Field: 2, Value: map[string]interface{}{
+			"a": []FieldValue{{Field: 7, Value: true}},
+		}},
+	}
+	buf, err := outer.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+	decoded, err := outer.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal("Error Decoding: " + err.Error())
+	}
+	for _, v := range decoded {
+		switch v.Field {
+		case 1:
+			list := v.Value.([]interface{})
+			if len(list) != 2 {
+				t.Fatalf("Expected 2 inner messages, got %d", len(list))
+			}
+			second := list[1].([]FieldValue)
+			if second[0].Value != uint64(3) {
+				t.Errorf("Expected FOURTH (3), got %v", second[0].Value)
+			}
+		case 2:
+			entry := v.Value.(map[interface{}]interface{})["a"].([]FieldValue)
+			if entry[0].Value != true {
+				t.Errorf("Expected map value mybool true, got %v", entry[0].Value)
+			}
+		}
+	}
+	if inner.Name() != "test.user.Outer.Inner" {
+		t.Errorf("Unexpected nested name %s", inner.Name())
+	}
+}
+
+func TestRegistrySkipsGroups(t *testing.T) {
+	optional := descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("group.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("WithGroup"),
+			Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("id"), Number: proto.Int32(1), Label: optional, Type: descriptor.FieldDescriptorProto_TYPE_INT32.Enum()},
+				{Name: proto.String("result"), Number: proto.Int32(2), Label: optional, Type: descriptor.FieldDescriptorProto_TYPE_GROUP.Enum(), TypeName: proto.String(".test.WithGroup.Result"), OneofIndex: proto.Int32(0)},
+				{Name: proto.String("error"), Number: proto.Int32(3), Label: optional, Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum(), OneofIndex: proto.Int32(0)},
+			},
+			NestedType: []*descriptor.DescriptorProto{{
+				Name:  proto.String("Result"),
+				Field: []*descriptor.FieldDescriptorProto{{Name: proto.String("url"), Number: proto.Int32(1), Label: optional, Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()}},
+			}},
+			OneofDecl: []*descriptor.OneofDescriptorProto{{Name: proto.String("outcome")}},
+		}, {
+			Name:  proto.String("Other"),
+			Field: []*descriptor.FieldDescriptorProto{{Name: proto.String("name"), Number: proto.Int32(1), Label: optional, Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()}},
+		}},
+	}
+
+	r := NewRegistry()
+	if err := r.AddFiles(file); err != nil {
+		t.Fatal(err)
+	}
+	fm, ok := r.GetMessage("test.WithGroup")
+	if !ok {
+		t.Fatal("test.WithGroup was not loaded")
+	}
+	if _, ok := fm.GetFieldByName("result"); ok {
+		t.Error("The group field should be left out")
+	}
+	if fields, ok := fm.GetOneof("outcome"); !ok || len(fields) != 1 || fields[0] != 3 {
+		t.Errorf("Unexpected oneof members %v", fields)
+	}
+	if _, ok := r.GetMessage("test.Other"); !ok {
+		t.Error("test.Other was not loaded")
+	}
+}
@@ -21,6 +21,8 @@ package dproto
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 )
@@ -35,8 +37,28 @@ type FieldValue struct {
 }
 
 // ProtoFieldMap associates field numbers with it's high-level Protobuf type.
+//
+// Optionally, a ProtoFieldMap can also hold the message name, field names,
+// field labels and the schemas of nested message and enum fields. These are
+// filled in automatically when loaded from a descriptor.
+//
+// A ProtoFieldMap may be used by many goroutines at once, as long as none of
+// them modify it. To keep changing a schema while it is in use, publish
+// snapshots of it made with Freeze.
 type ProtoFieldMap struct {
-	field2type map[FieldNum]descriptor.FieldDescriptorProto_Type
+	name        string
+	field2type  map[FieldNum]descriptor.FieldDescriptorProto_Type
+	field2name  map[FieldNum]string
+	name2field  map[string]FieldNum
+	field2label map[FieldNum]descriptor.FieldDescriptorProto_Label
+	packed      map[FieldNum]bool
+	field2msg   map[FieldNum]*ProtoFieldMap
+	field2enum  map[FieldNum]*ProtoEnum
+	maps        map[FieldNum]mapEntryType
+	oneofs      map[string][]FieldNum
+	field2oneof map[FieldNum]string
+	extRanges   []ExtensionRange
+	frozen      bool
 }
 
 // NewProtoFieldMap create a new ProtoFieldMap object.
@@ -48,32 +70,134 @@ func NewProtoFieldMap() *ProtoFieldMap {
 
 // Reset clears the stored associations inside a ProtoFieldMap
 func (fm *ProtoFieldMap) Reset() {
+	fm.checkMutable()
 	fm.field2type = make(map[FieldNum]descriptor.FieldDescriptorProto_Type)
+	fm.field2name = make(map[FieldNum]string)
+	fm.name2field = make(map[string]FieldNum)
+	fm.field2label = make(map[FieldNum]descriptor.FieldDescriptorProto_Label)
+	fm.packed = make(map[FieldNum]bool)
+	fm.field2msg = make(map[FieldNum]*ProtoFieldMap)
+	fm.field2enum = make(map[FieldNum]*ProtoEnum)
+	fm.maps = make(map[FieldNum]mapEntryType)
+	fm.oneofs = make(map[string][]FieldNum)
+	fm.field2oneof = make(map[FieldNum]string)
+	fm.extRanges = nil
+}
+
+// Name returns the fully qualified message name of the ProtoFieldMap, if set
+func (fm *ProtoFieldMap) Name() string {
+	return fm.name
+}
+
+// SetName sets the fully qualified message name of the ProtoFieldMap
+func (fm *ProtoFieldMap) SetName(name string) {
+	fm.checkMutable()
+	fm.name = name
 }
 
 // Add adds a Field-Type association to a ProtoFieldMap
 func (fm *ProtoFieldMap) Add(field FieldNum, typ descriptor.FieldDescriptorProto_Type) (ok bool) {
+	fm.checkMutable()
 	// check that the typ is valid
 	if _, ok = protoType2WireType[typ]; ok {
 		fm.field2type[field] = typ
+		delete(fm.maps, field)
+		delete(fm.field2msg, field)
+		delete(fm.field2enum, field)
+		if !packableType(typ) {
+			delete(fm.packed, field)
+		}
 	}
 	return
 }
 
+// AddMessage adds a Field-Message association to a ProtoFieldMap.
+// The field is decoded and encoded using the nested schema sub.
+func (fm *ProtoFieldMap) AddMessage(field FieldNum, sub *ProtoFieldMap) bool {
+	fm.checkMutable()
+	if sub == nil {
+		return false
+	}
+	fm.Add(field, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	fm.field2msg[field] = sub
+	return true
+}
+
+// AddEnum adds a Field-Enum association to a ProtoFieldMap.
+// Enum values may be given to the encoder by name.
+func (fm *ProtoFieldMap) AddEnum(field FieldNum, enum *ProtoEnum) bool {
+	fm.checkMutable()
+	if enum == nil {
+		return false
+	}
+	fm.Add(field, descriptor.FieldDescriptorProto_TYPE_ENUM)
+	fm.field2enum[field] = enum
+	return true
+}
+
+// SetFieldName names a previously added field.
+// It returns false if the field is unknown or the name is already in use
+// by another field.
+func (fm *ProtoFieldMap) SetFieldName(field FieldNum, name string) bool {
+	fm.checkMutable()
+	if _, ok := fm.field2type[field]; !ok {
+		return false
+	}
+	if other, ok := fm.name2field[name]; ok && other != field {
+		return false
+	}
+	if old, ok := fm.field2name[field]; ok {
+		delete(fm.name2field, old)
+	}
+	fm.field2name[field] = name
+	fm.name2field[name] = field
+	return true
+}
+
+// SetLabel sets the label (optional, required or repeated) of a previously
+// added field. Fields labeled repeated decode to a []interface{} holding
+// every occurrence, and must be given as a slice to encode.
+// It returns false if the field is unknown.
+func (fm *ProtoFieldMap) SetLabel(field FieldNum, label descriptor.FieldDescriptorProto_Label) bool {
+	fm.checkMutable()
+	if _, ok := fm.field2type[field]; !ok {
+		return false
+	}
+	fm.field2label[field] = label
+	return true
+}
+
 // RemoveByField removes the Field-Type association from a ProtoFieldMap
 // that has the specified field number.
 // It returns true if the association was found and removed, false otherwise
 func (fm *ProtoFieldMap) RemoveByField(field FieldNum) (ok bool) {
+	fm.checkMutable()
 	if _, ok = fm.field2type[field]; ok {
-		delete(fm.field2type, field)
+		fm.forget(field)
 	}
 	return
 }
 
+// forget drops the field and everything associated with it
+func (fm *ProtoFieldMap) forget(field FieldNum) {
+	delete(fm.field2type, field)
+	if name, ok := fm.field2name[field]; ok {
+		delete(fm.name2field, name)
+		delete(fm.field2name, field)
+	}
+	delete(fm.field2label, field)
+	delete(fm.packed, field)
+	delete(fm.field2msg, field)
+	delete(fm.field2enum, field)
+	delete(fm.maps, field)
+	fm.removeFromOneof(field)
+}
+
 // RemoveByType removes all field Field-Type association from a ProtoFieldMap
 // that has the specified type. This will check all map entries.
 // It returns true if an association was found and removed, false otherwise
 func (fm *ProtoFieldMap) RemoveByType(typ descriptor.FieldDescriptorProto_Type) bool {
+	fm.checkMutable()
 	deleteList := make([]FieldNum, 0, len(fm.field2type))
 	for k, v := range fm.field2type {
 		if v == typ {
@@ -86,7 +210,7 @@ func (fm *ProtoFieldMap) RemoveByType(typ descriptor.FieldDescriptorProto_Type)
 	}
 
 	for _, k := range deleteList {
-		delete(fm.field2type, k)
+		fm.forget(k)
 	}
 	return true
 }
@@ -97,6 +221,49 @@ func (fm *ProtoFieldMap) Get(field FieldNum) (descriptor.FieldDescriptorProto_Ty
 	return typ, ok
 }
 
+// GetFieldName gets the name associated with the field number
+func (fm *ProtoFieldMap) GetFieldName(field FieldNum) (string, bool) {
+	name, ok := fm.field2name[field]
+	return name, ok
+}
+
+// GetFieldByName gets the field number associated with the field name
+func (fm *ProtoFieldMap) GetFieldByName(name string) (FieldNum, bool) {
+	field, ok := fm.name2field[name]
+	return field, ok
+}
+
+// GetLabel gets the label of the field number.
+// Fields without an explicit label are reported as optional.
+func (fm *ProtoFieldMap) GetLabel(field FieldNum) descriptor.FieldDescriptorProto_Label {
+	if label, ok := fm.field2label[field]; ok {
+		return label
+	}
+	return descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+}
+
+// GetMessage gets the nested message schema associated with the field number
+func (fm *ProtoFieldMap) GetMessage(field FieldNum) (*ProtoFieldMap, bool) {
+	sub, ok := fm.field2msg[field]
+	return sub, ok
+}
+
+// GetEnum gets the enum associated with the field number
+func (fm *ProtoFieldMap) GetEnum(field FieldNum) (*ProtoEnum, bool) {
+	enum, ok := fm.field2enum[field]
+	return enum, ok
+}
+
+// GetFieldNums gets all field numbers in the ProtoFieldMap in increasing order
+func (fm *ProtoFieldMap) GetFieldNums() []FieldNum {
+	fields := make(fieldNumArray, 0, len(fm.field2type))
+	for f := range fm.field2type {
+		fields = append(fields, f)
+	}
+	sort.Sort(fields)
+	return []FieldNum(fields)
+}
+
 // Print shows the ProtoFieldMap to the user for debugging purposes.
 func (fm *ProtoFieldMap) Print() {
 	fmt.Println(fm)
@@ -104,26 +271,68 @@ func (fm *ProtoFieldMap) Print() {
 
 // DecodeMessage will decode all fields in the specified message using the
 // current ProtoFieldMap
+//
+// Only the active member of each oneof is decoded. See WhichOneof.
+// Fields associated with a nested message schema decode to a []FieldValue.
+// Fields that fail to decode are dropped and the first error is returned.
+// Use DecodeMessageResult to see all errors and unknown fields.
 func (fm *ProtoFieldMap) DecodeMessage(m *WireMessage) ([]FieldValue, error) {
 	values := make([]FieldValue, 0, m.GetFieldCount())
 	err := error(nil)
 
-	for _, f := range m.GetFieldNums() {
-		// Ignore fields that we are not aware/interested of/in - a feature
-		if typ, ok := fm.field2type[f]; ok {
-			// Pass over decodings that don't succeed - report first error
-			if v, e := m.DecodeAs(f, typ); e == nil {
-				values = append(values, FieldValue{f, v})
-			} else {
-				// save first error
-				if err == nil {
-					err = e
-				}
+	// Ignore fields that we are not aware/interested of/in - a feature
+	fm.decodeFields(m, func(f FieldNum, v interface{}, e error) {
+		// Pass over decodings that don't succeed - report first error
+		if e == nil {
+			values = append(values, FieldValue{f, v})
+		} else if err == nil {
+			err = e
+		}
+	}, func(FieldNum) {})
+
+	return values, err
+}
+
+// decodeField decodes a single known field from m
+func (fm *ProtoFieldMap) decodeField(m *WireMessage, field FieldNum) (interface{}, error) {
+	typ := fm.field2type[field]
+
+	if entry, isMap := fm.maps[field]; isMap {
+		return entry.decode(m, field)
+	}
+
+	if fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		vals, err := m.DecodeRepeatedAs(field, typ)
+		if err != nil {
+			return nil, err
+		}
+		for i := range vals {
+			if vals[i], err = fm.decodeNested(field, vals[i]); err != nil {
+				return nil, err
 			}
 		}
+		return vals, nil
 	}
 
-	return values, err
+	v, err := m.DecodeAs(field, typ)
+	if err != nil {
+		return nil, err
+	}
+	return fm.decodeNested(field, v)
+}
+
+// decodeNested decodes an embedded message using the nested schema of field,
+// if one is known
+func (fm *ProtoFieldMap) decodeNested(field FieldNum, value interface{}) (interface{}, error) {
+	if sub, ok := fm.field2msg[field]; ok {
+		if v, ok, err := decodeWellKnown(sub, value); ok {
+			return v, err
+		}
+		if wm, ok := value.(*WireMessage); ok {
+			return sub.DecodeMessage(wm)
+		}
+	}
+	return value, nil
 }
 
 // DecodeBuffer will unmarshal and decode all fields in the specified buffer
@@ -138,16 +347,102 @@ func (fm *ProtoFieldMap) DecodeBuffer(buf []byte) ([]FieldValue, error) {
 
 // EncodeMessage will marshal and encode all fields given. The output is a
 // new message.
+//
+// ErrOneofConflict is returned if values sets more than one member
+// of the same oneof.
 func (fm *ProtoFieldMap) EncodeMessage(values []FieldValue) (*WireMessage, error) {
+	return fm.encodeMessage(values, wireMode{})
+}
+
+// encodeMessage encodes values into a new message with the given mode
+func (fm *ProtoFieldMap) encodeMessage(values []FieldValue, mode wireMode) (*WireMessage, error) {
+	if err := fm.checkOneofs(values); err != nil {
+		return nil, err
+	}
 	m := NewWireMessage()
+	m.mode = mode
 	for _, v := range values {
-		if err := m.EncodeAs(v.Field, v.Value, fm.field2type[v.Field]); err != nil {
+		if err := fm.encodeField(m, v.Field, v.Value); err != nil {
 			return nil, err
 		}
 	}
 	return m, nil
 }
 
+// encodeField encodes a single field value into m
+func (fm *ProtoFieldMap) encodeField(m *WireMessage, field FieldNum, value interface{}) error {
+	typ := fm.field2type[field]
+
+	if entry, isMap := fm.maps[field]; isMap {
+		return entry.encode(m, field, value)
+	}
+
+	if fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice {
+			return ErrInvalidProtoBufType
+		}
+		m.Remove(field)
+		vals := make([]interface{}, rv.Len())
+		for i := range vals {
+			v, err := fm.encodeNested(m, field, rv.Index(i).Interface())
+			if err != nil {
+				return err
+			}
+			vals[i] = v
+		}
+		if fm.IsPacked(field) {
+			return m.appendPackedAs(field, vals, typ)
+		}
+		for _, v := range vals {
+			if err := m.AppendAs(field, v, typ); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	v, err := fm.encodeNested(m, field, value)
+	if err != nil || v == nil {
+		return err
+	}
+	return m.EncodeAs(field, v, typ)
+}
+
+// encodeNested converts values given in terms of the nested message schema
+// or enum of field into their wire message or number. Nested messages
+// inherit the mode of m.
+func (fm *ProtoFieldMap) encodeNested(m *WireMessage, field FieldNum, value interface{}) (interface{}, error) {
+	if sub, ok := fm.field2msg[field]; ok {
+		if wm, ok, err := encodeWellKnown(sub, value); ok {
+			if wm == nil && err == nil {
+				// A nil pointer leaves the field out
+				return nil, nil
+			}
+			return wm, err
+		}
+		switch v := value.(type) {
+		case []FieldValue:
+			return sub.encodeMessage(v, m.mode)
+		case *DynamicMessage:
+			if v.fm != sub {
+				return nil, fmt.Errorf("%w: message %s given for a field of type %s", ErrInvalidProtoBufType, v.fm.name, sub.name)
+			}
+			return v.wire, nil
+		}
+	}
+	if enum, ok := fm.field2enum[field]; ok {
+		if name, ok := value.(string); ok {
+			number, ok := enum.GetNumber(name)
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown value %q for enum %s", ErrInvalidProtoBufType, name, enum.Name())
+			}
+			return uint64(int64(number)), nil
+		}
+	}
+	return value, nil
+}
+
 // EncodeBuffer will marshal and encode all fields given. The output is a
 // raw buffer.
 func (fm *ProtoFieldMap) EncodeBuffer(values []FieldValue) ([]byte, error) {
@@ -0,0 +1,180 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the DynamicMessage, which binds a WireMessage to its
+// ProtoFieldMap. It is the easiest way to read and build messages, while
+// WireMessage remains the low-level layer underneath.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownField is returned when accessing a field that is not part of
+// the message schema
+var ErrUnknownField = errors.New("Field not in schema")
+
+// DynamicMessage is a message whose fields are read and written in terms of
+// its schema. Values are given and returned as the same Go types used by
+// ProtoFieldMap.DecodeMessage and ProtoFieldMap.EncodeMessage.
+type DynamicMessage struct {
+	fm   *ProtoFieldMap
+	wire *WireMessage
+}
+
+// NewDynamicMessage creates a new empty message with the schema fm
+func NewDynamicMessage(fm *ProtoFieldMap) *DynamicMessage {
+	return &DynamicMessage{fm: fm, wire: NewWireMessage()}
+}
+
+// Reset clears all fields of the message
+func (d *DynamicMessage) Reset() {
+	d.wire.Reset()
+}
+
+// Schema returns the ProtoFieldMap of the message
+func (d *DynamicMessage) Schema() *ProtoFieldMap {
+	return d.fm
+}
+
+// WireMessage returns the low-level WireMessage holding the fields.
+// Changes made to it are seen by the DynamicMessage.
+func (d *DynamicMessage) WireMessage() *WireMessage {
+	return d.wire
+}
+
+// field checks that field is part of the schema
+func (d *DynamicMessage) field(field FieldNum) error {
+	if _, ok := d.fm.field2type[field]; !ok {
+		return fmt.Errorf("%w: field %d of message %s", ErrUnknownField, field, d.fm.name)
+	}
+	return nil
+}
+
+// fieldByName looks up the number of the field called name
+func (d *DynamicMessage) fieldByName(name string) (FieldNum, error) {
+	field, ok := d.fm.GetFieldByName(name)
+	if !ok {
+		return 0, fmt.Errorf("%w: field %q of message %s", ErrUnknownField, name, d.fm.name)
+	}
+	return field, nil
+}
+
+// Has indicates if field is set. A oneof member that was overridden by
+// another member of its oneof is not set.
+func (d *DynamicMessage) Has(field FieldNum) bool {
+	if _, ok := d.wire.LastOccurrence(field); !ok {
+		return false
+	}
+	return d.fm.oneofWinner(d.wire, field)
+}
+
+// Get decodes the value of field.
+//
+// ErrUnknownField is returned if field is not part of the schema and
+// ErrMessageFieldMissing if it is not set.
+func (d *DynamicMessage) Get(field FieldNum) (interface{}, error) {
+	if err := d.field(field); err != nil {
+		return nil, err
+	}
+	if !d.Has(field) {
+		return nil, ErrMessageFieldMissing
+	}
+	return d.fm.decodeField(d.wire, field)
+}
+
+// GetByName decodes the value of the field called name. See Get.
+func (d *DynamicMessage) GetByName(name string) (interface{}, error) {
+	field, err := d.fieldByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.Get(field)
+}
+
+// GetMessage decodes the nested message held in field, which must have a
+// nested message schema
+func (d *DynamicMessage) GetMessage(field FieldNum) (*DynamicMessage, error) {
+	if err := d.field(field); err != nil {
+		return nil, err
+	}
+	sub, ok := d.fm.field2msg[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: field %d has no message schema", ErrInvalidProtoBufType, field)
+	}
+	if !d.Has(field) {
+		return nil, ErrMessageFieldMissing
+	}
+	wm, err := d.wire.DecodeMessage(field)
+	if err != nil {
+		return nil, err
+	}
+	return &DynamicMessage{fm: sub, wire: wm}, nil
+}
+
+// Set replaces the value of field with value, after checking it against the
+// schema. Setting a member of a oneof clears the other members.
+//
+// Nested messages may be given as a *DynamicMessage with the field's
+// message schema, or as a []FieldValue. On error, the message is unchanged.
+func (d *DynamicMessage) Set(field FieldNum, value interface{}) error {
+	if err := d.field(field); err != nil {
+		return err
+	}
+
+	// Encode separately, so that a bad value leaves the message untouched
+	scratch := NewWireMessage()
+	scratch.mode = d.wire.mode
+	if err := d.fm.encodeField(scratch, field, value); err != nil {
+		return fmt.Errorf("field %d: %w", field, err)
+	}
+
+	if name, ok := d.fm.field2oneof[field]; ok {
+		for _, member := range d.fm.oneofs[name] {
+			d.wire.Remove(member)
+		}
+	}
+	d.wire.Remove(field)
+	d.wire.copyField(scratch, field)
+	return nil
+}
+
+// SetByName sets the field called name. See Set.
+func (d *DynamicMessage) SetByName(name string, value interface{}) error {
+	field, err := d.fieldByName(name)
+	if err != nil {
+		return err
+	}
+	return d.Set(field, value)
+}
+
+// Clear removes field from the message
+func (d *DynamicMessage) Clear(field FieldNum) {
+	d.wire.Remove(field)
+}
+
+// Fields decodes all set fields, as ProtoFieldMap.DecodeMessage would
+func (d *DynamicMessage) Fields() ([]FieldValue, error) {
+	return d.fm.DecodeMessage(d.wire)
+}
+
+// Marshal encodes the message into a Protobuf buffer
+func (d *DynamicMessage) Marshal() ([]byte, error) {
+	return d.wire.Marshal()
+}
+
+// Unmarshal replaces the contents of the message with the Protobuf
+// buffer buf. Settings like the UTF-8 policy of the message are kept.
+func (d *DynamicMessage) Unmarshal(buf []byte) error {
+	// Unmarshal separately, so that a bad buffer leaves the message
+	// untouched, but keep the decoding mode of the current message
+	wm := NewWireMessage()
+	wm.mode = d.wire.mode
+	if err := wm.Unmarshal(buf); err != nil {
+		return err
+	}
+	*d.wire = *wm
+	return nil
+}
@@ -0,0 +1,172 @@
+package dproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func newDynamicTestMap(t *testing.T) *ProtoFieldMap {
+	inner := NewProtoFieldMap()
+	inner.SetName("test.Inner")
+	inner.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+	inner.SetFieldName(1, "label")
+
+	fm := NewProtoFieldMap()
+	fm.SetName("test.Outer")
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT64)
+	fm.SetFieldName(1, "id")
+	fm.AddMessage(2, inner)
+	fm.SetFieldName(2, "inner")
+	fm.Add(3, descriptor.FieldDescriptorProto_TYPE_SINT32)
+	fm.SetLabel(3, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	fm.Add(4, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.Add(5, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	if !fm.AddOneof("choice", 4, 5) {
+		t.Fatal("Failed to add oneof choice")
+	}
+	return fm
+}
+
+func TestDynamicMessageSetGet(t *testing.T) {
+	fm := newDynamicTestMap(t)
+	d := NewDynamicMessage(fm)
+
+	if err := d.Set(1, int64(42)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set(3, []int32{-1, 2, -3}); err != nil {
+		t.Fatal(err)
+	}
+	sub, _ := fm.GetMessage(2)
+	inner := NewDynamicMessage(sub)
+	if err := inner.SetByName("label", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetByName("inner", inner); err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Has(1) || d.Has(4) {
+		t.Error("Has reported the wrong fields")
+	}
+	if v, err := d.GetByName("id"); err != nil || v != int64(42) {
+		t.Errorf("Expected id 42, got %v (%v)", v, err)
+	}
+	if v, err := d.Get(3); err != nil || !reflect.DeepEqual(v, []interface{}{int32(-1), int32(2), int32(-3)}) {
+		t.Errorf("Unexpected repeated value %v (%v)", v, err)
+	}
+	got, err := d.GetMessage(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := got.GetByName("label"); err != nil || v != "hi" {
+		t.Errorf("Expected label hi, got %v (%v)", v, err)
+	}
+
+	d.Clear(1)
+	if _, err := d.Get(1); err != ErrMessageFieldMissing {
+		t.Errorf("Expected ErrMessageFieldMissing after Clear, got %v", err)
+	}
+}
+
+func TestDynamicMessageTypeCheck(t *testing.T) {
+	fm := newDynamicTestMap(t)
+	d := NewDynamicMessage(fm)
+	if err := d.Set(1, int64(7)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Set(1, "seven"); !errors.Is(err, ErrInvalidProtoBufType) {
+		t.Errorf("Expected ErrInvalidProtoBufType, got %v", err)
+	}
+	if v, _ := d.Get(1); v != int64(7) {
+		t.Errorf("Failed Set changed the value to %v", v)
+	}
+	if err := d.Set(2, NewDynamicMessage(fm)); !errors.Is(err, ErrInvalidProtoBufType) {
+		t.Errorf("Expected ErrInvalidProtoBufType for wrong message type, got %v", err)
+	}
+	if err := d.Set(9, int64(1)); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("Expected ErrUnknownField, got %v", err)
+	}
+	if _, err := d.GetByName("missing"); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("Expected ErrUnknownField, got %v", err)
+	}
+}
+
+func TestDynamicMessageOneof(t *testing.T) {
+	fm := newDynamicTestMap(t)
+	d := NewDynamicMessage(fm)
+
+	if err := d.Set(4, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set(5, true); err != nil {
+		t.Fatal(err)
+	}
+	if d.Has(4) || !d.Has(5) {
+		t.Error("Setting a oneof member did not clear the others")
+	}
+	if active, _ := fm.WhichOneof(d.WireMessage(), "choice"); active != 5 {
+		t.Errorf("Expected active member 5, got %d", active)
+	}
+}
+
+func TestDynamicMessageMarshal(t *testing.T) {
+	fm := newDynamicTestMap(t)
+	d := NewDynamicMessage(fm)
+	d.Set(1, int64(-5))
+	d.Set(4, "text")
+
+	buf, err := d.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("Expected 2 fields, got %v", values)
+	}
+
+	wm := d.WireMessage()
+	other := NewDynamicMessage(fm)
+	if err := other.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := other.Get(4); err != nil || v != "text" {
+		t.Errorf("Expected text, got %v (%v)", v, err)
+	}
+
+	// Unmarshal keeps the same WireMessage
+	if err := d.Unmarshal(nil); err != nil {
+		t.Fatal(err)
+	}
+	if wm != d.WireMessage() || wm.GetFieldCount() != 0 {
+		t.Error("Unmarshal did not replace the contents in place")
+	}
+}
+
+func TestDynamicMessageUnmarshalKeepsMode(t *testing.T) {
+	fm := newDynamicTestMap(t)
+	buf, err := fm.EncodeBuffer([]FieldValue{{Field: 2, Value: []FieldValue{{Field: 1, Value: "bad\xff"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDynamicMessage(fm)
+	d.WireMessage().SetUTF8Policy(UTF8Reject, nil)
+	d.WireMessage().SetCompatMode(true)
+	if err := d.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	if d.WireMessage().UTF8Policy() != UTF8Reject || !d.WireMessage().CompatMode() {
+		t.Error("Unmarshal reset the settings of the message")
+	}
+	if _, err := d.Get(2); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 from the nested message, got %v", err)
+	}
+}
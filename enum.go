@@ -0,0 +1,83 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the ProtoEnum, which associates Protobuf enum value names
+// with their numbers. It is to enums what the ProtoFieldMap is to messages.
+
+package dproto
+
+import "sort"
+
+// ProtoEnum associates the value names of a Protobuf enum with
+// their numbers.
+type ProtoEnum struct {
+	name        string
+	name2number map[string]int32
+	number2name map[int32]string
+	frozen      bool
+}
+
+// NewProtoEnum creates a new ProtoEnum object with the given
+// fully qualified name.
+func NewProtoEnum(name string) *ProtoEnum {
+	var e = new(ProtoEnum)
+	e.name = name
+	e.Reset()
+	return e
+}
+
+// Reset clears the stored values inside a ProtoEnum
+func (e *ProtoEnum) Reset() {
+	e.checkMutable()
+	e.name2number = make(map[string]int32)
+	e.number2name = make(map[int32]string)
+}
+
+// Name returns the fully qualified name of the enum
+func (e *ProtoEnum) Name() string {
+	return e.name
+}
+
+// Add adds a Name-Number association to a ProtoEnum.
+// Aliases are allowed, but the first name added for a number is the one
+// reported by GetName.
+// It returns false if name was already added.
+func (e *ProtoEnum) Add(name string, number int32) bool {
+	e.checkMutable()
+	if _, ok := e.name2number[name]; ok {
+		return false
+	}
+	e.name2number[name] = number
+	if _, ok := e.number2name[number]; !ok {
+		e.number2name[number] = name
+	}
+	return true
+}
+
+// GetName gets the value name associated with number
+func (e *ProtoEnum) GetName(number int32) (string, bool) {
+	name, ok := e.number2name[number]
+	return name, ok
+}
+
+// GetNumber gets the number associated with the value name
+func (e *ProtoEnum) GetNumber(name string) (int32, bool) {
+	number, ok := e.name2number[name]
+	return number, ok
+}
+
+// Names returns all value names of the enum, ordered by number
+func (e *ProtoEnum) Names() []string {
+	names := make([]string, 0, len(e.name2number))
+	for name := range e.name2number {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ni, nj := e.name2number[names[i]], e.name2number[names[j]]
+		if ni != nj {
+			return ni < nj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -2,6 +2,7 @@ package dproto_test
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/linux4life798/dproto"
@@ -182,3 +183,23 @@ func ExampleNewProtoFieldMap() {
 	// 1: true
 	// 2: 10
 }
+
+func ExampleProtoFieldMap_WriteProto() {
+	fm := dproto.NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	fm.SetFieldName(1, "status")
+	fm.Add(2, descriptor.FieldDescriptorProto_TYPE_INT64)
+	fm.SetFieldName(2, "intensity")
+	fm.AddMap(3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_UINT32)
+	fm.SetFieldName(3, "zones")
+
+	if err := fm.WriteProto(os.Stdout, "LightStatus"); err != nil {
+		panic("Error Writing: " + err.Error())
+	}
+	// Output:
+	// message LightStatus {
+	// 	optional bool status = 1;
+	// 	optional int64 intensity = 2;
+	// 	map<string, uint32> zones = 3;
+	// }
+}
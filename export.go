@@ -0,0 +1,338 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file turns a ProtoFieldMap back into a message descriptor or
+// .proto source, so that the schema used by a dynamic service can be
+// handed to peers that generate code, such as C and nanopb clients.
+
+package dproto
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ToDescriptor creates a message descriptor that describes the ProtoFieldMap.
+//
+// The descriptor is named after the last component of the message name,
+// if one was set. Fields without a name are called "field_N".
+// Nested message and enum schemas that are unnamed, or named as children of
+// this message, become nested types. Others are referenced by their fully
+// qualified names. Map fields get a nested map entry type, just like protoc
+// would synthesize. Message and enum fields without a schema are described
+// as bytes and int32 fields, which are identical on the wire.
+func (fm *ProtoFieldMap) ToDescriptor() *descriptor.DescriptorProto {
+	b := &descriptorBuilder{scopes: make(map[*ProtoFieldMap]string)}
+	return b.message(fm, simpleName(fm.name))
+}
+
+// WriteProto writes a .proto definition of the ProtoFieldMap, as a message
+// called messageName, to w. Nested types are written inside the message and
+// other referenced types are written as fully qualified names.
+// Fields use the proto2 labels optional, required and repeated.
+func (fm *ProtoFieldMap) WriteProto(w io.Writer, messageName string) error {
+	b := &descriptorBuilder{scopes: make(map[*ProtoFieldMap]string)}
+	msg := b.message(fm, messageName)
+
+	bw := bufio.NewWriter(w)
+	writeMessage(bw, msg, 0)
+	return bw.Flush()
+}
+
+// descriptorBuilder builds nested message descriptors, keeping track of
+// which ProtoFieldMaps are being built so that recursive references to them
+// are named rather than expanded forever
+type descriptorBuilder struct {
+	scopes map[*ProtoFieldMap]string
+}
+
+// message builds the descriptor for fm under the given name
+func (b *descriptorBuilder) message(fm *ProtoFieldMap, name string) *descriptor.DescriptorProto {
+	msg := new(descriptor.DescriptorProto)
+	if name != "" {
+		msg.Name = proto.String(name)
+	}
+	b.scopes[fm] = name
+	defer delete(b.scopes, fm)
+
+	// Nested types already added, so fields sharing a schema share a type
+	nestedMsgs := make(map[*ProtoFieldMap]string)
+	nestedEnums := make(map[*ProtoEnum]string)
+
+	// msgType returns the type name to use for sub, nesting it if needed
+	msgType := func(sub *ProtoFieldMap, fallback string) string {
+		if name, ok := nestedMsgs[sub]; ok {
+			return name
+		}
+		if sub.name != "" && !isChildName(fm.name, sub.name) {
+			return "." + sub.name
+		}
+		if name, ok := b.scopes[sub]; ok {
+			return name
+		}
+		nested := fallback
+		if sub.name != "" {
+			nested = simpleName(sub.name)
+		}
+		nestedMsgs[sub] = nested
+		msg.NestedType = append(msg.NestedType, b.message(sub, nested))
+		return nested
+	}
+	// enumType returns the type name to use for e, nesting it if needed
+	enumType := func(e *ProtoEnum, fallback string) string {
+		if name, ok := nestedEnums[e]; ok {
+			return name
+		}
+		if e.name != "" && !isChildName(fm.name, e.name) {
+			return "." + e.name
+		}
+		nested := fallback
+		if e.name != "" {
+			nested = simpleName(e.name)
+		}
+		nestedEnums[e] = nested
+		msg.EnumType = append(msg.EnumType, e.toDescriptor(nested))
+		return nested
+	}
+
+	oneofs := make(map[string]int32)
+	for _, field := range fm.GetFieldNums() {
+		fieldName := fm.descriptorFieldName(field)
+		f := &descriptor.FieldDescriptorProto{
+			Name:   proto.String(fieldName),
+			Number: proto.Int32(int32(field)),
+			Label:  fm.GetLabel(field).Enum(),
+			Type:   fm.field2type[field].Enum(),
+		}
+		if fm.IsPacked(field) {
+			f.Options = &descriptor.FieldOptions{Packed: proto.Bool(true)}
+		}
+
+		if entry, isMap := fm.maps[field]; isMap {
+			entryName := camelCase(fieldName) + "Entry"
+			e := &descriptor.DescriptorProto{
+				Name:    proto.String(entryName),
+				Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+				Field: []*descriptor.FieldDescriptorProto{
+					entryField("key", mapEntryKeyField, entry.key),
+					entryField("value", mapEntryValueField, entry.value),
+				},
+			}
+			if entry.valueMsg != nil {
+				e.Field[1].TypeName = proto.String(msgType(entry.valueMsg, camelCase(fieldName)+"Value"))
+			} else if entry.valueEnum != nil {
+				e.Field[1].TypeName = proto.String(enumType(entry.valueEnum, camelCase(fieldName)+"Value"))
+			}
+			msg.NestedType = append(msg.NestedType, e)
+			f.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			f.TypeName = proto.String(entryName)
+		} else if sub, ok := fm.field2msg[field]; ok {
+			f.TypeName = proto.String(msgType(sub, camelCase(fieldName)))
+		} else if e, ok := fm.field2enum[field]; ok {
+			f.TypeName = proto.String(enumType(e, camelCase(fieldName)))
+		} else if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			// Without a schema, the closest wire compatible type is bytes
+			f.Type = descriptor.FieldDescriptorProto_TYPE_BYTES.Enum()
+		} else if f.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM {
+			f.Type = descriptor.FieldDescriptorProto_TYPE_INT32.Enum()
+		}
+
+		if oneof, ok := fm.field2oneof[field]; ok {
+			index, declared := oneofs[oneof]
+			if !declared {
+				index = int32(len(msg.OneofDecl))
+				oneofs[oneof] = index
+				msg.OneofDecl = append(msg.OneofDecl, &descriptor.OneofDescriptorProto{Name: proto.String(oneof)})
+			}
+			f.OneofIndex = proto.Int32(index)
+		}
+		msg.Field = append(msg.Field, f)
+	}
+
+	for _, r := range fm.extRanges {
+		msg.ExtensionRange = append(msg.ExtensionRange, &descriptor.DescriptorProto_ExtensionRange{
+			Start: proto.Int32(int32(r.Start)),
+			End:   proto.Int32(int32(r.End) + 1),
+		})
+	}
+	return msg
+}
+
+// toDescriptor creates an enum descriptor for e under the given name
+func (e *ProtoEnum) toDescriptor(name string) *descriptor.EnumDescriptorProto {
+	ed := &descriptor.EnumDescriptorProto{Name: proto.String(name)}
+	aliased := false
+	for _, value := range e.Names() {
+		number := e.name2number[value]
+		if first, _ := e.GetName(number); first != value {
+			aliased = true
+		}
+		ed.Value = append(ed.Value, &descriptor.EnumValueDescriptorProto{
+			Name:   proto.String(value),
+			Number: proto.Int32(number),
+		})
+	}
+	if aliased {
+		ed.Options = &descriptor.EnumOptions{AllowAlias: proto.Bool(true)}
+	}
+	return ed
+}
+
+// entryField creates a field descriptor for the key or value of a map entry
+func entryField(name string, field FieldNum, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(int32(field)),
+		Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   typ.Enum(),
+	}
+}
+
+// descriptorFieldName returns the name of field, or a generated one
+func (fm *ProtoFieldMap) descriptorFieldName(field FieldNum) string {
+	if name, ok := fm.field2name[field]; ok {
+		return name
+	}
+	return fmt.Sprintf("field_%d", field)
+}
+
+// simpleName returns the last component of a fully qualified name
+func simpleName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// isChildName indicates if name is declared directly inside parent
+func isChildName(parent, name string) bool {
+	return parent != "" && strings.HasPrefix(name, parent+".") &&
+		!strings.Contains(name[len(parent)+1:], ".")
+}
+
+// camelCase converts a field name like "my_field" into a type name
+// like "MyField"
+func camelCase(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && c >= 'a' && c <= 'z':
+			sb.WriteRune(c - 'a' + 'A')
+			upper = false
+		default:
+			sb.WriteRune(c)
+			upper = false
+		}
+	}
+	return sb.String()
+}
+
+// protoTypeName returns the .proto keyword of a scalar Protobuf type
+func protoTypeName(typ descriptor.FieldDescriptorProto_Type) string {
+	for name, t := range typeName2ProtoType {
+		if t == typ {
+			return name
+		}
+	}
+	return ""
+}
+
+// writeMessage writes msg as .proto source, indented by depth levels
+func writeMessage(w *bufio.Writer, msg *descriptor.DescriptorProto, depth int) {
+	indent := strings.Repeat("\t", depth)
+	fmt.Fprintf(w, "%smessage %s {\n", indent, msg.GetName())
+
+	entries := make(map[string]*descriptor.DescriptorProto)
+	for _, nested := range msg.NestedType {
+		if nested.GetOptions().GetMapEntry() {
+			entries[nested.GetName()] = nested
+		}
+	}
+
+	// Oneof members are written together, where the first one appears
+	written := make(map[int32]bool)
+	for _, f := range msg.Field {
+		if f.OneofIndex == nil {
+			writeField(w, f, entries, depth+1, true)
+			continue
+		}
+		index := f.GetOneofIndex()
+		if written[index] {
+			continue
+		}
+		written[index] = true
+		fmt.Fprintf(w, "%s\toneof %s {\n", indent, msg.OneofDecl[index].GetName())
+		for _, member := range msg.Field {
+			if member.OneofIndex != nil && member.GetOneofIndex() == index {
+				writeField(w, member, entries, depth+2, false)
+			}
+		}
+		fmt.Fprintf(w, "%s\t}\n", indent)
+	}
+	for _, r := range msg.ExtensionRange {
+		if end := r.GetEnd() - 1; end == int32(maxFieldNum) {
+			fmt.Fprintf(w, "%s\textensions %d to max;\n", indent, r.GetStart())
+		} else if end == r.GetStart() {
+			fmt.Fprintf(w, "%s\textensions %d;\n", indent, r.GetStart())
+		} else {
+			fmt.Fprintf(w, "%s\textensions %d to %d;\n", indent, r.GetStart(), end)
+		}
+	}
+
+	for _, e := range msg.EnumType {
+		fmt.Fprintln(w)
+		writeEnum(w, e, depth+1)
+	}
+	for _, nested := range msg.NestedType {
+		if !nested.GetOptions().GetMapEntry() {
+			fmt.Fprintln(w)
+			writeMessage(w, nested, depth+1)
+		}
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// writeField writes a single field declaration
+func writeField(w *bufio.Writer, f *descriptor.FieldDescriptorProto, entries map[string]*descriptor.DescriptorProto, depth int, labeled bool) {
+	indent := strings.Repeat("\t", depth)
+	if entry, isMap := entries[f.GetTypeName()]; isMap {
+		fmt.Fprintf(w, "%smap<%s, %s> %s = %d;\n", indent,
+			fieldTypeName(entry.Field[0]), fieldTypeName(entry.Field[1]), f.GetName(), f.GetNumber())
+		return
+	}
+	label := ""
+	if labeled {
+		label = labelString(f.GetLabel()) + " "
+	}
+	options := ""
+	if f.GetOptions().GetPacked() {
+		options = " [packed = true]"
+	}
+	fmt.Fprintf(w, "%s%s%s %s = %d%s;\n", indent, label, fieldTypeName(f), f.GetName(), f.GetNumber(), options)
+}
+
+// fieldTypeName returns the type of f as written in .proto source
+func fieldTypeName(f *descriptor.FieldDescriptorProto) string {
+	if f.TypeName != nil {
+		return f.GetTypeName()
+	}
+	return protoTypeName(f.GetType())
+}
+
+// writeEnum writes e as .proto source, indented by depth levels
+func writeEnum(w *bufio.Writer, e *descriptor.EnumDescriptorProto, depth int) {
+	indent := strings.Repeat("\t", depth)
+	fmt.Fprintf(w, "%senum %s {\n", indent, e.GetName())
+	if e.GetOptions().GetAllowAlias() {
+		fmt.Fprintf(w, "%s\toption allow_alias = true;\n", indent)
+	}
+	for _, v := range e.Value {
+		fmt.Fprintf(w, "%s\t%s = %d;\n", indent, v.GetName(), v.GetNumber())
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
@@ -0,0 +1,93 @@
+package dproto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestToDescriptorRoundTrip(t *testing.T) {
+	color := NewProtoEnum("")
+	color.Add("RED", 0)
+	color.Add("GREEN", 1)
+
+	point := NewProtoFieldMap()
+	point.Add(1, descriptor.FieldDescriptorProto_TYPE_SINT32)
+	point.Add(2, descriptor.FieldDescriptorProto_TYPE_SINT32)
+
+	fm := NewProtoFieldMap()
+	fm.SetName("shapes.Polygon")
+	fm.AddMessage(1, point)
+	fm.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	fm.SetFieldName(1, "points")
+	fm.AddEnum(2, color)
+	fm.SetFieldName(2, "fill_color")
+	fm.AddMap(3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_DOUBLE)
+	fm.SetFieldName(3, "tags")
+	fm.Add(4, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.Add(5, descriptor.FieldDescriptorProto_TYPE_BYTES)
+	fm.AddOneof("label", 4, 5)
+
+	desc := fm.ToDescriptor()
+	if desc.GetName() != "Polygon" {
+		t.Errorf("Expected name Polygon, got %s", desc.GetName())
+	}
+	if len(desc.NestedType) != 2 || len(desc.EnumType) != 1 || len(desc.OneofDecl) != 1 {
+		t.Fatalf("Unexpected nested types in %v", desc)
+	}
+	if desc.Field[0].GetTypeName() != "Points" || desc.Field[1].GetTypeName() != "FillColor" {
+		t.Errorf("Unexpected type names %s and %s", desc.Field[0].GetTypeName(), desc.Field[1].GetTypeName())
+	}
+	if desc.Field[3].GetName() != "field_4" {
+		t.Errorf("Expected generated name field_4, got %s", desc.Field[3].GetName())
+	}
+
+	loaded, err := NewProtoFieldMapFromDescriptor(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []FieldValue{
+		{Field: 1, Value: []interface{}{
+			[]FieldValue{{Field: 1, Value: int32(-1)}, {Field: 2, Value: int32(2)}},
+		}},
+		{Field: 2, Value: "GREEN"},
+		{Field: 3, Value: map[string]float64{"area": 1.5}},
+		{Field: 5, Value: []byte{0xAA}},
+	}
+	want, err := fm.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+	got, err := loaded.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("Loaded descriptor encodes differently:\n%x\n%x", want, got)
+	}
+	if fields, ok := loaded.GetOneof("label"); !ok || len(fields) != 2 {
+		t.Error("Oneof was not preserved")
+	}
+}
+
+func TestToDescriptorReferences(t *testing.T) {
+	node := NewProtoFieldMap()
+	node.SetName("tree.Node")
+	node.AddMessage(1, node)
+	node.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	node.Add(2, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	node.Add(3, descriptor.FieldDescriptorProto_TYPE_ENUM)
+
+	desc := node.ToDescriptor()
+	if len(desc.NestedType) != 0 {
+		t.Error("Recursive reference should not be nested")
+	}
+	if desc.Field[0].GetTypeName() != ".tree.Node" {
+		t.Errorf("Expected .tree.Node, got %s", desc.Field[0].GetTypeName())
+	}
+	if desc.Field[1].GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES ||
+		desc.Field[2].GetType() != descriptor.FieldDescriptorProto_TYPE_INT32 {
+		t.Error("Schemaless message and enum fields should become bytes and int32")
+	}
+}
@@ -0,0 +1,210 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file adds support for proto2 extensions. A ProtoFieldMap declares
+// which field numbers are reserved for extensions, and an ExtensionRegistry
+// holds the extension fields defined elsewhere. The two are combined with
+// WithExtensions right before decoding or encoding.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ErrInvalidExtension is returned when an extension does not fit the
+// message it extends
+var ErrInvalidExtension = errors.New("Invalid extension")
+
+// maxFieldNum is the largest field number Protobuf allows
+const maxFieldNum FieldNum = 1<<29 - 1
+
+// ExtensionRange is a range of field numbers reserved for extensions.
+// Like "extensions 100 to 199;" in a .proto file, End is included.
+type ExtensionRange struct {
+	Start FieldNum
+	End   FieldNum
+}
+
+// AddExtensionRange reserves the field numbers start through end,
+// inclusive, for extensions.
+// It returns false if the range is empty, invalid or overlaps another
+// extension range.
+func (fm *ProtoFieldMap) AddExtensionRange(start, end FieldNum) bool {
+	fm.checkMutable()
+	if start < 1 || start > end || end > maxFieldNum {
+		return false
+	}
+	for _, r := range fm.extRanges {
+		if start <= r.End && r.Start <= end {
+			return false
+		}
+	}
+	fm.extRanges = append(fm.extRanges, ExtensionRange{start, end})
+	sort.Slice(fm.extRanges, func(i, j int) bool { return fm.extRanges[i].Start < fm.extRanges[j].Start })
+	return true
+}
+
+// ExtensionRanges returns the extension ranges of the message, in order
+func (fm *ProtoFieldMap) ExtensionRanges() []ExtensionRange {
+	return append([]ExtensionRange(nil), fm.extRanges...)
+}
+
+// InExtensionRange indicates if field is reserved for extensions
+func (fm *ProtoFieldMap) InExtensionRange(field FieldNum) bool {
+	for _, r := range fm.extRanges {
+		if r.Start <= field && field <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Extension describes an extension field of the message named Extendee.
+// Name is the fully qualified name of the extension, like
+// "mypackage.priority". Message or Enum may hold the schema of message and
+// enum extensions. A zero Label means optional.
+type Extension struct {
+	Extendee string
+	Field    FieldNum
+	Name     string
+	Type     descriptor.FieldDescriptorProto_Type
+	Label    descriptor.FieldDescriptorProto_Label
+	Message  *ProtoFieldMap
+	Enum     *ProtoEnum
+}
+
+// ExtensionRegistry holds extensions by the name of the message they extend
+type ExtensionRegistry struct {
+	exts map[string]map[FieldNum]Extension
+}
+
+// NewExtensionRegistry creates a new empty ExtensionRegistry object.
+func NewExtensionRegistry() *ExtensionRegistry {
+	var r = new(ExtensionRegistry)
+	r.Reset()
+	return r
+}
+
+// Reset clears all extensions stored in the ExtensionRegistry
+func (r *ExtensionRegistry) Reset() {
+	r.exts = make(map[string]map[FieldNum]Extension)
+}
+
+// Add adds the extension ext. It returns false if ext has no extendee,
+// field number or valid type, or if that field of the extendee is already
+// registered.
+func (r *ExtensionRegistry) Add(ext Extension) bool {
+	ext.Extendee = normalizeTypeName(ext.Extendee)
+	if ext.Extendee == "" || ext.Field < 1 || ext.Field > maxFieldNum {
+		return false
+	}
+	if _, ok := protoType2WireType[ext.Type]; !ok {
+		return false
+	}
+	if _, ok := r.exts[ext.Extendee][ext.Field]; ok {
+		return false
+	}
+	if r.exts[ext.Extendee] == nil {
+		r.exts[ext.Extendee] = make(map[FieldNum]Extension)
+	}
+	r.exts[ext.Extendee][ext.Field] = ext
+	return true
+}
+
+// Get gets the extension field of the message named extendee
+func (r *ExtensionRegistry) Get(extendee string, field FieldNum) (Extension, bool) {
+	ext, ok := r.exts[normalizeTypeName(extendee)][field]
+	return ext, ok
+}
+
+// Extensions returns all extensions of the message named extendee, in
+// field number order
+func (r *ExtensionRegistry) Extensions(extendee string) []Extension {
+	fields := r.exts[normalizeTypeName(extendee)]
+	exts := make([]Extension, 0, len(fields))
+	for _, ext := range fields {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool { return exts[i].Field < exts[j].Field })
+	return exts
+}
+
+// WithExtensions returns a frozen copy of the ProtoFieldMap that also holds
+// the extensions registered in exts for its message name. Decoding with the
+// copy reports extensions alongside the regular fields, and encoding with
+// it emits them. Extension fields are named after the extension.
+//
+// ErrInvalidExtension is returned if an extension is outside the extension
+// ranges, or clashes with a regular field.
+func (fm *ProtoFieldMap) WithExtensions(exts *ExtensionRegistry) (*ProtoFieldMap, error) {
+	c := fm.clone()
+	for _, ext := range exts.Extensions(fm.name) {
+		if !fm.InExtensionRange(ext.Field) {
+			return nil, fmt.Errorf("%w: field %d of %s is not in an extension range", ErrInvalidExtension, ext.Field, fm.name)
+		}
+		if _, ok := fm.field2type[ext.Field]; ok {
+			return nil, fmt.Errorf("%w: field %d of %s is a regular field", ErrInvalidExtension, ext.Field, fm.name)
+		}
+
+		switch {
+		case ext.Message != nil:
+			c.AddMessage(ext.Field, ext.Message)
+		case ext.Enum != nil:
+			c.AddEnum(ext.Field, ext.Enum)
+		default:
+			c.Add(ext.Field, ext.Type)
+		}
+		if ext.Label != 0 {
+			c.SetLabel(ext.Field, ext.Label)
+		}
+		if ext.Name != "" && !c.SetFieldName(ext.Field, ext.Name) {
+			return nil, fmt.Errorf("%w: name %s is already used by %s", ErrInvalidExtension, ext.Name, fm.name)
+		}
+	}
+	c.frozen = true
+	return c, nil
+}
+
+// clone makes a shallow copy of the ProtoFieldMap, which shares the nested
+// message schemas and enums
+func (fm *ProtoFieldMap) clone() *ProtoFieldMap {
+	c := NewProtoFieldMap()
+	c.name = fm.name
+	for field, typ := range fm.field2type {
+		c.field2type[field] = typ
+	}
+	for field, name := range fm.field2name {
+		c.field2name[field] = name
+	}
+	for name, field := range fm.name2field {
+		c.name2field[name] = field
+	}
+	for field, label := range fm.field2label {
+		c.field2label[field] = label
+	}
+	for field := range fm.packed {
+		c.packed[field] = true
+	}
+	for field, sub := range fm.field2msg {
+		c.field2msg[field] = sub
+	}
+	for field, e := range fm.field2enum {
+		c.field2enum[field] = e
+	}
+	for field, entry := range fm.maps {
+		c.maps[field] = entry
+	}
+	for name, fields := range fm.oneofs {
+		c.oneofs[name] = append([]FieldNum(nil), fields...)
+	}
+	for field, name := range fm.field2oneof {
+		c.field2oneof[field] = name
+	}
+	c.extRanges = fm.ExtensionRanges()
+	return c
+}
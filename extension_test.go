@@ -0,0 +1,194 @@
+package dproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func newExtensionTestMap() *ProtoFieldMap {
+	fm := NewProtoFieldMap()
+	fm.SetName("legacy.Request")
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.SetFieldName(1, "path")
+	fm.AddExtensionRange(100, 199)
+	fm.AddExtensionRange(1000, maxFieldNum)
+	return fm
+}
+
+func TestExtensionRanges(t *testing.T) {
+	fm := newExtensionTestMap()
+	tests := []struct {
+		start, end FieldNum
+		ok         bool
+	}{
+		{200, 299, true},
+		{150, 250, false},
+		{0, 10, false},
+		{20, 10, false},
+		{10, maxFieldNum + 1, false},
+	}
+	for i, test := range tests {
+		if ok := fm.AddExtensionRange(test.start, test.end); ok != test.ok {
+			t.Errorf("Test %d: expected %v, got %v", i, test.ok, ok)
+		}
+	}
+
+	expected := []ExtensionRange{{100, 199}, {200, 299}, {1000, maxFieldNum}}
+	if ranges := fm.ExtensionRanges(); !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Expected %v, got %v", expected, ranges)
+	}
+	if !fm.InExtensionRange(199) || fm.InExtensionRange(300) {
+		t.Error("Wrong extension range membership")
+	}
+	if ranges := fm.Freeze().ExtensionRanges(); !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Freeze lost the extension ranges: %v", ranges)
+	}
+}
+
+func TestExtensionRegistry(t *testing.T) {
+	r := NewExtensionRegistry()
+	ext := Extension{Extendee: ".legacy.Request", Field: 101, Name: "legacy.priority", Type: descriptor.FieldDescriptorProto_TYPE_SINT32}
+	if !r.Add(ext) {
+		t.Fatal("Failed to add extension")
+	}
+	if r.Add(ext) {
+		t.Error("Added the same extension twice")
+	}
+	if r.Add(Extension{Extendee: "legacy.Request", Field: 102}) {
+		t.Error("Added an extension without a type")
+	}
+	if got, ok := r.Get("legacy.Request", 101); !ok || got.Name != "legacy.priority" {
+		t.Errorf("Unexpected extension %v", got)
+	}
+	if exts := r.Extensions("legacy.Other"); len(exts) != 0 {
+		t.Errorf("Unexpected extensions %v", exts)
+	}
+}
+
+func TestWithExtensions(t *testing.T) {
+	fm := newExtensionTestMap()
+	tags := NewProtoFieldMap()
+	tags.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+
+	r := NewExtensionRegistry()
+	r.Add(Extension{Extendee: "legacy.Request", Field: 101, Name: "legacy.priority", Type: descriptor.FieldDescriptorProto_TYPE_SINT32})
+	r.Add(Extension{Extendee: "legacy.Request", Field: 1000, Name: "legacy.tags",
+		Type: descriptor.FieldDescriptorProto_TYPE_MESSAGE, Label: descriptor.FieldDescriptorProto_LABEL_REPEATED, Message: tags})
+
+	ext, err := fm.WithExtensions(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ext.Frozen() {
+		t.Error("Expected a frozen copy")
+	}
+	if _, ok := fm.Get(101); ok {
+		t.Error("The original ProtoFieldMap was modified")
+	}
+	if field, ok := ext.GetFieldByName("legacy.priority"); !ok || field != 101 {
+		t.Errorf("Extension not named, got %v", field)
+	}
+
+	values := []FieldValue{
+		{1, "/"},
+		{101, int32(-2)},
+		{1000, []interface{}{[]FieldValue{{1, "a"}}}},
+	}
+	buf, err := ext.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ext.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("Expected %v, got %v", values, decoded)
+	}
+
+	// Without the registry, extensions are unknown fields
+	result := fm.DecodeBufferResult(buf, UnknownFieldsCollect)
+	if len(result.Values) != 1 || len(result.Unknown) != 2 {
+		t.Errorf("Expected 1 value and 2 unknown fields, got %v and %v", result.Values, result.Unknown)
+	}
+}
+
+func TestWithExtensionsInvalid(t *testing.T) {
+	fm := newExtensionTestMap()
+	tests := []Extension{
+		{Extendee: "legacy.Request", Field: 50, Type: descriptor.FieldDescriptorProto_TYPE_BOOL},
+		{Extendee: "legacy.Request", Field: 100, Name: "path", Type: descriptor.FieldDescriptorProto_TYPE_BOOL},
+	}
+	for i, ext := range tests {
+		r := NewExtensionRegistry()
+		r.Add(ext)
+		if _, err := fm.WithExtensions(r); !errors.Is(err, ErrInvalidExtension) {
+			t.Errorf("Test %d: expected ErrInvalidExtension, got %v", i, err)
+		}
+	}
+}
+
+func TestExtensionRangeDescriptor(t *testing.T) {
+	fm, err := NewProtoFieldMapFromDescriptor(&descriptor.DescriptorProto{
+		Name: proto.String("Request"),
+		ExtensionRange: []*descriptor.DescriptorProto_ExtensionRange{
+			{Start: proto.Int32(100), End: proto.Int32(200)},
+			{Start: proto.Int32(500), End: proto.Int32(501)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ExtensionRange{{100, 199}, {500, 500}}
+	if ranges := fm.ExtensionRanges(); !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Expected %v, got %v", expected, ranges)
+	}
+
+	if d := fm.ToDescriptor(); len(d.ExtensionRange) != 2 || d.ExtensionRange[0].GetEnd() != 200 {
+		t.Errorf("Unexpected extension ranges %v", d.ExtensionRange)
+	}
+	var buf bytes.Buffer
+	if err := newExtensionTestMap().WriteProto(&buf, "Request"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"extensions 100 to 199;", "extensions 1000 to max;"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Missing %q in:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestExtensionRangeSchema(t *testing.T) {
+	data, err := json.Marshal(newExtensionTestMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"extension_ranges":[{"start":100,"end":199},{"start":1000,"end":536870911}]`) {
+		t.Errorf("Extension ranges missing from %s", data)
+	}
+
+	fm := NewProtoFieldMap()
+	if err := json.Unmarshal(data, fm); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fm.ExtensionRanges(), newExtensionTestMap().ExtensionRanges()) {
+		t.Errorf("Unexpected ranges %v", fm.ExtensionRanges())
+	}
+	r := NewExtensionRegistry()
+	r.Add(Extension{Extendee: "legacy.Request", Field: 101, Name: "legacy.priority", Type: descriptor.FieldDescriptorProto_TYPE_SINT32})
+	if _, err := fm.WithExtensions(r); err != nil {
+		t.Errorf("Extensions of a loaded schema: %v", err)
+	}
+
+	invalid := `{"name": "a.B", "fields": [], "extension_ranges": [{"start": 10, "end": 5}]}`
+	if err := json.Unmarshal([]byte(invalid), NewProtoFieldMap()); err == nil {
+		t.Error("Expected an error for an invalid extension range")
+	}
+}
@@ -0,0 +1,142 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds Freeze, which makes immutable snapshots of a
+// ProtoFieldMap that can be shared between goroutines.
+//
+// The concurrency model is simple. Decoding and encoding only read the
+// ProtoFieldMap, so any number of goroutines may do so at once. Modifying
+// a ProtoFieldMap while it is being read is a data race. Instead, modify a
+// private ProtoFieldMap and publish a frozen snapshot of it, for example
+// through an atomic.Value. Readers holding an older snapshot are unaffected.
+
+package dproto
+
+// Freeze returns an immutable deep copy of the ProtoFieldMap, including its
+// nested message schemas and enums. Any attempt to modify the copy panics.
+// The original is left unfrozen and may be modified and frozen again.
+//
+// Freezing an already frozen ProtoFieldMap returns it unchanged.
+func (fm *ProtoFieldMap) Freeze() *ProtoFieldMap {
+	if fm.frozen {
+		return fm
+	}
+	f := &freezer{
+		messages: make(map[*ProtoFieldMap]*ProtoFieldMap),
+		enums:    make(map[*ProtoEnum]*ProtoEnum),
+	}
+	return f.message(fm)
+}
+
+// Frozen indicates if the ProtoFieldMap was made by Freeze
+func (fm *ProtoFieldMap) Frozen() bool {
+	return fm.frozen
+}
+
+// checkMutable panics if the ProtoFieldMap is frozen
+func (fm *ProtoFieldMap) checkMutable() {
+	if fm.frozen {
+		panic("dproto: modification of frozen ProtoFieldMap " + fm.name)
+	}
+}
+
+// Freeze returns an immutable copy of the ProtoEnum.
+// Any attempt to modify the copy panics.
+func (e *ProtoEnum) Freeze() *ProtoEnum {
+	if e.frozen {
+		return e
+	}
+	f := &freezer{enums: make(map[*ProtoEnum]*ProtoEnum)}
+	return f.enum(e)
+}
+
+// Frozen indicates if the ProtoEnum was made by Freeze
+func (e *ProtoEnum) Frozen() bool {
+	return e.frozen
+}
+
+// checkMutable panics if the ProtoEnum is frozen
+func (e *ProtoEnum) checkMutable() {
+	if e.frozen {
+		panic("dproto: modification of frozen ProtoEnum " + e.name)
+	}
+}
+
+// freezer copies a graph of schemas, so that a schema referenced from
+// several places, or from itself, is copied only once
+type freezer struct {
+	messages map[*ProtoFieldMap]*ProtoFieldMap
+	enums    map[*ProtoEnum]*ProtoEnum
+}
+
+func (f *freezer) message(fm *ProtoFieldMap) *ProtoFieldMap {
+	if fm.frozen {
+		return fm
+	}
+	if c, ok := f.messages[fm]; ok {
+		return c
+	}
+	c := NewProtoFieldMap()
+	f.messages[fm] = c
+	c.name = fm.name
+
+	for field, typ := range fm.field2type {
+		c.field2type[field] = typ
+	}
+	for field, name := range fm.field2name {
+		c.field2name[field] = name
+	}
+	for name, field := range fm.name2field {
+		c.name2field[name] = field
+	}
+	for field, label := range fm.field2label {
+		c.field2label[field] = label
+	}
+	for field := range fm.packed {
+		c.packed[field] = true
+	}
+	for field, sub := range fm.field2msg {
+		c.field2msg[field] = f.message(sub)
+	}
+	for field, e := range fm.field2enum {
+		c.field2enum[field] = f.enum(e)
+	}
+	for field, entry := range fm.maps {
+		if entry.valueMsg != nil {
+			entry.valueMsg = f.message(entry.valueMsg)
+		}
+		if entry.valueEnum != nil {
+			entry.valueEnum = f.enum(entry.valueEnum)
+		}
+		c.maps[field] = entry
+	}
+	for name, fields := range fm.oneofs {
+		c.oneofs[name] = append([]FieldNum(nil), fields...)
+	}
+	for field, name := range fm.field2oneof {
+		c.field2oneof[field] = name
+	}
+	c.extRanges = fm.ExtensionRanges()
+
+	c.frozen = true
+	return c
+}
+
+func (f *freezer) enum(e *ProtoEnum) *ProtoEnum {
+	if e.frozen {
+		return e
+	}
+	if c, ok := f.enums[e]; ok {
+		return c
+	}
+	c := NewProtoEnum(e.name)
+	for name, number := range e.name2number {
+		c.name2number[name] = number
+	}
+	for number, name := range e.number2name {
+		c.number2name[number] = name
+	}
+	c.frozen = true
+	f.enums[e] = c
+	return c
+}
@@ -0,0 +1,134 @@
+package dproto
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestFreezeIsIndependent(t *testing.T) {
+	color := NewProtoEnum("Color")
+	color.Add("RED", 0)
+
+	fm := NewProtoFieldMap()
+	fm.SetName("Node")
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT32)
+	fm.SetFieldName(1, "id")
+	fm.AddMessage(2, fm)
+	fm.AddEnum(3, color)
+	fm.Add(4, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.Add(5, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	fm.AddOneof("choice", 4, 5)
+
+	frozen := fm.Freeze()
+	if !frozen.Frozen() || fm.Frozen() {
+		t.Fatal("Only the copy should be frozen")
+	}
+	if frozen.Freeze() != frozen {
+		t.Error("Freezing a frozen map should return it unchanged")
+	}
+	if sub, _ := frozen.GetMessage(2); sub != frozen {
+		t.Error("Recursive reference should point at the frozen copy")
+	}
+
+	// Changing the original must not affect the snapshot
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.SetFieldName(1, "name")
+	color.Add("GREEN", 1)
+	fm.RemoveOneof("choice")
+
+	if typ, _ := frozen.Get(1); typ != descriptor.FieldDescriptorProto_TYPE_INT32 {
+		t.Error("Snapshot field type changed")
+	}
+	if name, _ := frozen.GetFieldName(1); name != "id" {
+		t.Error("Snapshot field name changed")
+	}
+	if e, _ := frozen.GetEnum(3); len(e.Names()) != 1 || !e.Frozen() {
+		t.Error("Snapshot enum changed")
+	}
+	if _, ok := frozen.GetOneof("choice"); !ok {
+		t.Error("Snapshot oneof changed")
+	}
+}
+
+func TestFreezePanicsOnModification(t *testing.T) {
+	frozen := NewProtoFieldMap()
+	frozen.Add(1, descriptor.FieldDescriptorProto_TYPE_INT32)
+	frozen.Add(2, descriptor.FieldDescriptorProto_TYPE_INT32)
+	frozen.AddOneof("choice", 1, 2)
+	frozen = frozen.Freeze()
+	enum := NewProtoEnum("E").Freeze()
+
+	mutators := map[string]func(){
+		"Reset":      frozen.Reset,
+		"SetName":    func() { frozen.SetName("x") },
+		"Add":        func() { frozen.Add(3, descriptor.FieldDescriptorProto_TYPE_BOOL) },
+		"AddMessage": func() { frozen.AddMessage(3, NewProtoFieldMap()) },
+		"AddEnum":    func() { frozen.AddEnum(3, NewProtoEnum("E")) },
+		"AddMap": func() {
+			frozen.AddMap(3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BOOL)
+		},
+		"AddOneof":      func() { frozen.AddOneof("other", 1) },
+		"RemoveOneof":   func() { frozen.RemoveOneof("choice") },
+		"SetFieldName":  func() { frozen.SetFieldName(1, "x") },
+		"SetLabel":      func() { frozen.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REPEATED) },
+		"RemoveByField": func() { frozen.RemoveByField(1) },
+		"RemoveByType":  func() { frozen.RemoveByType(descriptor.FieldDescriptorProto_TYPE_INT32) },
+		"ProtoEnum.Add": func() { enum.Add("A", 0) },
+		"ProtoEnum.Reset": func() {
+			enum.Reset()
+		},
+	}
+	for name, mutate := range mutators {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic on a frozen schema", name)
+				}
+			}()
+			mutate()
+		}()
+	}
+	if _, ok := frozen.Get(1); !ok {
+		t.Error("Frozen map was modified")
+	}
+}
+
+// TestFreezeConcurrent should be run with the race detector. One goroutine
+// keeps changing a schema and publishing snapshots, while others decode
+// with whichever snapshot is current.
+func TestFreezeConcurrent(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT64)
+	var current atomic.Value
+	current.Store(fm.Freeze())
+
+	buf, err := fm.EncodeBuffer([]FieldValue{{Field: 1, Value: int64(42)}})
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				snapshot := current.Load().(*ProtoFieldMap)
+				values, err := snapshot.DecodeBuffer(buf)
+				if err != nil || len(values) != 1 || values[0].Value != int64(42) {
+					t.Errorf("Unexpected decode %v, %v", values, err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 500; i++ {
+		fm.Add(FieldNum(i+2), descriptor.FieldDescriptorProto_TYPE_BOOL)
+		fm.SetFieldName(1, "value")
+		current.Store(fm.Freeze())
+	}
+	wg.Wait()
+}
@@ -1,6 +1,6 @@
 module github.com/linux4life798/dproto
 
-go 1.18
+go 1.20
 
 require github.com/golang/protobuf v0.0.0-20170523065751-7a211bcf3bce
 
@@ -8,7 +8,14 @@ package dproto
 
 import "github.com/golang/protobuf/protoc-gen-go/descriptor"
 import "strconv"
+import "strings"
+import "errors"
+import "fmt"
 import "encoding/base64"
+import "encoding/hex"
+
+// ErrInvalidFormat is returned when FormatOptions are out of range
+var ErrInvalidFormat = errors.New("Invalid format options")
 
 // typeName2ProtoType maps the Protobuf type identifier to
 // it's usable enumeration type.
@@ -37,7 +44,11 @@ func ParseProtobufType(typ string) (descriptor.FieldDescriptorProto_Type, bool)
 	return t, ok
 }
 
-// ParseAs parses the string as the specified Protobuf type
+// ParseAs parses the string as the specified Protobuf type.
+// The base applies to integers and enums, where 0 means the base is implied
+// by the prefix of the string, like strconv.ParseInt. For bytes, the base
+// selects the encoding: 64 for standard or URL safe base64, 16 for hex and 8
+// for escaped strings, as written by FormatAs.
 func ParseAs(value string, pbtype descriptor.FieldDescriptorProto_Type, base int) (interface{}, error) {
 	var v interface{}
 	var err = ErrInvalidProtoBufType
@@ -72,6 +83,11 @@ func ParseAs(value string, pbtype descriptor.FieldDescriptorProto_Type, base int
 		v, err = strconv.ParseUint(value, base, 64)
 		v = uint64(v.(uint64))
 
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// Enums are int32 values, which are decoded sign extended
+		v, err = strconv.ParseInt(value, base, 32)
+		v = uint64(v.(int64))
+
 	case descriptor.FieldDescriptorProto_TYPE_BOOL:
 		v, err = strconv.ParseBool(value)
 
@@ -87,14 +103,182 @@ func ParseAs(value string, pbtype descriptor.FieldDescriptorProto_Type, base int
 		v, err = value, nil
 
 	case descriptor.FieldDescriptorProto_TYPE_BYTES:
-		// we currently only support base64 encoding
-		if base == 64 {
-			v, err = base64.StdEncoding.DecodeString(value)
+		switch base {
+		case 64:
+			v, err = parseBase64(value)
+		case 16:
+			v, err = hex.DecodeString(value)
+		case 8:
+			v, err = unescapeBytes(value)
 		}
 
 	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
-		// Currently do not implement the Protobuf JSON format
+		// Messages need a schema, see UnmarshalJSON for the JSON format
 		err = ErrInvalidProtoBufType
 	}
 	return v, err
 }
+
+// BytesEncoding selects how FormatAs writes bytes
+type BytesEncoding int
+
+// Encodings of bytes values
+const (
+	// BytesBase64 is standard base64 with padding
+	BytesBase64 BytesEncoding = iota
+	// BytesBase64URL is URL safe base64 with padding
+	BytesBase64URL
+	// BytesHex is lowercase hex
+	BytesHex
+	// BytesEscaped is a C-style escaped string, like in the text format,
+	// without the surrounding quotes
+	BytesEscaped
+)
+
+// FormatOptions configures FormatAs. The zero value writes integers in
+// decimal, floats in their shortest exact form and bytes in base64.
+type FormatOptions struct {
+	// Base is the base of integers and enums, from 2 to 36. Zero means 10.
+	Base int
+	// FloatFormat is the strconv.FormatFloat format of floats and
+	// doubles: 'e', 'E', 'f', 'g', 'G', 'x' or 'X'. Zero means 'g'.
+	FloatFormat byte
+	// Precision is the number of digits of floats and doubles. Zero means
+	// the fewest digits that parse back exactly. Other precisions may round.
+	Precision int
+	// Bytes is the encoding of bytes values
+	Bytes BytesEncoding
+}
+
+// ParseBase returns the base that ParseAs needs to read back a value of
+// the Protobuf type pbtype that was written with the options
+func (o FormatOptions) ParseBase(pbtype descriptor.FieldDescriptorProto_Type) int {
+	switch pbtype {
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		switch o.Bytes {
+		case BytesHex:
+			return 16
+		case BytesEscaped:
+			return 8
+		}
+		return 64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_BOOL,
+		descriptor.FieldDescriptorProto_TYPE_STRING:
+		return 0
+	}
+	if o.Base == 0 {
+		return 10
+	}
+	return o.Base
+}
+
+// FormatAs formats value as the specified Protobuf type. It is the inverse
+// of ParseAs: ParseAs(s, pbtype, opts.ParseBase(pbtype)) gives back the
+// value, as long as opts.Precision is zero.
+// Numbers may be given as any Go number or numeric string, see CoerceAs.
+func FormatAs(value interface{}, pbtype descriptor.FieldDescriptorProto_Type, opts FormatOptions) (string, error) {
+	base := opts.Base
+	if base == 0 {
+		base = 10
+	}
+	if base < 2 || base > 36 {
+		return "", fmt.Errorf("%w: base %d", ErrInvalidFormat, opts.Base)
+	}
+	format, prec := opts.FloatFormat, opts.Precision
+	if format == 0 {
+		format = 'g'
+	}
+	if prec == 0 {
+		prec = -1
+	}
+	if !strings.ContainsRune("eEfgGxX", rune(format)) || prec < -1 {
+		return "", fmt.Errorf("%w: float format %q with precision %d", ErrInvalidFormat, format, opts.Precision)
+	}
+
+	// Decoded enums are sign extended, which CoerceAs takes as out of range
+	if n, ok := value.(uint64); ok && pbtype == descriptor.FieldDescriptorProto_TYPE_ENUM && int64(n) == int64(int32(n)) {
+		return strconv.FormatInt(int64(n), base), nil
+	}
+	v, err := CoerceAs(value, pbtype)
+	if err != nil {
+		return "", err
+	}
+	switch x := v.(type) {
+	case int32:
+		return strconv.FormatInt(int64(x), base), nil
+	case int64:
+		return strconv.FormatInt(x, base), nil
+	case uint32:
+		return strconv.FormatUint(uint64(x), base), nil
+	case uint64:
+		if pbtype == descriptor.FieldDescriptorProto_TYPE_ENUM {
+			return strconv.FormatInt(int64(x), base), nil
+		}
+		return strconv.FormatUint(x, base), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), format, prec, 32), nil
+	case float64:
+		return strconv.FormatFloat(x, format, prec, 64), nil
+	case bool:
+		if pbtype == descriptor.FieldDescriptorProto_TYPE_BOOL {
+			return strconv.FormatBool(x), nil
+		}
+	case string:
+		if pbtype == descriptor.FieldDescriptorProto_TYPE_STRING {
+			return x, nil
+		}
+	case []byte:
+		if pbtype == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return formatBytes(x, opts.Bytes)
+		}
+	}
+	return "", fmt.Errorf("%w: can not format %T as a %s", ErrInvalidProtoBufType, value, typeString(pbtype))
+}
+
+// formatBytes encodes b with enc
+func formatBytes(b []byte, enc BytesEncoding) (string, error) {
+	switch enc {
+	case BytesBase64:
+		return base64.StdEncoding.EncodeToString(b), nil
+	case BytesBase64URL:
+		return base64.URLEncoding.EncodeToString(b), nil
+	case BytesHex:
+		return hex.EncodeToString(b), nil
+	case BytesEscaped:
+		return escapeText(b), nil
+	}
+	return "", fmt.Errorf("%w: bytes encoding %d", ErrInvalidFormat, enc)
+}
+
+// parseBase64 decodes standard or URL safe base64, with or without padding
+func parseBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
+
+// unescapeBytes decodes the C-style escapes in s
+func unescapeBytes(s string) ([]byte, error) {
+	p := &textParser{s: s, line: 1}
+	b := []byte{}
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		if c != '\\' {
+			b = append(b, c)
+			continue
+		}
+		var err error
+		if b, err = p.escape(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
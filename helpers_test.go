@@ -0,0 +1,136 @@
+package dproto
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestFormatAsRoundTrip(t *testing.T) {
+	cases := []struct {
+		value  interface{}
+		pbtype descriptor.FieldDescriptorProto_Type
+	}{
+		{int32(math.MinInt32), descriptor.FieldDescriptorProto_TYPE_INT32},
+		{int32(-7), descriptor.FieldDescriptorProto_TYPE_SINT32},
+		{int32(math.MaxInt32), descriptor.FieldDescriptorProto_TYPE_SFIXED32},
+		{int64(math.MinInt64), descriptor.FieldDescriptorProto_TYPE_INT64},
+		{int64(math.MaxInt64), descriptor.FieldDescriptorProto_TYPE_SFIXED64},
+		{uint32(math.MaxUint32), descriptor.FieldDescriptorProto_TYPE_FIXED32},
+		{uint64(math.MaxUint64), descriptor.FieldDescriptorProto_TYPE_UINT64},
+		{uint64(math.MaxUint64), descriptor.FieldDescriptorProto_TYPE_ENUM},
+		{uint64(3), descriptor.FieldDescriptorProto_TYPE_ENUM},
+		{float32(0.1), descriptor.FieldDescriptorProto_TYPE_FLOAT},
+		{float32(math.MaxFloat32), descriptor.FieldDescriptorProto_TYPE_FLOAT},
+		{1.0 / 3, descriptor.FieldDescriptorProto_TYPE_DOUBLE},
+		{math.SmallestNonzeroFloat64, descriptor.FieldDescriptorProto_TYPE_DOUBLE},
+		{math.Inf(-1), descriptor.FieldDescriptorProto_TYPE_DOUBLE},
+		{true, descriptor.FieldDescriptorProto_TYPE_BOOL},
+		{"a\"b\\\n é", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{[]byte{}, descriptor.FieldDescriptorProto_TYPE_BYTES},
+		{[]byte{0, 0xFB, 0xFF, '\\', '"', 'z'}, descriptor.FieldDescriptorProto_TYPE_BYTES},
+	}
+	options := []FormatOptions{
+		{},
+		{Base: 2, FloatFormat: 'e', Bytes: BytesBase64URL},
+		{Base: 16, FloatFormat: 'f', Bytes: BytesHex},
+		{Base: 36, FloatFormat: 'x', Bytes: BytesEscaped},
+	}
+
+	for _, opts := range options {
+		for _, c := range cases {
+			s, err := FormatAs(c.value, c.pbtype, opts)
+			if err != nil {
+				t.Errorf("Formatting %v as %v with %+v: %v", c.value, c.pbtype, opts, err)
+				continue
+			}
+			v, err := ParseAs(s, c.pbtype, opts.ParseBase(c.pbtype))
+			if err != nil {
+				t.Errorf("Parsing %q as %v with %+v: %v", s, c.pbtype, opts, err)
+				continue
+			}
+			if b, ok := c.value.([]byte); ok {
+				if !bytes.Equal(v.([]byte), b) {
+					t.Errorf("Bytes % x came back as % x via %q", b, v, s)
+				}
+			} else if v != c.value {
+				t.Errorf("Value %v (%T) came back as %v (%T) via %q", c.value, c.value, v, v, s)
+			}
+		}
+	}
+}
+
+func TestFormatAs(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		pbtype   descriptor.FieldDescriptorProto_Type
+		opts     FormatOptions
+		expected string
+	}{
+		{int32(-255), descriptor.FieldDescriptorProto_TYPE_INT32, FormatOptions{Base: 16}, "-ff"},
+		{uint64(math.MaxUint64), descriptor.FieldDescriptorProto_TYPE_ENUM, FormatOptions{}, "-1"},
+		{float32(0.1), descriptor.FieldDescriptorProto_TYPE_FLOAT, FormatOptions{}, "0.1"},
+		{0.1, descriptor.FieldDescriptorProto_TYPE_DOUBLE, FormatOptions{FloatFormat: 'e', Precision: 3}, "1.000e-01"},
+		{"42", descriptor.FieldDescriptorProto_TYPE_UINT32, FormatOptions{Base: 2}, "101010"},
+		{7, descriptor.FieldDescriptorProto_TYPE_DOUBLE, FormatOptions{}, "7"},
+		{[]byte{0xFB, 0xFF}, descriptor.FieldDescriptorProto_TYPE_BYTES, FormatOptions{}, "+/8="},
+		{[]byte{0xFB, 0xFF}, descriptor.FieldDescriptorProto_TYPE_BYTES, FormatOptions{Bytes: BytesBase64URL}, "-_8="},
+		{[]byte{0xFB, 0xFF}, descriptor.FieldDescriptorProto_TYPE_BYTES, FormatOptions{Bytes: BytesHex}, "fbff"},
+		{[]byte("a\x00\n\"é"), descriptor.FieldDescriptorProto_TYPE_BYTES, FormatOptions{Bytes: BytesEscaped}, `a\000\n\"\303\251`},
+	}
+	for _, c := range cases {
+		if s, err := FormatAs(c.value, c.pbtype, c.opts); err != nil || s != c.expected {
+			t.Errorf("Expected %v as %q, got %q (%v)", c.value, c.expected, s, err)
+		}
+	}
+}
+
+func TestFormatAsErrors(t *testing.T) {
+	cases := []struct {
+		value  interface{}
+		pbtype descriptor.FieldDescriptorProto_Type
+		opts   FormatOptions
+		err    error
+	}{
+		{int32(1), descriptor.FieldDescriptorProto_TYPE_INT32, FormatOptions{Base: 1}, ErrInvalidFormat},
+		{int32(1), descriptor.FieldDescriptorProto_TYPE_INT32, FormatOptions{Base: 37}, ErrInvalidFormat},
+		{1.5, descriptor.FieldDescriptorProto_TYPE_DOUBLE, FormatOptions{FloatFormat: 'q'}, ErrInvalidFormat},
+		{[]byte{1}, descriptor.FieldDescriptorProto_TYPE_BYTES, FormatOptions{Bytes: 9}, ErrInvalidFormat},
+		{int64(1 << 40), descriptor.FieldDescriptorProto_TYPE_INT32, FormatOptions{}, ErrOverflow},
+		{1.5, descriptor.FieldDescriptorProto_TYPE_INT64, FormatOptions{}, ErrPrecisionLoss},
+		{[]byte{1}, descriptor.FieldDescriptorProto_TYPE_STRING, FormatOptions{}, ErrInvalidProtoBufType},
+		{"abc", descriptor.FieldDescriptorProto_TYPE_BYTES, FormatOptions{}, ErrInvalidProtoBufType},
+		{NewWireMessage(), descriptor.FieldDescriptorProto_TYPE_MESSAGE, FormatOptions{}, ErrInvalidProtoBufType},
+	}
+	for _, c := range cases {
+		if _, err := FormatAs(c.value, c.pbtype, c.opts); !errors.Is(err, c.err) {
+			t.Errorf("Formatting %v as %v with %+v: expected %v, got %v", c.value, c.pbtype, c.opts, c.err, err)
+		}
+	}
+}
+
+func TestParseAsBytes(t *testing.T) {
+	cases := []struct {
+		value string
+		base  int
+	}{
+		{"+/8=", 64},
+		{"-_8", 64},
+		{"FBff", 16},
+		{`\373\xff`, 8},
+	}
+	for _, c := range cases {
+		if v, err := ParseAs(c.value, descriptor.FieldDescriptorProto_TYPE_BYTES, c.base); err != nil || !bytes.Equal(v.([]byte), []byte{0xFB, 0xFF}) {
+			t.Errorf("Parsing %q with base %d gave %v (%v)", c.value, c.base, v, err)
+		}
+	}
+	if _, err := ParseAs("abc", descriptor.FieldDescriptorProto_TYPE_BYTES, 10); !errors.Is(err, ErrInvalidProtoBufType) {
+		t.Errorf("Expected ErrInvalidProtoBufType for base 10 bytes, got %v", err)
+	}
+	if _, err := ParseAs(`a\q`, descriptor.FieldDescriptorProto_TYPE_BYTES, 8); err == nil {
+		t.Error("Expected an error for an invalid escape")
+	}
+}
@@ -0,0 +1,113 @@
+package dproto
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// int32TestMessage is encoded by golang/protobuf, to cross-check the bytes
+// dproto writes
+type int32TestMessage struct {
+	MyInt32  *int32 `protobuf:"varint,1,opt,name=myint32"`
+	MySint32 *int32 `protobuf:"zigzag32,5,opt,name=mysint32"`
+	MyEnum   *int32 `protobuf:"varint,8,opt,name=myenum"`
+}
+
+func (m *int32TestMessage) Reset()         { *m = int32TestMessage{} }
+func (m *int32TestMessage) String() string { return proto.CompactTextString(m) }
+func (*int32TestMessage) ProtoMessage()    {}
+
+var int32TestValues = []int32{0, 1, -1, 150, -150, math.MaxInt32, math.MinInt32, 1 << 30, -(1 << 30)}
+
+func TestInt32MatchesGolangProtobuf(t *testing.T) {
+	for _, v := range int32TestValues {
+		v := v
+		expected, err := proto.Marshal(&int32TestMessage{MyInt32: &v, MySint32: &v, MyEnum: &v})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := NewWireMessage()
+		m.EncodeInt32(1, v)
+		m.EncodeSint32(5, v)
+		m.EncodeEnum(8, uint64(int64(v)))
+		buf, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, expected) {
+			t.Errorf("Value %d: expected % x, got % x", v, expected, buf)
+		}
+
+		m, err = Unmarshal(expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := m.DecodeAs(1, descriptor.FieldDescriptorProto_TYPE_INT32); err != nil || got != v {
+			t.Errorf("Value %d: decoded int32 %v (%v)", v, got, err)
+		}
+		if got, err := m.DecodeAs(5, descriptor.FieldDescriptorProto_TYPE_SINT32); err != nil || got != v {
+			t.Errorf("Value %d: decoded sint32 %v (%v)", v, got, err)
+		}
+
+		var decoded int32TestMessage
+		if err := proto.Unmarshal(buf, &decoded); err != nil || *decoded.MyInt32 != v || *decoded.MySint32 != v {
+			t.Errorf("Value %d: golang/protobuf decoded %v (%v)", v, decoded.String(), err)
+		}
+	}
+}
+
+func TestInt32Validation(t *testing.T) {
+	if !WireVarint(math.MaxUint64).ValidInt32() || !WireVarint(math.MaxInt32).ValidInt32() {
+		t.Error("Valid int32 varints were rejected")
+	}
+	if WireVarint(math.MaxUint32).ValidInt32() || WireVarint(1<<32).ValidInt32() {
+		t.Error("Invalid int32 varints were accepted")
+	}
+
+	// A 5 byte -1, as older versions of dproto wrote it, and a value that
+	// does not fit in 32 bits at all
+	m := NewWireMessage()
+	m.AddVarint(1, WireVarint(uint32(math.MaxUint32)))
+	m.AddVarint(2, WireVarint(1<<40))
+	m.AppendBytes(3, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F})
+	buf, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = Unmarshal(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.DecodeAs(1, descriptor.FieldDescriptorProto_TYPE_INT32); !errors.Is(err, ErrInvalidInt32) {
+		t.Errorf("Expected ErrInvalidInt32, got %v", err)
+	}
+	if _, err := m.DecodeRepeatedAs(1, descriptor.FieldDescriptorProto_TYPE_INT32); !errors.Is(err, ErrInvalidInt32) {
+		t.Errorf("Expected ErrInvalidInt32 for repeated field, got %v", err)
+	}
+	if v, ok := m.DecodeInt32(1); !ok || v != -1 {
+		t.Errorf("Low level DecodeInt32 should truncate, got %d", v)
+	}
+
+	m.SetLegacyInt32(true)
+	if v, err := m.DecodeAs(1, descriptor.FieldDescriptorProto_TYPE_INT32); err != nil || v != int32(-1) {
+		t.Errorf("Legacy mode decoded %v (%v)", v, err)
+	}
+	if _, err := m.DecodeAs(2, descriptor.FieldDescriptorProto_TYPE_INT32); !errors.Is(err, ErrInvalidInt32) {
+		t.Errorf("Legacy mode accepted a value wider than 32 bits: %v", err)
+	}
+
+	m.SetCompatMode(true)
+	if vals, err := m.DecodeRepeatedAs(3, descriptor.FieldDescriptorProto_TYPE_INT32); err != nil || vals[0] != int32(-1) {
+		t.Errorf("Compatibility mode decoded packed %v (%v)", vals, err)
+	}
+	if v, err := m.DecodeAs(2, descriptor.FieldDescriptorProto_TYPE_INT32); err != nil || v != int32(0) {
+		t.Errorf("Compatibility mode should truncate, got %v (%v)", v, err)
+	}
+}
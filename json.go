@@ -0,0 +1,790 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the canonical proto3 JSON mapping of messages described
+// by a ProtoFieldMap. Field names are lowerCamelCase, 64 bit integers are
+// strings, bytes are base64, enums are value names and the well-known types
+// use their special forms, like RFC 3339 strings for Timestamps.
+//
+// These functions convert message contents. The MarshalJSON and
+// UnmarshalJSON methods of ProtoFieldMap convert the schema itself.
+
+package dproto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ErrInvalidJSON is returned when JSON does not match the message schema
+var ErrInvalidJSON = errors.New("Invalid Protobuf JSON")
+
+// JSONOptions configures the JSON mapping
+type JSONOptions struct {
+	// EmitUnpopulated writes fields that are not present with their
+	// default value. Members of oneofs are never written this way.
+	EmitUnpopulated bool
+	// UseProtoNames writes the field names of the schema, instead of their
+	// lowerCamelCase JSON names. Both are always accepted when reading.
+	UseProtoNames bool
+	// DiscardUnknown ignores unknown JSON fields when unmarshalling,
+	// instead of failing
+	DiscardUnknown bool
+	// Resolver finds the schemas of messages packed in Any fields
+	Resolver AnyResolver
+}
+
+// MarshalJSON converts the message m, described by fm, into proto3 JSON
+func MarshalJSON(fm *ProtoFieldMap, m *WireMessage) ([]byte, error) {
+	return JSONOptions{}.Marshal(fm, m)
+}
+
+// UnmarshalJSON converts proto3 JSON into a message described by fm
+func UnmarshalJSON(fm *ProtoFieldMap, data []byte) (*WireMessage, error) {
+	return JSONOptions{}.Unmarshal(fm, data)
+}
+
+// Marshal converts the message m, described by fm, into proto3 JSON.
+// Fields of m that are not in fm are dropped.
+func (o JSONOptions) Marshal(fm *ProtoFieldMap, m *WireMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := o.writeMessage(&buf, fm, m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal converts proto3 JSON into a message described by fm
+func (o JSONOptions) Unmarshal(fm *ProtoFieldMap, data []byte) (*WireMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: data after the top level value", ErrInvalidJSON)
+	}
+	return o.readMessage(fm, v)
+}
+
+// jsonName converts a field name to lowerCamelCase, like protoc does
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// fieldName returns the JSON key of field
+func (o JSONOptions) fieldName(fm *ProtoFieldMap, field FieldNum) string {
+	name := fm.descriptorFieldName(field)
+	if o.UseProtoNames {
+		return name
+	}
+	return jsonName(name)
+}
+
+// lookupField finds the field with the JSON or schema name key
+func lookupField(fm *ProtoFieldMap, key string) (FieldNum, bool) {
+	if field, ok := fm.GetFieldByName(key); ok {
+		return field, true
+	}
+	for _, field := range fm.GetFieldNums() {
+		name := fm.descriptorFieldName(field)
+		if key == name || key == jsonName(name) {
+			return field, true
+		}
+	}
+	return 0, false
+}
+
+// jsonKind names the kind of a decoded JSON value, for error messages
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// kindError reports a JSON value that does not fit the expected kind
+func kindError(expected string, v interface{}) error {
+	return fmt.Errorf("%w: expected %s, got %s", ErrInvalidJSON, expected, jsonKind(v))
+}
+
+/////////////////////////////// Marshalling ///////////////////////////////////
+
+// writeJSON writes v as JSON, without escaping HTML characters
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	// Encode ends every value with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+func (o JSONOptions) writeMessage(buf *bytes.Buffer, fm *ProtoFieldMap, m *WireMessage) error {
+	name := normalizeTypeName(fm.name)
+	if wkt, ok := wellKnownTypes[name]; ok {
+		return o.writeWellKnown(buf, name, wkt, m)
+	}
+
+	buf.WriteByte('{')
+	first := true
+	for _, field := range fm.GetFieldNums() {
+		if _, ok := m.LastOccurrence(field); !ok {
+			if _, isOneof := fm.field2oneof[field]; isOneof || !o.EmitUnpopulated {
+				continue
+			}
+		} else if !fm.oneofWinner(m, field) {
+			continue
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeJSON(buf, o.fieldName(fm, field))
+		buf.WriteByte(':')
+		if err := o.writeField(buf, fm, m, field); err != nil {
+			return fmt.Errorf("field %d: %w", field, err)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func (o JSONOptions) writeField(buf *bytes.Buffer, fm *ProtoFieldMap, m *WireMessage, field FieldNum) error {
+	typ := fm.field2type[field]
+	sub, enum := fm.field2msg[field], fm.field2enum[field]
+
+	if entry, isMap := fm.maps[field]; isMap {
+		return o.writeMap(buf, entry, m, field)
+	}
+
+	if fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		vals, err := m.DecodeRepeatedAs(field, typ)
+		if err != nil && err != ErrMessageFieldMissing {
+			return err
+		}
+		buf.WriteByte('[')
+		for i, v := range vals {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := o.writeValue(buf, v, typ, sub, enum); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	v, err := m.DecodeAs(field, typ)
+	if err == ErrMessageFieldMissing {
+		// Only unpopulated fields that should be emitted get here
+		if typ == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			buf.WriteString("null")
+			return nil
+		}
+		v, err = zeroValue(typ), nil
+	}
+	if err != nil {
+		return err
+	}
+	return o.writeValue(buf, v, typ, sub, enum)
+}
+
+// writeMap writes the map field as a JSON object with sorted keys
+func (o JSONOptions) writeMap(buf *bytes.Buffer, entry mapEntryType, m *WireMessage, field FieldNum) error {
+	keys, entries, err := entry.rawEntries(m, field)
+	if err != nil {
+		return err
+	}
+
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSON(buf, fmt.Sprint(k))
+		buf.WriteByte(':')
+		if err := o.writeValue(buf, entries[k], entry.value, entry.valueMsg, entry.valueEnum); err != nil {
+			return fmt.Errorf("key %v: %w", k, err)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeValue writes a single decoded value of the Protobuf type typ
+func (o JSONOptions) writeValue(buf *bytes.Buffer, v interface{}, typ descriptor.FieldDescriptorProto_Type, sub *ProtoFieldMap, enum *ProtoEnum) error {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		wm := v.(*WireMessage)
+		if sub == nil {
+			// Without a schema, the message can only be kept as bytes
+			b, err := wm.Marshal()
+			if err != nil {
+				return err
+			}
+			return writeJSON(buf, base64.StdEncoding.EncodeToString(b))
+		}
+		return o.writeMessage(buf, sub, wm)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		number := int32(v.(uint64))
+		if enum != nil {
+			if normalizeTypeName(enum.name) == NullValueName {
+				buf.WriteString("null")
+				return nil
+			}
+			if name, ok := enum.GetName(number); ok {
+				return writeJSON(buf, name)
+			}
+		}
+		buf.WriteString(strconv.FormatInt(int64(number), 10))
+		return nil
+	}
+	return writeScalar(buf, v)
+}
+
+// writeScalar writes a decoded scalar value
+func writeScalar(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case int64, uint64:
+		return writeJSON(buf, fmt.Sprint(x))
+	case float32:
+		return writeFloat(buf, float64(x), x)
+	case float64:
+		return writeFloat(buf, x, x)
+	case []byte:
+		return writeJSON(buf, base64.StdEncoding.EncodeToString(x))
+	}
+	return writeJSON(buf, v)
+}
+
+// writeFloat writes the float f, or one of the strings "NaN", "Infinity"
+// and "-Infinity". The value v holds f in its original precision.
+func writeFloat(buf *bytes.Buffer, f float64, v interface{}) error {
+	switch {
+	case math.IsNaN(f):
+		buf.WriteString(`"NaN"`)
+	case math.IsInf(f, 1):
+		buf.WriteString(`"Infinity"`)
+	case math.IsInf(f, -1):
+		buf.WriteString(`"-Infinity"`)
+	default:
+		return writeJSON(buf, v)
+	}
+	return nil
+}
+
+// formatNanos formats nanos as a fraction with 0, 3, 6 or 9 digits
+func formatNanos(nanos int32) string {
+	switch {
+	case nanos == 0:
+		return ""
+	case nanos%1000000 == 0:
+		return fmt.Sprintf(".%03d", nanos/1000000)
+	case nanos%1000 == 0:
+		return fmt.Sprintf(".%06d", nanos/1000)
+	}
+	return fmt.Sprintf(".%09d", nanos)
+}
+
+func (o JSONOptions) writeWellKnown(buf *bytes.Buffer, name string, wkt *wellKnownType, m *WireMessage) error {
+	switch name {
+	case AnyName:
+		return o.writeAny(buf, m)
+	case TimestampName:
+		seconds, nanos, err := decodeSecondsNanos(m)
+		if err != nil {
+			return err
+		}
+		if err := checkTimestamp(seconds, nanos); err != nil {
+			return err
+		}
+		t := time.Unix(seconds, 0).UTC()
+		return writeJSON(buf, t.Format("2006-01-02T15:04:05")+formatNanos(nanos)+"Z")
+	case DurationName:
+		seconds, nanos, err := decodeSecondsNanos(m)
+		if err != nil {
+			return err
+		}
+		if err := checkDuration(seconds, nanos); err != nil {
+			return err
+		}
+		sign := ""
+		if seconds < 0 || nanos < 0 {
+			sign, seconds, nanos = "-", -seconds, -nanos
+		}
+		return writeJSON(buf, fmt.Sprintf("%s%d%ss", sign, seconds, formatNanos(nanos)))
+	case StructName, ValueName, ListValueName:
+		v, err := wkt.decode(m)
+		if err != nil {
+			return err
+		}
+		return writeJSON(buf, v)
+	}
+
+	// The wrappers are written as their plain value
+	typ := wkt.schema.field2type[1]
+	v, err := decodeEntryField(m, 1, typ)
+	if err != nil {
+		return err
+	}
+	return writeScalar(buf, v)
+}
+
+// writeAny writes the packed message with an added "@type" field, or its
+// special form in a "value" field if it is a well-known type
+func (o JSONOptions) writeAny(buf *bytes.Buffer, m *WireMessage) error {
+	typeURL, _ := m.DecodeString(anyTypeURLField)
+	if _, ok := m.LastOccurrence(anyValueField); !ok && typeURL == "" {
+		buf.WriteString("{}")
+		return nil
+	}
+	if o.Resolver == nil {
+		return fmt.Errorf("%w: no resolver for %s", ErrUnresolvedType, typeURL)
+	}
+	d, err := UnpackAny(m, o.Resolver)
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString(`{"@type":`)
+	writeJSON(buf, typeURL)
+	name := normalizeTypeName(d.fm.name)
+	if wkt, ok := wellKnownTypes[name]; ok {
+		buf.WriteString(`,"value":`)
+		if err := o.writeWellKnown(buf, name, wkt, d.wire); err != nil {
+			return err
+		}
+	} else {
+		var fields bytes.Buffer
+		if err := o.writeMessage(&fields, d.fm, d.wire); err != nil {
+			return err
+		}
+		// Splice in the fields, without their braces
+		if inner := fields.Bytes(); len(inner) > 2 {
+			buf.WriteByte(',')
+			buf.Write(inner[1 : len(inner)-1])
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+////////////////////////////// Unmarshalling //////////////////////////////////
+
+func (o JSONOptions) readMessage(fm *ProtoFieldMap, v interface{}) (*WireMessage, error) {
+	name := normalizeTypeName(fm.name)
+	if wkt, ok := wellKnownTypes[name]; ok {
+		return o.readWellKnown(name, wkt, v)
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, kindError("object", v)
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := NewWireMessage()
+	seen := make(map[FieldNum]bool)
+	oneofs := make(map[string]FieldNum)
+	for _, key := range keys {
+		field, ok := lookupField(fm, key)
+		if !ok {
+			if o.DiscardUnknown {
+				continue
+			}
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidJSON, key)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("%w: field %q given twice", ErrInvalidJSON, key)
+		}
+		seen[field] = true
+
+		value := obj[key]
+		if value == nil && !acceptsNull(fm, field) {
+			// Null means the default value
+			continue
+		}
+		if oneof, ok := fm.field2oneof[field]; ok {
+			if other, set := oneofs[oneof]; set {
+				return nil, fmt.Errorf("%w: fields %d and %d of %s", ErrOneofConflict, other, field, oneof)
+			}
+			oneofs[oneof] = field
+		}
+		if err := o.readField(m, fm, field, value); err != nil {
+			return nil, fmt.Errorf("field %s: %w", key, err)
+		}
+	}
+	return m, nil
+}
+
+// acceptsNull indicates if a JSON null is a value of the singular field,
+// rather than its default, which is only true for Value and NullValue
+func acceptsNull(fm *ProtoFieldMap, field FieldNum) bool {
+	if fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return false
+	}
+	if sub, ok := fm.field2msg[field]; ok {
+		return normalizeTypeName(sub.name) == ValueName
+	}
+	if enum, ok := fm.field2enum[field]; ok {
+		return normalizeTypeName(enum.name) == NullValueName
+	}
+	return false
+}
+
+func (o JSONOptions) readField(m *WireMessage, fm *ProtoFieldMap, field FieldNum, value interface{}) error {
+	typ := fm.field2type[field]
+	sub, enum := fm.field2msg[field], fm.field2enum[field]
+
+	if entry, isMap := fm.maps[field]; isMap {
+		return o.readMap(m, entry, field, value)
+	}
+
+	if fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		arr, ok := value.([]interface{})
+		if !ok {
+			return kindError("array", value)
+		}
+		for i, elem := range arr {
+			v, err := o.readValue(elem, typ, sub, enum)
+			if err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+			if err := m.AppendAs(field, v, typ); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	v, err := o.readValue(value, typ, sub, enum)
+	if err != nil {
+		return err
+	}
+	return m.EncodeAs(field, v, typ)
+}
+
+// readMap reads a JSON object into the entries of a map field
+func (o JSONOptions) readMap(m *WireMessage, entry mapEntryType, field FieldNum, value interface{}) error {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return kindError("object", value)
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key, err := ParseAs(k, entry.key, 10)
+		if err != nil {
+			return fmt.Errorf("%w: invalid %s map key %q", ErrInvalidJSON, typeString(entry.key), k)
+		}
+		v, err := o.readValue(obj[k], entry.value, entry.valueMsg, entry.valueEnum)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", k, err)
+		}
+		e := NewWireMessage()
+		if err := e.EncodeAs(mapEntryKeyField, key, entry.key); err != nil {
+			return err
+		}
+		if err := e.EncodeAs(mapEntryValueField, v, entry.value); err != nil {
+			return err
+		}
+		b, err := e.Marshal()
+		if err != nil {
+			return err
+		}
+		m.AppendBytes(field, b)
+	}
+	return nil
+}
+
+// readValue converts a single JSON value into the Go type EncodeAs
+// expects for typ
+func (o JSONOptions) readValue(v interface{}, typ descriptor.FieldDescriptorProto_Type, sub *ProtoFieldMap, enum *ProtoEnum) (interface{}, error) {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if sub == nil {
+			s, ok := v.(string)
+			if !ok {
+				return nil, kindError("base64 string", v)
+			}
+			b, err := decodeBase64(s)
+			if err != nil {
+				return nil, err
+			}
+			return Unmarshal(b)
+		}
+		return o.readMessage(sub, v)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum != nil && normalizeTypeName(enum.name) == NullValueName && v == nil {
+			return uint64(0), nil
+		}
+		switch x := v.(type) {
+		case string:
+			if enum != nil {
+				if number, ok := enum.GetNumber(x); ok {
+					return uint64(int64(number)), nil
+				}
+			}
+			return nil, fmt.Errorf("%w: unknown enum value %q", ErrInvalidJSON, x)
+		case json.Number:
+			return CoerceAs(string(x), typ)
+		}
+		return nil, kindError("enum name or number", v)
+	}
+	return readScalar(v, typ)
+}
+
+// readScalar converts a JSON value into a scalar of the Protobuf type typ
+func readScalar(v interface{}, typ descriptor.FieldDescriptorProto_Type) (interface{}, error) {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, kindError("bool", v)
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, kindError("string", v)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		if s, ok := v.(string); ok {
+			return decodeBase64(s)
+		}
+		return nil, kindError("base64 string", v)
+	}
+
+	// Numbers may also be given as strings, which is how 64 bit integers,
+	// NaN and Infinity are written. CoerceAs only accepts decimal strings,
+	// with an optional exponent, as the JSON mapping requires.
+	switch x := v.(type) {
+	case json.Number:
+		return CoerceAs(string(x), typ)
+	case string:
+		if x != strings.TrimSpace(x) || x == "" {
+			return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidJSON, x)
+		}
+		return CoerceAs(x, typ)
+	}
+	return nil, kindError("number", v)
+}
+
+// decodeBase64 decodes standard or URL safe base64, with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	b, err := parseBase64(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	return b, nil
+}
+
+// parseDuration parses the JSON form of a Duration, like "-1.5s"
+func parseDuration(s string) (int64, int32, error) {
+	invalid := fmt.Errorf("%w: invalid duration %q", ErrInvalidJSON, s)
+	if !strings.HasSuffix(s, "s") {
+		return 0, 0, invalid
+	}
+	s = strings.TrimSuffix(s, "s")
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, frac, _ := strings.Cut(s, ".")
+	if !isDigits(whole) || len(frac) > 9 || (frac != "" && !isDigits(frac)) {
+		return 0, 0, invalid
+	}
+
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: duration %q", ErrOverflow, s)
+	}
+	var nanos int64
+	if frac != "" {
+		nanos, _ = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 32)
+	}
+	if neg {
+		seconds, nanos = -seconds, -nanos
+	}
+	return seconds, int32(nanos), nil
+}
+
+// isDigits indicates if s is a non-empty string of decimal digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonToGo replaces the json.Numbers in v by float64s
+func jsonToGo(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return CoerceAs(string(x), descriptor.FieldDescriptorProto_TYPE_DOUBLE)
+	case []interface{}:
+		result := make([]interface{}, len(x))
+		for i, elem := range x {
+			var err error
+			if result[i], err = jsonToGo(elem); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(x))
+		for k, elem := range x {
+			var err error
+			if result[k], err = jsonToGo(elem); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	return v, nil
+}
+
+func (o JSONOptions) readWellKnown(name string, wkt *wellKnownType, v interface{}) (*WireMessage, error) {
+	switch name {
+	case AnyName:
+		return o.readAny(v)
+	case TimestampName:
+		s, ok := v.(string)
+		if !ok {
+			return nil, kindError("RFC 3339 string", v)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+		}
+		m, _, err := encodeTimestamp(t)
+		return m, err
+	case DurationName:
+		s, ok := v.(string)
+		if !ok {
+			return nil, kindError("duration string", v)
+		}
+		seconds, nanos, err := parseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDuration(seconds, nanos); err != nil {
+			return nil, err
+		}
+		return encodeSecondsNanos(seconds, nanos), nil
+	case StructName, ValueName, ListValueName:
+		g, err := jsonToGo(v)
+		if err != nil {
+			return nil, err
+		}
+		m, ok, err := wkt.encode(g)
+		if !ok {
+			return nil, kindError(map[string]string{StructName: "object", ListValueName: "array"}[name], v)
+		}
+		return m, err
+	}
+
+	// The wrappers are given as their plain value
+	x, err := readScalar(v, wkt.schema.field2type[1])
+	if err != nil {
+		return nil, err
+	}
+	m, _, err := wkt.encode(x)
+	return m, err
+}
+
+// readAny reads an object with an "@type" field, holding either the fields
+// of the packed message or its special form in a "value" field
+func (o JSONOptions) readAny(v interface{}) (*WireMessage, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, kindError("object", v)
+	}
+	if len(obj) == 0 {
+		return NewWireMessage(), nil
+	}
+	typeURL, ok := obj["@type"].(string)
+	if !ok || typeURL == "" {
+		return nil, fmt.Errorf("%w: %w: missing @type", ErrInvalidJSON, ErrInvalidAny)
+	}
+	if o.Resolver == nil {
+		return nil, fmt.Errorf("%w: no resolver for %s", ErrUnresolvedType, typeURL)
+	}
+	sub, ok := o.Resolver.ResolveAny(typeURL)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolvedType, typeURL)
+	}
+
+	var inner *WireMessage
+	var err error
+	name := normalizeTypeName(sub.name)
+	if wkt, ok := wellKnownTypes[name]; ok {
+		for k := range obj {
+			if k != "@type" && k != "value" {
+				return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidJSON, k)
+			}
+		}
+		inner, err = o.readWellKnown(name, wkt, obj["value"])
+	} else {
+		fields := make(map[string]interface{}, len(obj)-1)
+		for k, elem := range obj {
+			if k != "@type" {
+				fields[k] = elem
+			}
+		}
+		inner, err = o.readMessage(sub, fields)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return PackAny(typeURL, inner)
+}
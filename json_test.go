@@ -0,0 +1,265 @@
+package dproto
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// newJSONTestMap creates a schema covering the special cases of the JSON
+// mapping
+func newJSONTestMap() *ProtoFieldMap {
+	color := NewProtoEnum("test.Color")
+	color.Add("RED", 0)
+	color.Add("GREEN", 1)
+
+	inner := NewProtoFieldMap()
+	inner.SetName("test.Inner")
+	inner.Add(1, descriptor.FieldDescriptorProto_TYPE_INT32)
+	inner.SetFieldName(1, "value")
+
+	fm := NewProtoFieldMap()
+	fm.SetName("test.Outer")
+	fields := []struct {
+		field FieldNum
+		name  string
+		typ   descriptor.FieldDescriptorProto_Type
+	}{
+		{1, "user_id", descriptor.FieldDescriptorProto_TYPE_INT64},
+		{2, "count", descriptor.FieldDescriptorProto_TYPE_UINT32},
+		{3, "ratio", descriptor.FieldDescriptorProto_TYPE_DOUBLE},
+		{4, "data", descriptor.FieldDescriptorProto_TYPE_BYTES},
+		{6, "tags", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{9, "name", descriptor.FieldDescriptorProto_TYPE_STRING},
+		{10, "enabled", descriptor.FieldDescriptorProto_TYPE_BOOL},
+	}
+	for _, f := range fields {
+		fm.Add(f.field, f.typ)
+		fm.SetFieldName(f.field, f.name)
+	}
+	fm.AddEnum(5, color)
+	fm.SetFieldName(5, "color")
+	fm.SetLabel(6, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	fm.AddMessage(7, inner)
+	fm.SetFieldName(7, "inner_msg")
+	fm.AddMap(8, descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.SetFieldName(8, "labels")
+	fm.AddOneof("choice", 9, 10)
+	fm.AddWellKnown(11, TimestampName)
+	fm.SetFieldName(11, "created")
+	fm.AddWellKnown(12, DurationName)
+	fm.SetFieldName(12, "timeout")
+	fm.AddWellKnown(13, Int64ValueName)
+	fm.SetFieldName(13, "limit")
+	fm.AddWellKnown(14, StructName)
+	fm.SetFieldName(14, "extra")
+	fm.AddWellKnown(15, ValueName)
+	fm.SetFieldName(15, "any_value")
+	return fm
+}
+
+func TestJSONMarshal(t *testing.T) {
+	fm := newJSONTestMap()
+	m, err := fm.EncodeMessage([]FieldValue{
+		{1, int64(-9007199254740993)},
+		{2, uint32(7)},
+		{3, math.Inf(-1)},
+		{4, []byte{0xFB, 0xFF}},
+		{5, "GREEN"},
+		{6, []interface{}{"a", "<b>"}},
+		{7, []FieldValue{{1, int32(3)}}},
+		{8, map[int32]string{2: "two", -1: "minus"}},
+		{10, true},
+		{11, time.Date(2017, 3, 13, 1, 2, 3, 500000000, time.UTC)},
+		{12, -1500 * time.Millisecond},
+		{13, int64(5)},
+		{14, map[string]interface{}{"k": []interface{}{float64(1), nil}}},
+		{15, nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := MarshalJSON(fm, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"userId":"-9007199254740993","count":7,"ratio":"-Infinity","data":"+/8=",` +
+		`"color":"GREEN","tags":["a","<b>"],"innerMsg":{"value":3},"labels":{"-1":"minus","2":"two"},` +
+		`"enabled":true,"created":"2017-03-13T01:02:03.500Z","timeout":"-1.500s","limit":"5",` +
+		`"extra":{"k":[1,null]},"anyValue":null}`
+	if string(buf) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buf)
+	}
+
+	// And back again
+	m2, err := UnmarshalJSON(fm, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf2, err := MarshalJSON(fm, m2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf2) != expected {
+		t.Errorf("Round trip mismatch\n%s\n%s", expected, buf2)
+	}
+}
+
+func TestJSONOptions(t *testing.T) {
+	fm := newJSONTestMap()
+	m := NewWireMessage()
+	m.EncodeInt64(1, 1)
+
+	buf, err := JSONOptions{UseProtoNames: true}.Marshal(fm, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != `{"user_id":"1"}` {
+		t.Errorf("Unexpected %s", buf)
+	}
+
+	buf, err = JSONOptions{EmitUnpopulated: true}.Marshal(fm, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"userId":"1","count":0,"ratio":0,"data":"","color":"RED","tags":[],"innerMsg":null,` +
+		`"labels":{},"created":null,"timeout":null,"limit":null,"extra":null,"anyValue":null}`
+	if string(buf) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buf)
+	}
+
+	if _, err := UnmarshalJSON(fm, []byte(`{"nope":1}`)); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("Expected ErrInvalidJSON for an unknown field, got %v", err)
+	}
+	if _, err := (JSONOptions{DiscardUnknown: true}).Unmarshal(fm, []byte(`{"nope":1}`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONUnmarshalForms(t *testing.T) {
+	fm := newJSONTestMap()
+	m, err := UnmarshalJSON(fm, []byte(`{
+		"user_id": 12,
+		"count": "8",
+		"ratio": "NaN",
+		"data": "-_8",
+		"color": 1,
+		"innerMsg": {"value": 1e2},
+		"name": null,
+		"timeout": "3s",
+		"extra": {}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := fm.DecodeMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[FieldNum]interface{})
+	for _, v := range values {
+		got[v.Field] = v.Value
+	}
+	if got[1] != int64(12) || got[2] != uint32(8) || got[5] != uint64(1) || got[12] != 3*time.Second {
+		t.Errorf("Unexpected values %v", values)
+	}
+	if r, _ := got[3].(float64); !math.IsNaN(r) {
+		t.Errorf("Expected NaN, got %v", got[3])
+	}
+	if d, _ := got[4].([]byte); len(d) != 2 || d[0] != 0xFB {
+		t.Errorf("Expected URL safe base64 bytes, got %v", got[4])
+	}
+	if _, ok := got[9]; ok {
+		t.Error("Null should leave the field out")
+	}
+}
+
+func TestJSONUnmarshalErrors(t *testing.T) {
+	fm := newJSONTestMap()
+	tests := []struct {
+		json string
+		err  error
+	}{
+		{`{"count": 1.5}`, ErrPrecisionLoss},
+		{`{"count": -1}`, ErrOverflow},
+		{`{"color": "BLUE"}`, ErrInvalidJSON},
+		{`{"tags": "a"}`, ErrInvalidJSON},
+		{`{"name": "a", "enabled": true}`, ErrOneofConflict},
+		{`{"userId": 1, "user_id": 2}`, ErrInvalidJSON},
+		{`{"created": "10000-01-01T00:00:00Z"}`, ErrInvalidJSON},
+		{`{"timeout": "1.5"}`, ErrInvalidJSON},
+		{`{"timeout": "315576000001s"}`, ErrOverflow},
+		{`{"user_id": "0x10"}`, ErrInvalidProtoBufType},
+		{`{"count": "0b11"}`, ErrInvalidProtoBufType},
+		{`{"user_id": "1_000"}`, ErrInvalidProtoBufType},
+		{`{} {}`, ErrInvalidJSON},
+		{`[]`, ErrInvalidJSON},
+	}
+	for _, test := range tests {
+		if _, err := UnmarshalJSON(fm, []byte(test.json)); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.json, test.err, err)
+		}
+	}
+}
+
+func TestJSONDecimalStrings(t *testing.T) {
+	// Integer strings are decimal, so leading zeros are not octal
+	fm := newJSONTestMap()
+	m, err := UnmarshalJSON(fm, []byte(`{"user_id": "010", "count": "2e1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MarshalJSON(fm, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"userId":"10","count":20}` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+}
+
+func TestJSONAny(t *testing.T) {
+	r := newAnyTestRegistry(t)
+	duration, _ := WellKnownSchema(DurationName)
+	r.AddMessage(duration)
+	fm := NewProtoFieldMap()
+	fm.AddWellKnown(1, AnyName)
+	fm.SetFieldName(1, "payload")
+	fm.AddWellKnown(2, AnyName)
+	fm.SetFieldName(2, "wait")
+
+	point := NewWireMessage()
+	point.EncodeSint32(1, -3)
+	pointAny, _ := PackAny("test.Point", point)
+	wait := encodeSecondsNanos(2, 0)
+	waitAny, _ := PackAny(DurationName, wait)
+	m := NewWireMessage()
+	m.EncodeMessage(1, pointAny)
+	m.EncodeMessage(2, waitAny)
+
+	opts := JSONOptions{Resolver: r}
+	buf, err := opts.Marshal(fm, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"payload":{"@type":"type.googleapis.com/test.Point","field1":-3},` +
+		`"wait":{"@type":"type.googleapis.com/google.protobuf.Duration","value":"2s"}}`
+	if string(buf) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buf)
+	}
+
+	m2, err := opts.Unmarshal(fm, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf2, err := opts.Marshal(fm, m2); err != nil || string(buf2) != expected {
+		t.Errorf("Round trip mismatch %s, %v", buf2, err)
+	}
+
+	if _, err := MarshalJSON(fm, m); !errors.Is(err, ErrUnresolvedType) {
+		t.Errorf("Expected ErrUnresolvedType without a resolver, got %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file adds support for Protobuf map<K,V> fields to the ProtoFieldMap.
+// On the wire, a map is simply a repeated embedded message where each
+// entry message holds the key as field 1 and the value as field 2.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Field numbers used inside every map entry message
+const (
+	mapEntryKeyField   FieldNum = 1
+	mapEntryValueField FieldNum = 2
+)
+
+// mapEntryType holds the key and value types of a map field.
+// The value may optionally have a nested message schema or enum.
+type mapEntryType struct {
+	key       descriptor.FieldDescriptorProto_Type
+	value     descriptor.FieldDescriptorProto_Type
+	valueMsg  *ProtoFieldMap
+	valueEnum *ProtoEnum
+}
+
+// validMapKeyType indicates if typ is allowed as a Protobuf map key.
+// Any integral or string type is allowed. Floats, bytes, enums and messages
+// are not.
+func validMapKeyType(typ descriptor.FieldDescriptorProto_Type) bool {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_BOOL,
+		descriptor.FieldDescriptorProto_TYPE_STRING:
+		return true
+	}
+	return false
+}
+
+// AddMap adds a map<keyType, valueType> field association to a ProtoFieldMap.
+//
+// Decoding a map field yields a map[interface{}]interface{}. Encoding accepts
+// any Go map whose keys and values match the declared Protobuf types.
+// It returns false if keyType is not a valid map key type or valueType
+// is not a valid Protobuf type.
+func (fm *ProtoFieldMap) AddMap(field FieldNum, keyType, valueType descriptor.FieldDescriptorProto_Type) bool {
+	fm.checkMutable()
+	if !validMapKeyType(keyType) {
+		return false
+	}
+	if _, ok := protoType2WireType[valueType]; !ok {
+		return false
+	}
+	fm.Add(field, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	fm.maps[field] = mapEntryType{key: keyType, value: valueType}
+	return true
+}
+
+// GetMap gets the key and value Protobuf types associated with the map
+// field number
+func (fm *ProtoFieldMap) GetMap(field FieldNum) (keyType, valueType descriptor.FieldDescriptorProto_Type, ok bool) {
+	entry, ok := fm.maps[field]
+	return entry.key, entry.value, ok
+}
+
+// zeroValue returns the Go zero value that DecodeAs would yield for pbtype.
+// This is used to fill in map entries that omit their key or value.
+func zeroValue(pbtype descriptor.FieldDescriptorProto_Type) interface{} {
+	switch pbtype {
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return int32(0)
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64(0)
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return uint32(0)
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return uint64(0)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return float32(0)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return float64(0)
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return ""
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return []byte{}
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return NewWireMessage()
+	}
+	return nil
+}
+
+// decodeEntryField decodes a key or value from a map entry message,
+// substituting the zero value when it was omitted on the wire
+func decodeEntryField(entry *WireMessage, field FieldNum, pbtype descriptor.FieldDescriptorProto_Type) (interface{}, error) {
+	v, err := entry.DecodeAs(field, pbtype)
+	if err == ErrMessageFieldMissing {
+		return zeroValue(pbtype), nil
+	}
+	return v, err
+}
+
+// decode assembles all entry messages for field in m into a Go map
+func (e mapEntryType) decode(m *WireMessage, field FieldNum) (map[interface{}]interface{}, error) {
+	entries := m.GetRepeatedBytes(field)
+	result := make(map[interface{}]interface{}, len(entries))
+	for _, buf := range entries {
+		entry, err := Unmarshal(buf)
+		if err != nil {
+			return nil, err
+		}
+		m.inherit(entry)
+		k, err := decodeEntryField(entry, mapEntryKeyField, e.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decodeEntryField(entry, mapEntryValueField, e.value)
+		if err != nil {
+			return nil, err
+		}
+		if wm, ok := v.(*WireMessage); ok && e.valueMsg != nil {
+			if v, err = e.valueMsg.DecodeMessage(wm); err != nil {
+				return nil, err
+			}
+		}
+		// Later entries with the same key win
+		result[k] = v
+	}
+	return result, nil
+}
+
+// rawEntries decodes the keys and values of all entries for field in m,
+// without applying the value schema. Nested messages stay WireMessages.
+// The keys are returned in sorted order.
+func (e mapEntryType) rawEntries(m *WireMessage, field FieldNum) ([]interface{}, map[interface{}]interface{}, error) {
+	entries := make(map[interface{}]interface{})
+	for _, buf := range m.GetRepeatedBytes(field) {
+		entry, err := Unmarshal(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		m.inherit(entry)
+		k, err := decodeEntryField(entry, mapEntryKeyField, e.key)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := decodeEntryField(entry, mapEntryValueField, e.value)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Later entries with the same key win
+		entries[k] = v
+	}
+
+	keys := make([]interface{}, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessMapKey(keys[i], keys[j]) })
+	return keys, entries, nil
+}
+
+// encode adds one entry message to m for every key in value.
+// Entries are emitted in sorted key order, so that the output is
+// reproducible. Every key must have the Go type of the key type, which
+// is checked before sorting.
+func (e mapEntryType) encode(m *WireMessage, field FieldNum, value interface{}) error {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Map {
+		return ErrInvalidProtoBufType
+	}
+
+	type mapEntry struct {
+		key   interface{}
+		rkey  reflect.Value
+		entry *WireMessage
+	}
+	entries := make([]mapEntry, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		entry := NewWireMessage()
+		m.inherit(entry)
+		key := k.Interface()
+		if err := entry.EncodeAs(mapEntryKeyField, key, e.key); err != nil {
+			if errors.Is(err, ErrInvalidProtoBufType) {
+				return fmt.Errorf("%w: map key %v (%T) is not a %s", ErrInvalidProtoBufType, key, key, typeString(e.key))
+			}
+			return err
+		}
+		entries = append(entries, mapEntry{key, k, entry})
+	}
+	sort.Slice(entries, func(i, j int) bool { return lessMapKey(entries[i].key, entries[j].key) })
+
+	// Clear any previous value, since we only append below
+	m.Remove(field)
+	for _, me := range entries {
+		entry := me.entry
+		v := rv.MapIndex(me.rkey).Interface()
+		if vals, ok := v.([]FieldValue); ok && e.valueMsg != nil {
+			wm, err := e.valueMsg.encodeMessage(vals, m.mode)
+			if err != nil {
+				return err
+			}
+			v = wm
+		}
+		if name, ok := v.(string); ok && e.valueEnum != nil {
+			number, ok := e.valueEnum.GetNumber(name)
+			if !ok {
+				return fmt.Errorf("%w: unknown value %q for enum %s", ErrInvalidProtoBufType, name, e.valueEnum.Name())
+			}
+			v = uint64(int64(number))
+		}
+		if err := entry.EncodeAs(mapEntryValueField, v, e.value); err != nil {
+			return err
+		}
+		buf, err := entry.Marshal()
+		if err != nil {
+			return err
+		}
+		m.AppendBytes(field, buf)
+	}
+	return nil
+}
+
+// lessMapKey orders two map keys of the same Protobuf key type.
+// Keys of differing Go types are ordered by their type name.
+func lessMapKey(a, b interface{}) bool {
+	switch x := a.(type) {
+	case int32:
+		if y, ok := b.(int32); ok {
+			return x < y
+		}
+	case int64:
+		if y, ok := b.(int64); ok {
+			return x < y
+		}
+	case uint32:
+		if y, ok := b.(uint32); ok {
+			return x < y
+		}
+	case uint64:
+		if y, ok := b.(uint64); ok {
+			return x < y
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			return !x && y
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return x < y
+		}
+	}
+	return reflect.TypeOf(a).String() < reflect.TypeOf(b).String()
+}
+
+// schema builds the schema of the entry messages, with the fields "key" and
+// "value"
+func (e mapEntryType) schema() *ProtoFieldMap {
+	fm := NewProtoFieldMap()
+	fm.Add(mapEntryKeyField, e.key)
+	fm.SetFieldName(mapEntryKeyField, "key")
+	switch {
+	case e.valueMsg != nil:
+		fm.AddMessage(mapEntryValueField, e.valueMsg)
+	case e.valueEnum != nil:
+		fm.AddEnum(mapEntryValueField, e.valueEnum)
+	default:
+		fm.Add(mapEntryValueField, e.value)
+	}
+	fm.SetFieldName(mapEntryValueField, "value")
+	return fm
+}
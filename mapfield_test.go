@@ -0,0 +1,126 @@
+package dproto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestMapFieldRoundTrip(t *testing.T) {
+	fm := NewProtoFieldMap()
+	if !fm.AddMap(3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_INT64) {
+		t.Fatal("Failed to add map field 3")
+	}
+
+	values := []FieldValue{
+		{
+			Field: 3,
+			Value: map[string]int64{"b": 2, "a": 1, "c": -3},
+		},
+	}
+
+	buf, err := fm.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+
+	// Encoding must be reproducible, regardless of Go's map ordering
+	for i := 0; i < 10; i++ {
+		again, err := fm.EncodeBuffer(values)
+		if err != nil {
+			t.Fatal("Error Encoding: " + err.Error())
+		}
+		if !bytes.Equal(buf, again) {
+			t.Fatalf("Map encoding is not deterministic: [% x] != [% x]", buf, again)
+		}
+	}
+
+	decoded, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal("Error Decoding: " + err.Error())
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("Expected 1 decoded field, got %d", len(decoded))
+	}
+	m, ok := decoded[0].Value.(map[interface{}]interface{})
+	if !ok {
+		t.Fatalf("Decoded map has wrong type %T", decoded[0].Value)
+	}
+	expected := map[string]int64{"a": 1, "b": 2, "c": -3}
+	if len(m) != len(expected) {
+		t.Fatalf("Decoded map has %d entries, expected %d", len(m), len(expected))
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Errorf("Decoded map[%q] = %v, expected %v", k, m[k], v)
+		}
+	}
+}
+
+func TestMapFieldMissingKeyValue(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.AddMap(1, descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_STRING)
+
+	// An entry with only a value, and an entry with only a key
+	m := NewWireMessage()
+	onlyValue := NewWireMessage()
+	onlyValue.EncodeString(mapEntryValueField, "zero")
+	onlyKey := NewWireMessage()
+	onlyKey.EncodeUint32(mapEntryKeyField, 7)
+	for _, e := range []*WireMessage{onlyValue, onlyKey} {
+		b, err := e.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.AppendBytes(1, b)
+	}
+
+	decoded, err := fm.DecodeMessage(m)
+	if err != nil {
+		t.Fatal("Error Decoding: " + err.Error())
+	}
+	result := decoded[0].Value.(map[interface{}]interface{})
+	if result[uint32(0)] != "zero" {
+		t.Errorf("Expected missing key to default to 0, got %v", result)
+	}
+	if result[uint32(7)] != "" {
+		t.Errorf("Expected missing value to default to \"\", got %v", result)
+	}
+}
+
+func TestAddMapInvalidKey(t *testing.T) {
+	fm := NewProtoFieldMap()
+	if fm.AddMap(1, descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_INT32) {
+		t.Error("double should not be accepted as a map key type")
+	}
+	if fm.AddMap(1, descriptor.FieldDescriptorProto_TYPE_BYTES, descriptor.FieldDescriptorProto_TYPE_INT32) {
+		t.Error("bytes should not be accepted as a map key type")
+	}
+}
+
+func TestMapFieldInvalidKeys(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.AddMap(1, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_INT64)
+
+	tests := []interface{}{
+		map[interface{}]interface{}{nil: int64(1), "a": int64(2)},
+		map[interface{}]interface{}{int32(1): int64(1), "a": int64(2)},
+		map[int32]int64{1: 1},
+	}
+	for i, v := range tests {
+		if _, err := fm.EncodeMessage([]FieldValue{{Field: 1, Value: v}}); !errors.Is(err, ErrInvalidProtoBufType) {
+			t.Errorf("Test %d: expected ErrInvalidProtoBufType, got %v", i, err)
+		}
+	}
+
+	// Keys in an interface map are fine, as long as they have the key type
+	buf, err := fm.EncodeBuffer([]FieldValue{{Field: 1, Value: map[interface{}]interface{}{"b": int64(2), "a": int64(1)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte{0x0a, 0x05, 0x0a, 0x01, 'a', 0x10, 0x01, 0x0a, 0x05, 0x0a, 0x01, 'b', 0x10, 0x02}) {
+		t.Errorf("Unexpected encoding % x", buf)
+	}
+}
@@ -0,0 +1,143 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file adds support for Protobuf oneof groups to the ProtoFieldMap.
+// A oneof is just a set of regular fields where at most one may be set.
+// On the wire, the member that appears last wins.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOneofConflict is returned when more than one member of the same oneof
+// is given to be encoded
+var ErrOneofConflict = errors.New("Multiple members of oneof set")
+
+// AddOneof groups the specified fields into the oneof called name.
+// All fields must already be associated with a type, must not be map fields
+// and must not belong to another oneof.
+// It returns true if the oneof was added, false otherwise.
+func (fm *ProtoFieldMap) AddOneof(name string, fields ...FieldNum) bool {
+	fm.checkMutable()
+	if name == "" || len(fields) == 0 {
+		return false
+	}
+	if _, exists := fm.oneofs[name]; exists {
+		return false
+	}
+	for _, f := range fields {
+		if _, ok := fm.field2type[f]; !ok {
+			return false
+		}
+		if _, isMap := fm.maps[f]; isMap {
+			return false
+		}
+		if _, taken := fm.field2oneof[f]; taken {
+			return false
+		}
+	}
+
+	members := make([]FieldNum, len(fields))
+	copy(members, fields)
+	fm.oneofs[name] = members
+	for _, f := range members {
+		fm.field2oneof[f] = name
+	}
+	return true
+}
+
+// GetOneof gets the member fields of the oneof called name
+func (fm *ProtoFieldMap) GetOneof(name string) ([]FieldNum, bool) {
+	fields, ok := fm.oneofs[name]
+	return append([]FieldNum(nil), fields...), ok
+}
+
+// GetOneofByField gets the name of the oneof that field belongs to
+func (fm *ProtoFieldMap) GetOneofByField(field FieldNum) (string, bool) {
+	name, ok := fm.field2oneof[field]
+	return name, ok
+}
+
+// RemoveOneof ungroups the oneof called name. The member fields themselves
+// stay associated.
+// It returns true if the oneof was found and removed, false otherwise
+func (fm *ProtoFieldMap) RemoveOneof(name string) bool {
+	fm.checkMutable()
+	fields, ok := fm.oneofs[name]
+	if !ok {
+		return false
+	}
+	for _, f := range fields {
+		delete(fm.field2oneof, f)
+	}
+	delete(fm.oneofs, name)
+	return true
+}
+
+// removeFromOneof drops field from whatever oneof it belongs to.
+// A oneof left without members is removed.
+func (fm *ProtoFieldMap) removeFromOneof(field FieldNum) {
+	name, ok := fm.field2oneof[field]
+	if !ok {
+		return
+	}
+	delete(fm.field2oneof, field)
+
+	members := fm.oneofs[name][:0:0]
+	for _, f := range fm.oneofs[name] {
+		if f != field {
+			members = append(members, f)
+		}
+	}
+	if len(members) == 0 {
+		delete(fm.oneofs, name)
+	} else {
+		fm.oneofs[name] = members
+	}
+}
+
+// WhichOneof reports the active member of the oneof called name in
+// message m. When several members are present, the one that occurred
+// last in m wins, following Protobuf's parsing rules.
+// It returns false if the oneof is unknown or no member is present.
+func (fm *ProtoFieldMap) WhichOneof(m *WireMessage, name string) (FieldNum, bool) {
+	var active FieldNum
+	var activeSeq uint64
+	found := false
+	for _, f := range fm.oneofs[name] {
+		if seq, ok := m.LastOccurrence(f); ok && (!found || seq > activeSeq) {
+			active, activeSeq, found = f, seq, true
+		}
+	}
+	return active, found
+}
+
+// oneofWinner indicates if field should be decoded from m, which is false
+// when field lost to another member of its oneof
+func (fm *ProtoFieldMap) oneofWinner(m *WireMessage, field FieldNum) bool {
+	name, ok := fm.field2oneof[field]
+	if !ok {
+		return true
+	}
+	active, _ := fm.WhichOneof(m, name)
+	return active == field
+}
+
+// checkOneofs verifies that values sets at most one member of each oneof
+func (fm *ProtoFieldMap) checkOneofs(values []FieldValue) error {
+	set := make(map[string]FieldNum)
+	for _, v := range values {
+		name, ok := fm.field2oneof[v.Field]
+		if !ok {
+			continue
+		}
+		if other, dup := set[name]; dup && other != v.Field {
+			return fmt.Errorf("%w: oneof %s has fields %d and %d set", ErrOneofConflict, name, other, v.Field)
+		}
+		set[name] = v.Field
+	}
+	return nil
+}
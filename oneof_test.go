@@ -0,0 +1,90 @@
+package dproto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func newOneofTestMap(t *testing.T) *ProtoFieldMap {
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	for f := FieldNum(4); f <= 7; f++ {
+		fm.Add(f, descriptor.FieldDescriptorProto_TYPE_INT64)
+	}
+	if !fm.AddOneof("choice", 4, 5, 6, 7) {
+		t.Fatal("Failed to add oneof choice")
+	}
+	return fm
+}
+
+func TestOneofLastWins(t *testing.T) {
+	fm := newOneofTestMap(t)
+
+	// Field 4 arrives after field 6 on the wire, so the lower numbered
+	// member must win
+	m, err := Unmarshal([]byte{
+		0x08, 0x01, // 1: true
+		0x30, 0x3c, // 6: 60
+		0x20, 0x28, // 4: 40
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active, ok := fm.WhichOneof(m, "choice")
+	if !ok || active != 4 {
+		t.Fatalf("Expected active member 4, got %d (%v)", active, ok)
+	}
+
+	values, err := fm.DecodeMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("Expected 2 decoded fields, got %v", values)
+	}
+	for _, v := range values {
+		if v.Field == 6 {
+			t.Error("Overridden oneof member 6 was decoded")
+		}
+		if v.Field == 4 && v.Value != int64(40) {
+			t.Errorf("Expected 40 for member 4, got %v", v.Value)
+		}
+	}
+}
+
+func TestOneofEncodeConflict(t *testing.T) {
+	fm := newOneofTestMap(t)
+
+	_, err := fm.EncodeBuffer([]FieldValue{
+		{Field: 4, Value: int64(1)},
+		{Field: 7, Value: int64(2)},
+	})
+	if !errors.Is(err, ErrOneofConflict) {
+		t.Fatalf("Expected ErrOneofConflict, got %v", err)
+	}
+
+	if _, err := fm.EncodeBuffer([]FieldValue{
+		{Field: 1, Value: true},
+		{Field: 5, Value: int64(2)},
+	}); err != nil {
+		t.Fatalf("Single oneof member rejected: %v", err)
+	}
+}
+
+func TestOneofRemoveField(t *testing.T) {
+	fm := newOneofTestMap(t)
+	if fm.AddOneof("other", 4) {
+		t.Error("Field 4 was allowed in two oneofs")
+	}
+	fm.RemoveByField(4)
+	if _, ok := fm.GetOneofByField(4); ok {
+		t.Error("Removed field still belongs to oneof")
+	}
+	fields, _ := fm.GetOneof("choice")
+	if len(fields) != 3 {
+		t.Errorf("Expected 3 remaining members, got %v", fields)
+	}
+}
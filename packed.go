@@ -0,0 +1,135 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the packed encoding of repeated scalar fields, where all
+// values are written back to back in a single length delimited occurrence.
+// Proto3 packs repeated scalars by default, proto2 only with [packed = true].
+
+package dproto
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// packableType indicates if repeated fields of typ may be packed, which is
+// the case for all scalar types except strings and bytes
+func packableType(typ descriptor.FieldDescriptorProto_Type) bool {
+	wire, ok := protoType2WireType[typ]
+	return ok && wire != proto.WireBytes
+}
+
+// SetPacked sets if a repeated scalar field is encoded in the packed form.
+// Decoding accepts both forms either way.
+// It returns false if the field is unknown or its type can not be packed.
+func (fm *ProtoFieldMap) SetPacked(field FieldNum, packed bool) bool {
+	fm.checkMutable()
+	if typ, ok := fm.field2type[field]; !ok || !packableType(typ) {
+		return false
+	}
+	if packed {
+		fm.packed[field] = true
+	} else {
+		delete(fm.packed, field)
+	}
+	return true
+}
+
+// IsPacked indicates if field is a repeated field encoded in the packed form
+func (fm *ProtoFieldMap) IsPacked(field FieldNum) bool {
+	return fm.packed[field] && fm.field2label[field] == descriptor.FieldDescriptorProto_LABEL_REPEATED
+}
+
+// appendPackedAs adds values to m as one packed occurrence of field, keeping
+// any previous occurrences. Nothing is added if values is empty.
+func (m *WireMessage) appendPackedAs(field FieldNum, values []interface{}, pbtype descriptor.FieldDescriptorProto_Type) error {
+	if len(values) == 0 {
+		return nil
+	}
+	single := NewWireMessage()
+	single.mode = m.mode
+	for _, v := range values {
+		if err := single.AppendAs(field, v, pbtype); err != nil {
+			return err
+		}
+	}
+
+	pbuf := proto.NewBuffer(nil)
+	for _, v := range single.varint[field] {
+		if err := pbuf.EncodeVarint(uint64(v)); err != nil {
+			return err
+		}
+	}
+	for _, v := range single.fixed32[field] {
+		if err := pbuf.EncodeFixed32(uint64(v)); err != nil {
+			return err
+		}
+	}
+	for _, v := range single.fixed64[field] {
+		if err := pbuf.EncodeFixed64(uint64(v)); err != nil {
+			return err
+		}
+	}
+	if len(single.bytes[field]) > 0 {
+		return ErrInvalidProtoBufType
+	}
+	m.AppendBytes(field, pbuf.Bytes())
+	return nil
+}
+
+// appendPacked decodes the packed run b of a repeated scalar field, which
+// holds several values of the given wire type, and appends them to vals
+func (m *WireMessage) appendPacked(vals []interface{}, field FieldNum, b []byte, wire WireType, pbtype descriptor.FieldDescriptorProto_Type) ([]interface{}, error) {
+	for len(b) > 0 {
+		switch wire {
+		case proto.WireVarint:
+			u, n := proto.DecodeVarint(b)
+			if n == 0 {
+				return nil, ErrMalformedProtoBuf
+			}
+			if pbtype == descriptor.FieldDescriptorProto_TYPE_INT32 {
+				if err := m.checkInt32(field, WireVarint(u)); err != nil {
+					return nil, err
+				}
+			}
+			vals = append(vals, varintAs(WireVarint(u), pbtype))
+			b = b[n:]
+		case proto.WireFixed32:
+			if len(b) < 4 {
+				return nil, ErrMalformedProtoBuf
+			}
+			vals = append(vals, fixed32As(WireFixed32(binary.LittleEndian.Uint32(b)), pbtype))
+			b = b[4:]
+		case proto.WireFixed64:
+			if len(b) < 8 {
+				return nil, ErrMalformedProtoBuf
+			}
+			vals = append(vals, fixed64As(WireFixed64(binary.LittleEndian.Uint64(b)), pbtype))
+			b = b[8:]
+		default:
+			return nil, ErrInvalidProtoBufType
+		}
+	}
+	return vals, nil
+}
+
+// packedLen returns the number of values in the packed run b of the given
+// wire type. A truncated last value is not counted.
+func packedLen(b []byte, wire WireType) int {
+	switch wire {
+	case proto.WireFixed32:
+		return len(b) / 4
+	case proto.WireFixed64:
+		return len(b) / 8
+	}
+	// Every varint ends in a byte without the continuation bit
+	n := 0
+	for _, c := range b {
+		if c < 0x80 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,163 @@
+package dproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// packedTestMessage is encoded by golang/protobuf, to cross-check the packed
+// bytes dproto writes
+type packedTestMessage struct {
+	Packed   []int32   `protobuf:"varint,1,rep,packed,name=packed"`
+	Unpacked []int32   `protobuf:"varint,2,rep,name=unpacked"`
+	Doubles  []float64 `protobuf:"fixed64,3,rep,packed,name=doubles"`
+}
+
+func (m *packedTestMessage) Reset()         { *m = packedTestMessage{} }
+func (m *packedTestMessage) String() string { return proto.CompactTextString(m) }
+func (*packedTestMessage) ProtoMessage()    {}
+
+func packedTestFile(syntax string, unpacked *descriptor.FieldOptions) *descriptor.FileDescriptorProto {
+	repeated := descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("packed.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String(syntax),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Packed"),
+			Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("packed"), Number: proto.Int32(1), Label: repeated, Type: descriptor.FieldDescriptorProto_TYPE_INT32.Enum()},
+				{Name: proto.String("unpacked"), Number: proto.Int32(2), Label: repeated, Type: descriptor.FieldDescriptorProto_TYPE_INT32.Enum(), Options: unpacked},
+				{Name: proto.String("doubles"), Number: proto.Int32(3), Label: repeated, Type: descriptor.FieldDescriptorProto_TYPE_DOUBLE.Enum()},
+				{Name: proto.String("names"), Number: proto.Int32(4), Label: repeated, Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+			},
+		}},
+	}
+}
+
+func TestPackedFromDescriptor(t *testing.T) {
+	r := NewRegistry()
+	if err := r.AddFiles(packedTestFile("proto3", &descriptor.FieldOptions{Packed: proto.Bool(false)})); err != nil {
+		t.Fatal(err)
+	}
+	fm, ok := r.GetMessage("test.Packed")
+	if !ok {
+		t.Fatal("test.Packed was not loaded")
+	}
+	if !fm.IsPacked(1) || fm.IsPacked(2) || !fm.IsPacked(3) || fm.IsPacked(4) {
+		t.Errorf("Unexpected proto3 packing %v %v %v %v", fm.IsPacked(1), fm.IsPacked(2), fm.IsPacked(3), fm.IsPacked(4))
+	}
+
+	r = NewRegistry()
+	if err := r.AddFiles(packedTestFile("proto2", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if fm, _ := r.GetMessage("test.Packed"); fm.IsPacked(1) || fm.IsPacked(3) {
+		t.Error("proto2 fields should not be packed by default")
+	}
+}
+
+func TestPackedMatchesGolangProtobuf(t *testing.T) {
+	r := NewRegistry()
+	if err := r.AddFiles(packedTestFile("proto3", &descriptor.FieldOptions{Packed: proto.Bool(false)})); err != nil {
+		t.Fatal(err)
+	}
+	gm := &packedTestMessage{
+		Packed:   []int32{1, 150, -1},
+		Unpacked: []int32{2, 300},
+		Doubles:  []float64{0.5, -2},
+	}
+	expected, err := proto.Marshal(gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := []FieldValue{
+		{Field: 1, Value: []int32{1, 150, -1}},
+		{Field: 2, Value: []int32{2, 300}},
+		{Field: 3, Value: []float64{0.5, -2}},
+	}
+	buf, err := r.Encode("test.Packed", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, expected) {
+		t.Errorf("Expected % x, got % x", expected, buf)
+	}
+
+	// Both forms decode, whatever the schema says
+	gm.Packed, gm.Unpacked = gm.Unpacked, gm.Packed
+	swapped, err := proto.Marshal(gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := r.Decode("test.Packed", swapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range decoded {
+		var expected interface{}
+		switch v.Field {
+		case 1:
+			expected = []interface{}{int32(2), int32(300)}
+		case 2:
+			expected = []interface{}{int32(1), int32(150), int32(-1)}
+		case 3:
+			expected = []interface{}{0.5, -2.0}
+		}
+		if !reflect.DeepEqual(v.Value, expected) {
+			t.Errorf("Field %d: expected %#v, got %#v", v.Field, expected, v.Value)
+		}
+	}
+}
+
+func TestPackedSchema(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_SINT64)
+	fm.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	fm.SetFieldName(1, "offsets")
+	fm.Add(2, descriptor.FieldDescriptorProto_TYPE_STRING)
+	if !fm.SetPacked(1, true) {
+		t.Fatal("Failed to pack sint64 field")
+	}
+	if fm.SetPacked(2, true) {
+		t.Error("Strings can not be packed")
+	}
+
+	var text strings.Builder
+	if err := fm.WriteProto(&text, "Offsets"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "repeated sint64 offsets = 1 [packed = true];") {
+		t.Errorf("Packed option missing from:\n%s", text.String())
+	}
+	if !fm.ToDescriptor().Field[0].GetOptions().GetPacked() {
+		t.Error("Packed option missing from the descriptor")
+	}
+
+	data, err := json.Marshal(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewProtoFieldMap()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.IsPacked(1) {
+		t.Errorf("Packed flag lost in %s", data)
+	}
+
+	buf, err := loaded.EncodeBuffer([]FieldValue{{Field: 1, Value: []int64{-1, 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte{0x0a, 0x02, 0x01, 0x04}) {
+		t.Errorf("Expected packed encoding, got % x", buf)
+	}
+}
@@ -0,0 +1,359 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the tokenizer for .proto source files.
+
+package protoparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position identifies a location in a .proto source file
+type Position struct {
+	Filename string
+	Line     int // starting at 1
+	Col      int // starting at 1, counted in runes
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Col)
+}
+
+// ParseError describes a problem found in a .proto source file and where
+// it was found
+type ParseError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// errorf creates a new ParseError at pos
+func errorf(pos Position, format string, args ...interface{}) *ParseError {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokFloat
+	tokString
+	tokPunct
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of file"
+	case tokIdent:
+		return "identifier"
+	case tokInt:
+		return "integer"
+	case tokFloat:
+		return "float"
+	case tokString:
+		return "string"
+	}
+	return "punctuation"
+}
+
+// token is a single lexical element. For strings, text holds the
+// unescaped value. For everything else, it holds the source text.
+type token struct {
+	kind tokenKind
+	text string
+	pos  Position
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return t.kind.String()
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return "\"" + t.text + "\""
+}
+
+// lexer splits a .proto source into tokens
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+	file string
+}
+
+// tokenize splits all of src into tokens, ending with a tokEOF
+func tokenize(filename, src string) ([]token, error) {
+	l := &lexer{src: src, line: 1, col: 1, file: filename}
+	var toks []token
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		toks = append(toks, t)
+		if t.kind == tokEOF {
+			return toks, nil
+		}
+	}
+}
+
+func (l *lexer) pos() Position {
+	return Position{Filename: l.file, Line: l.line, Col: l.col}
+}
+
+// peek returns the byte at offset i from the current position, or 0
+func (l *lexer) peek(i int) byte {
+	if l.off+i < len(l.src) {
+		return l.src[l.off+i]
+	}
+	return 0
+}
+
+// advance moves forward one rune, keeping track of lines and columns
+func (l *lexer) advance() {
+	if l.off >= len(l.src) {
+		return
+	}
+	if l.src[l.off] == '\n' {
+		l.line++
+		l.col = 1
+		l.off++
+		return
+	}
+	_, size := utf8.DecodeRuneInString(l.src[l.off:])
+	l.off += size
+	l.col++
+}
+
+// skipSpace skips over whitespace and comments
+func (l *lexer) skipSpace() error {
+	for l.off < len(l.src) {
+		c := l.peek(0)
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == '\v':
+			l.advance()
+		case c == '/' && l.peek(1) == '/':
+			for l.off < len(l.src) && l.peek(0) != '\n' {
+				l.advance()
+			}
+		case c == '/' && l.peek(1) == '*':
+			start := l.pos()
+			l.advance()
+			l.advance()
+			for !(l.peek(0) == '*' && l.peek(1) == '/') {
+				if l.off >= len(l.src) {
+					return errorf(start, "unterminated block comment")
+				}
+				l.advance()
+			}
+			l.advance()
+			l.advance()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// next scans the next token
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	start := l.pos()
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	c := l.peek(0)
+	switch {
+	case isLetter(c):
+		begin := l.off
+		for isLetter(l.peek(0)) || isDigit(l.peek(0)) {
+			l.advance()
+		}
+		return token{kind: tokIdent, text: l.src[begin:l.off], pos: start}, nil
+
+	case isDigit(c) || (c == '.' && isDigit(l.peek(1))):
+		return l.number(start)
+
+	case c == '"' || c == '\'':
+		return l.str(start)
+	}
+
+	if strings.IndexByte("=;{}[]()<>,.-+:/", c) < 0 {
+		r, _ := utf8.DecodeRuneInString(l.src[l.off:])
+		return token{}, errorf(start, "unexpected character %q", r)
+	}
+	l.advance()
+	return token{kind: tokPunct, text: string(c), pos: start}, nil
+}
+
+// number scans an integer or floating point literal
+func (l *lexer) number(start Position) (token, error) {
+	begin := l.off
+	kind := tokInt
+
+	if l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') {
+		l.advance()
+		l.advance()
+		if !isHexDigit(l.peek(0)) {
+			return token{}, errorf(start, "invalid hex literal")
+		}
+		for isHexDigit(l.peek(0)) {
+			l.advance()
+		}
+	} else {
+		for isDigit(l.peek(0)) {
+			l.advance()
+		}
+		if l.peek(0) == '.' {
+			kind = tokFloat
+			l.advance()
+			for isDigit(l.peek(0)) {
+				l.advance()
+			}
+		}
+		if c := l.peek(0); c == 'e' || c == 'E' {
+			kind = tokFloat
+			l.advance()
+			if c := l.peek(0); c == '+' || c == '-' {
+				l.advance()
+			}
+			if !isDigit(l.peek(0)) {
+				return token{}, errorf(start, "invalid float literal")
+			}
+			for isDigit(l.peek(0)) {
+				l.advance()
+			}
+		}
+	}
+
+	if isLetter(l.peek(0)) {
+		return token{}, errorf(start, "invalid number literal")
+	}
+	return token{kind: kind, text: l.src[begin:l.off], pos: start}, nil
+}
+
+// str scans a quoted string literal, interpreting escape sequences
+func (l *lexer) str(start Position) (token, error) {
+	quote := l.peek(0)
+	l.advance()
+
+	var sb strings.Builder
+	for {
+		if l.off >= len(l.src) || l.peek(0) == '\n' {
+			return token{}, errorf(start, "unterminated string literal")
+		}
+		c := l.peek(0)
+		if c == quote {
+			l.advance()
+			return token{kind: tokString, text: sb.String(), pos: start}, nil
+		}
+		if c != '\\' {
+			_, size := utf8.DecodeRuneInString(l.src[l.off:])
+			sb.WriteString(l.src[l.off : l.off+size])
+			l.advance()
+			continue
+		}
+
+		escPos := l.pos()
+		l.advance()
+		c = l.peek(0)
+		switch c {
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'v':
+			sb.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			sb.WriteByte(c)
+		case 'x', 'X':
+			l.advance()
+			n := 0
+			for n < 2 && isHexDigit(l.peek(n)) {
+				n++
+			}
+			if n == 0 {
+				return token{}, errorf(escPos, "invalid hex escape")
+			}
+			v, _ := strconv.ParseUint(l.src[l.off:l.off+n], 16, 8)
+			sb.WriteByte(byte(v))
+			for ; n > 0; n-- {
+				l.advance()
+			}
+			continue
+		case 'u', 'U':
+			digits := 4
+			if c == 'U' {
+				digits = 8
+			}
+			l.advance()
+			for i := 0; i < digits; i++ {
+				if !isHexDigit(l.peek(i)) {
+					return token{}, errorf(escPos, "invalid unicode escape")
+				}
+			}
+			v, _ := strconv.ParseUint(l.src[l.off:l.off+digits], 16, 32)
+			if !utf8.ValidRune(rune(v)) {
+				return token{}, errorf(escPos, "invalid unicode code point")
+			}
+			sb.WriteRune(rune(v))
+			for i := 0; i < digits; i++ {
+				l.advance()
+			}
+			continue
+		default:
+			if c < '0' || c > '7' {
+				return token{}, errorf(escPos, "invalid escape sequence \\%c", c)
+			}
+			n := 0
+			for n < 3 && l.peek(n) >= '0' && l.peek(n) <= '7' {
+				n++
+			}
+			v, _ := strconv.ParseUint(l.src[l.off:l.off+n], 8, 16)
+			if v > 255 {
+				return token{}, errorf(escPos, "octal escape out of range")
+			}
+			sb.WriteByte(byte(v))
+			for ; n > 0; n-- {
+				l.advance()
+			}
+			continue
+		}
+		l.advance()
+	}
+}
@@ -0,0 +1,310 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the Parser, which loads .proto files and their imports,
+// and the linker, which resolves the type names used between them.
+
+package protoparse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/linux4life798/dproto"
+)
+
+// Parser parses .proto files, along with everything they import
+type Parser struct {
+	// ImportPaths are the directories searched for the files to parse and
+	// their imports, in order. The current directory is used if empty.
+	ImportPaths []string
+
+	// Accessor optionally replaces how files are opened. It is given the
+	// file name exactly as written in the import statement.
+	Accessor func(filename string) (io.ReadCloser, error)
+}
+
+// Parse parses a single .proto file read from r. The file must not import
+// any other files.
+func Parse(filename string, r io.Reader) (*descriptor.FileDescriptorProto, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := Parser{
+		Accessor: func(name string) (io.ReadCloser, error) {
+			if name != filename {
+				return nil, os.ErrNotExist
+			}
+			return ioutil.NopCloser(bytes.NewReader(src)), nil
+		},
+	}
+	fds, err := p.ParseFiles(filename)
+	if err != nil {
+		return nil, err
+	}
+	return fds[0], nil
+}
+
+// ParseFiles parses the named .proto files and all files they import.
+// The returned descriptors are fully linked, meaning all type names are
+// fully qualified, just like protoc's output. Imported files come before the
+// files importing them.
+func (p Parser) ParseFiles(filenames ...string) ([]*descriptor.FileDescriptorProto, error) {
+	l := &loader{parser: p, files: make(map[string]*parsedFile), loading: make(map[string]bool)}
+	for _, name := range filenames {
+		if err := l.load(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := link(l.order); err != nil {
+		return nil, err
+	}
+
+	fds := make([]*descriptor.FileDescriptorProto, len(l.order))
+	for i, f := range l.order {
+		fds[i] = f.fd
+	}
+	return fds, nil
+}
+
+// ParseFileDescriptorSet parses the named .proto files and returns them,
+// along with all of their imports, as a FileDescriptorSet
+func (p Parser) ParseFileDescriptorSet(filenames ...string) (*descriptor.FileDescriptorSet, error) {
+	fds, err := p.ParseFiles(filenames...)
+	if err != nil {
+		return nil, err
+	}
+	return &descriptor.FileDescriptorSet{File: fds}, nil
+}
+
+// LoadRegistry parses the named .proto files and loads all of their
+// messages and enums, along with those of their imports, into a new
+// dproto.Registry
+func (p Parser) LoadRegistry(filenames ...string) (*dproto.Registry, error) {
+	fds, err := p.ParseFiles(filenames...)
+	if err != nil {
+		return nil, err
+	}
+	r := dproto.NewRegistry()
+	if err := r.AddFiles(fds...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParseInDir is like ParseFiles, but searches dir before the ImportPaths.
+// It can be given to dproto.NewRegistryWatcher to load .proto files.
+func (p Parser) ParseInDir(dir string, filenames []string) ([]*descriptor.FileDescriptorProto, error) {
+	if p.Accessor == nil {
+		p.ImportPaths = append([]string{dir}, p.ImportPaths...)
+	}
+	return p.ParseFiles(filenames...)
+}
+
+// open opens the named file using the Accessor or the ImportPaths
+func (p Parser) open(name string) (io.ReadCloser, error) {
+	if p.Accessor != nil {
+		return p.Accessor(name)
+	}
+	paths := p.ImportPaths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var firstErr error
+	for _, dir := range paths {
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// loader parses files depth first, so that imports are ordered before
+// the files that import them
+type loader struct {
+	parser  Parser
+	files   map[string]*parsedFile
+	loading map[string]bool
+	order   []*parsedFile
+}
+
+// load parses the named file and its imports. importedAt is the position
+// of the import statement, if any.
+func (l *loader) load(name string, importedAt *Position) error {
+	if _, done := l.files[name]; done {
+		return nil
+	}
+	at := Position{Filename: name}
+	if importedAt != nil {
+		at = *importedAt
+	}
+	if l.loading[name] {
+		return errorf(at, "import cycle involving %s", name)
+	}
+
+	r, err := l.parser.open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errorf(at, "file not found: %s", name)
+		}
+		return errorf(at, "%v", err)
+	}
+	src, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return errorf(at, "%v", err)
+	}
+
+	f, err := parseFile(name, string(src))
+	if err != nil {
+		return err
+	}
+
+	l.loading[name] = true
+	for i, dep := range f.fd.Dependency {
+		if err := l.load(dep, &f.imports[i]); err != nil {
+			return err
+		}
+	}
+	delete(l.loading, name)
+
+	l.files[name] = f
+	l.order = append(l.order, f)
+	return nil
+}
+
+// symbol is a message or enum declared in some file
+type symbol struct {
+	file   *parsedFile
+	isEnum bool
+}
+
+// link resolves every type reference in files, which must be ordered so
+// that imports come first
+func link(files []*parsedFile) error {
+	symbols := make(map[string]symbol)
+	byName := make(map[string]*parsedFile, len(files))
+	for _, f := range files {
+		byName[f.fd.GetName()] = f
+		pkg := f.fd.GetPackage()
+		for _, msg := range f.fd.MessageType {
+			if err := declareMessage(symbols, f, pkg, msg); err != nil {
+				return err
+			}
+		}
+		for _, e := range f.fd.EnumType {
+			if err := declare(symbols, f, qualify(pkg, e.GetName()), true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		visible := visibleFiles(f, byName)
+		pkg := f.fd.GetPackage()
+		for _, ref := range f.refs {
+			full, sym, ok := resolve(symbols, qualify(pkg, ref.scope), ref.name)
+			if !ok || !visible[sym.file] {
+				return errorf(ref.pos, "unknown type %s", ref.name)
+			}
+			if ref.field != nil {
+				ref.field.TypeName = proto.String("." + full)
+				if sym.isEnum {
+					ref.field.Type = descriptor.FieldDescriptorProto_TYPE_ENUM.Enum()
+				} else {
+					ref.field.Type = descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				}
+				continue
+			}
+			if sym.isEnum {
+				return errorf(ref.pos, "%s is an enum, expected a message", ref.name)
+			}
+			*ref.target = proto.String("." + full)
+		}
+	}
+	return nil
+}
+
+// declare adds a fully qualified name to the symbol table
+func declare(symbols map[string]symbol, f *parsedFile, name string, isEnum bool) error {
+	if prev, dup := symbols[name]; dup {
+		return errorf(Position{Filename: f.fd.GetName()}, "%s is already defined in %s", name, prev.file.fd.GetName())
+	}
+	symbols[name] = symbol{file: f, isEnum: isEnum}
+	return nil
+}
+
+// declareMessage adds msg and everything nested inside it to the symbol table
+func declareMessage(symbols map[string]symbol, f *parsedFile, scope string, msg *descriptor.DescriptorProto) error {
+	name := qualify(scope, msg.GetName())
+	if err := declare(symbols, f, name, false); err != nil {
+		return err
+	}
+	for _, nested := range msg.NestedType {
+		if err := declareMessage(symbols, f, name, nested); err != nil {
+			return err
+		}
+	}
+	for _, e := range msg.EnumType {
+		if err := declare(symbols, f, qualify(name, e.GetName()), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve finds what name refers to from within scope, searching from the
+// innermost scope outwards, as Protobuf does
+func resolve(symbols map[string]symbol, scope, name string) (string, symbol, bool) {
+	if strings.HasPrefix(name, ".") {
+		full := name[1:]
+		sym, ok := symbols[full]
+		return full, sym, ok
+	}
+	for {
+		full := qualify(scope, name)
+		if sym, ok := symbols[full]; ok {
+			return full, sym, true
+		}
+		if scope == "" {
+			return "", symbol{}, false
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// visibleFiles returns the files whose types f may use: f itself, the files
+// it imports, and anything those files import publicly
+func visibleFiles(f *parsedFile, byName map[string]*parsedFile) map[*parsedFile]bool {
+	visible := map[*parsedFile]bool{f: true}
+	var addPublic func(dep *parsedFile)
+	addPublic = func(dep *parsedFile) {
+		if visible[dep] {
+			return
+		}
+		visible[dep] = true
+		for _, i := range dep.fd.PublicDependency {
+			addPublic(byName[dep.fd.Dependency[i]])
+		}
+	}
+	for _, dep := range f.fd.Dependency {
+		addPublic(byName[dep])
+	}
+	return visible
+}
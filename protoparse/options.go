@@ -0,0 +1,410 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file handles option statements. Standard options are set directly
+// on the descriptor's options message. Custom options, such as
+// "(my.option).field", are kept as uninterpreted options, just like protoc
+// does before it loads the extensions that define them.
+
+package protoparse
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// optionName is the possibly dotted name of an option
+type optionName struct {
+	pos   Position
+	parts []*descriptor.UninterpretedOption_NamePart
+}
+
+// simple returns the name of a standard option, or "" if the name refers
+// to a custom option
+func (n *optionName) simple() string {
+	if len(n.parts) == 1 && !n.parts[0].GetIsExtension() {
+		return n.parts[0].GetNamePart()
+	}
+	return ""
+}
+
+func (n *optionName) String() string {
+	parts := make([]string, len(n.parts))
+	for i, part := range n.parts {
+		if part.GetIsExtension() {
+			parts[i] = "(" + part.GetNamePart() + ")"
+		} else {
+			parts[i] = part.GetNamePart()
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// optionValue is the constant assigned to an option
+type optionValue struct {
+	pos       Position
+	kind      tokenKind // tokIdent, tokInt, tokFloat or tokString
+	neg       bool
+	aggregate bool   // a { ... } text format message
+	text      string // identifier, number, unescaped string or aggregate
+}
+
+// String formats the value the way protoc stores default values
+func (v optionValue) String() string {
+	if v.neg {
+		return "-" + v.text
+	}
+	return v.text
+}
+
+// optionName parses the name of an option
+func (p *parser) optionName() (*optionName, error) {
+	n := &optionName{pos: p.peek().pos}
+	for {
+		part := &descriptor.UninterpretedOption_NamePart{IsExtension: proto.Bool(false)}
+		if p.accept("(") {
+			name, _, err := p.typeName()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			part.NamePart = proto.String(name)
+			part.IsExtension = proto.Bool(true)
+		} else {
+			t, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			part.NamePart = proto.String(t.text)
+		}
+		n.parts = append(n.parts, part)
+		if !p.accept(".") {
+			return n, nil
+		}
+	}
+}
+
+// optionValue parses the constant assigned to an option
+func (p *parser) optionValue() (optionValue, error) {
+	v := optionValue{pos: p.peek().pos}
+
+	if p.is("{") {
+		open := p.next()
+		var parts []string
+		depth := 1
+		for depth > 0 {
+			t := p.next()
+			switch {
+			case t.kind == tokEOF:
+				return v, errorf(open.pos, "unclosed aggregate value")
+			case t.kind == tokString:
+				parts = append(parts, strconv.Quote(t.text))
+				continue
+			case t.text == "{":
+				depth++
+			case t.text == "}":
+				depth--
+				if depth == 0 {
+					continue
+				}
+			}
+			parts = append(parts, t.text)
+		}
+		v.aggregate = true
+		v.text = strings.Join(parts, " ")
+		return v, nil
+	}
+
+	if p.accept("-") {
+		v.neg = true
+	} else {
+		p.accept("+")
+	}
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		if v.neg && t.text != "inf" && t.text != "nan" {
+			return v, errorf(t.pos, "expected number, found %s", t)
+		}
+	case tokInt, tokFloat:
+	case tokString:
+		if v.neg {
+			return v, errorf(t.pos, "expected number, found %s", t)
+		}
+		for p.peek().kind == tokString {
+			t.text += p.next().text
+		}
+	default:
+		return v, errorf(t.pos, "expected option value, found %s", t)
+	}
+	v.kind = t.kind
+	v.text = t.text
+	return v, nil
+}
+
+// option parses "name = value ;" after the option keyword and applies it
+// to target, which must be a pointer to an options message pointer
+func (p *parser) option(target interface{}) error {
+	name, err := p.optionName()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect("="); err != nil {
+		return err
+	}
+	v, err := p.optionValue()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return err
+	}
+	return p.applyOption(target, name, v)
+}
+
+// optionList parses a bracketed "name = value, ..." list after the opening
+// bracket, calling apply for each option
+func (p *parser) optionList(apply func(*optionName, optionValue) error) error {
+	for {
+		name, err := p.optionName()
+		if err != nil {
+			return err
+		}
+		if _, err := p.expect("="); err != nil {
+			return err
+		}
+		v, err := p.optionValue()
+		if err != nil {
+			return err
+		}
+		if err := apply(name, v); err != nil {
+			return err
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	_, err := p.expect("]")
+	return err
+}
+
+// fieldOptions parses the bracketed options of a field, including the
+// default and json_name pseudo options
+func (p *parser) fieldOptions(f *descriptor.FieldDescriptorProto) error {
+	return p.optionList(func(name *optionName, v optionValue) error {
+		switch name.simple() {
+		case "default":
+			if p.proto3 {
+				return errorf(name.pos, "default values are not allowed in proto3")
+			}
+			if v.aggregate {
+				return errorf(v.pos, "invalid default value")
+			}
+			f.DefaultValue = proto.String(v.String())
+			return nil
+		case "json_name":
+			if v.kind != tokString {
+				return errorf(v.pos, "json_name must be a string")
+			}
+			f.JsonName = proto.String(v.text)
+			return nil
+		}
+		return p.applyOption(&f.Options, name, v)
+	})
+}
+
+// applyOption sets the option on target, which must be a pointer to an
+// options message pointer, such as **descriptor.FieldOptions
+func (p *parser) applyOption(target interface{}, name *optionName, v optionValue) error {
+	ptr := reflect.ValueOf(target).Elem()
+	if ptr.IsNil() {
+		ptr.Set(reflect.New(ptr.Type().Elem()))
+	}
+	opts := ptr.Elem()
+
+	if simple := name.simple(); simple != "" {
+		if simple == "map_entry" || simple == "uninterpreted_option" {
+			return errorf(name.pos, "option %s must not be set explicitly", simple)
+		}
+		for i := 0; i < opts.NumField(); i++ {
+			tag := opts.Type().Field(i).Tag.Get("protobuf")
+			if tagName(tag) == simple {
+				return setOptionField(opts.Field(i), tag, name, v)
+			}
+		}
+		return errorf(name.pos, "unknown option %s", simple)
+	}
+
+	u := &descriptor.UninterpretedOption{Name: name.parts}
+	switch {
+	case v.aggregate:
+		u.AggregateValue = proto.String(v.text)
+	case v.kind == tokIdent && !v.neg:
+		u.IdentifierValue = proto.String(v.text)
+	case v.kind == tokString:
+		u.StringValue = []byte(v.text)
+	case v.kind == tokInt:
+		n, err := strconv.ParseUint(v.text, 0, 64)
+		if err != nil {
+			return errorf(v.pos, "integer %s out of range", v.text)
+		}
+		if v.neg {
+			u.NegativeIntValue = proto.Int64(-int64(n))
+		} else {
+			u.PositiveIntValue = proto.Uint64(n)
+		}
+	default:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return errorf(v.pos, "invalid number %s", v.String())
+		}
+		u.DoubleValue = proto.Float64(f)
+	}
+	list := opts.FieldByName("UninterpretedOption")
+	list.Set(reflect.Append(list, reflect.ValueOf(u)))
+	return nil
+}
+
+// tagName extracts the field name from a protobuf struct tag
+func tagName(tag string) string {
+	for _, part := range strings.Split(tag, ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return ""
+}
+
+// tagEnum extracts the enum type name from a protobuf struct tag
+func tagEnum(tag string) string {
+	for _, part := range strings.Split(tag, ",") {
+		if strings.HasPrefix(part, "enum=") {
+			return strings.TrimPrefix(part, "enum=")
+		}
+	}
+	return ""
+}
+
+// setOptionField sets the pointer field of an options message to v
+func setOptionField(field reflect.Value, tag string, name *optionName, v optionValue) error {
+	val := reflect.New(field.Type().Elem())
+	switch val.Elem().Kind() {
+	case reflect.Bool:
+		if v.kind != tokIdent || (v.text != "true" && v.text != "false") {
+			return errorf(v.pos, "option %s expects true or false", name)
+		}
+		val.Elem().SetBool(v.text == "true")
+	case reflect.String:
+		if v.kind != tokString {
+			return errorf(v.pos, "option %s expects a string", name)
+		}
+		val.Elem().SetString(v.text)
+	case reflect.Int32:
+		number, ok := proto.EnumValueMap(tagEnum(tag))[v.text]
+		if v.kind != tokIdent || !ok {
+			return errorf(v.pos, "invalid value %s for option %s", v.String(), name)
+		}
+		val.Elem().SetInt(int64(number))
+	default:
+		return errorf(name.pos, "option %s is not supported", name)
+	}
+	field.Set(val)
+	return nil
+}
+
+// fieldRange is an inclusive range of field numbers and where it was
+// declared
+type fieldRange struct {
+	start, end int64
+	pos        Position
+}
+
+// messageValidator checks that the fields of a message don't collide with
+// each other, or with the reserved and extension ranges
+type messageValidator struct {
+	msg           *descriptor.DescriptorProto
+	numbers       map[int32]Position
+	names         map[string]Position
+	reserved      []fieldRange
+	reservedNames map[string]Position
+	extensions    []fieldRange
+	err           error
+}
+
+func newMessageValidator(msg *descriptor.DescriptorProto) *messageValidator {
+	return &messageValidator{
+		msg:           msg,
+		numbers:       make(map[int32]Position),
+		names:         make(map[string]Position),
+		reservedNames: make(map[string]Position),
+	}
+}
+
+// fail records the first error found
+func (v *messageValidator) fail(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+func (v *messageValidator) addField(f *descriptor.FieldDescriptorProto, pos Position) {
+	if prev, dup := v.numbers[f.GetNumber()]; dup {
+		v.fail(errorf(pos, "field number %d already used by the field at %d:%d", f.GetNumber(), prev.Line, prev.Col))
+	}
+	if prev, dup := v.names[f.GetName()]; dup {
+		v.fail(errorf(pos, "field name %s already used by the field at %d:%d", f.GetName(), prev.Line, prev.Col))
+	}
+	v.numbers[f.GetNumber()] = pos
+	v.names[f.GetName()] = pos
+}
+
+func (v *messageValidator) addReservedName(name string, pos Position) {
+	v.reservedNames[name] = pos
+}
+
+func (v *messageValidator) addReservedRange(r [2]int64, pos Position) {
+	v.reserved = append(v.reserved, fieldRange{r[0], r[1], pos})
+}
+
+func (v *messageValidator) addExtensionRange(r [2]int64, pos Position) {
+	v.extensions = append(v.extensions, fieldRange{r[0], r[1], pos})
+}
+
+// validate reports the first problem found with the message
+func (v *messageValidator) validate() error {
+	if v.err != nil {
+		return v.err
+	}
+	for _, f := range v.msg.Field {
+		number, pos := f.GetNumber(), v.numbers[f.GetNumber()]
+		for _, r := range v.reserved {
+			if int64(number) >= r.start && int64(number) <= r.end {
+				return errorf(pos, "field number %d is reserved", number)
+			}
+		}
+		for _, r := range v.extensions {
+			if int64(number) >= r.start && int64(number) <= r.end {
+				return errorf(pos, "field number %d is in an extension range", number)
+			}
+		}
+	}
+	for _, f := range v.msg.Field {
+		if _, ok := v.reservedNames[f.GetName()]; ok {
+			return errorf(v.names[f.GetName()], "field name %s is reserved", f.GetName())
+		}
+	}
+	for _, r := range v.extensions {
+		for _, res := range v.reserved {
+			if r.start <= res.end && res.start <= r.end {
+				return errorf(r.pos, "extension range overlaps reserved range")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1020 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the recursive descent parser that turns the tokens of a
+// .proto source file into a FileDescriptorProto.
+
+// Package protoparse parses proto2 and proto3 .proto source files into
+// Protobuf descriptors at runtime, without needing protoc.
+//
+// The resulting descriptors can be handed to a dproto.Registry, which turns
+// them into ready-to-use ProtoFieldMaps. This allows a long running service
+// to pick up new message definitions straight from .proto files.
+//
+// Messages, enums, nested types, imports, options, reserved ranges, oneofs,
+// maps, extensions and services are supported. Groups are not, since dproto
+// can not decode them. Errors are reported with the file, line and column
+// they were found at.
+package protoparse
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const (
+	// maxFieldNumber is the largest field number Protobuf allows
+	maxFieldNumber = 536870911
+	// Field numbers reserved for the Protobuf implementation itself
+	firstReservedNumber = 19000
+	lastReservedNumber  = 19999
+)
+
+// scalarTypes maps the .proto scalar type keywords to their types
+var scalarTypes = map[string]descriptor.FieldDescriptorProto_Type{
+	"double":   descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptor.FieldDescriptorProto_TYPE_FLOAT,
+	"int32":    descriptor.FieldDescriptorProto_TYPE_INT32,
+	"int64":    descriptor.FieldDescriptorProto_TYPE_INT64,
+	"uint32":   descriptor.FieldDescriptorProto_TYPE_UINT32,
+	"uint64":   descriptor.FieldDescriptorProto_TYPE_UINT64,
+	"sint32":   descriptor.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptor.FieldDescriptorProto_TYPE_SINT64,
+	"fixed32":  descriptor.FieldDescriptorProto_TYPE_FIXED32,
+	"fixed64":  descriptor.FieldDescriptorProto_TYPE_FIXED64,
+	"sfixed32": descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+	"bool":     descriptor.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptor.FieldDescriptorProto_TYPE_STRING,
+	"bytes":    descriptor.FieldDescriptorProto_TYPE_BYTES,
+}
+
+// typeRef is a reference to a message or enum type that must be resolved
+// once all files have been parsed
+type typeRef struct {
+	pos   Position
+	scope string // scope of the reference, relative to the file's package
+	name  string // the name as written
+
+	// Exactly one of field or target is set. A field may refer to either a
+	// message or an enum. A target must refer to a message.
+	field  *descriptor.FieldDescriptorProto
+	target **string
+}
+
+// parsedFile is a parsed, but not yet linked, .proto file
+type parsedFile struct {
+	fd      *descriptor.FileDescriptorProto
+	refs    []typeRef
+	imports []Position // position of each dependency's import statement
+}
+
+// parser holds the state of parsing a single file
+type parser struct {
+	toks   []token
+	i      int
+	file   *parsedFile
+	proto3 bool
+}
+
+// parseFile parses the .proto source src
+func parseFile(filename, src string) (*parsedFile, error) {
+	toks, err := tokenize(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		toks: toks,
+		file: &parsedFile{
+			fd: &descriptor.FileDescriptorProto{Name: proto.String(filename)},
+		},
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.file, nil
+}
+
+/*******************************************************
+ *                   Token Helpers                     *
+ *******************************************************/
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+// peekAt returns the token n positions ahead
+func (p *parser) peekAt(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// is indicates if the next token is the keyword or punctuation text
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokIdent || t.kind == tokPunct) && t.text == text
+}
+
+// accept consumes the next token if it is the keyword or punctuation text
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the next token, which must be the keyword or
+// punctuation text
+func (p *parser) expect(text string) (token, error) {
+	t := p.next()
+	if (t.kind == tokIdent || t.kind == tokPunct) && t.text == text {
+		return t, nil
+	}
+	return t, errorf(t.pos, "expected %q, found %s", text, t)
+}
+
+// ident consumes a single identifier
+func (p *parser) ident() (token, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return t, errorf(t.pos, "expected identifier, found %s", t)
+	}
+	return t, nil
+}
+
+// fullIdent consumes a dotted identifier, such as "foo.bar.Baz"
+func (p *parser) fullIdent() (string, Position, error) {
+	t, err := p.ident()
+	if err != nil {
+		return "", t.pos, err
+	}
+	name := t.text
+	for p.accept(".") {
+		part, err := p.ident()
+		if err != nil {
+			return "", t.pos, err
+		}
+		name += "." + part.text
+	}
+	return name, t.pos, nil
+}
+
+// typeName consumes a possibly fully qualified type name, such as ".foo.Bar"
+func (p *parser) typeName() (string, Position, error) {
+	pos := p.peek().pos
+	prefix := ""
+	if p.accept(".") {
+		prefix = "."
+	}
+	name, _, err := p.fullIdent()
+	return prefix + name, pos, err
+}
+
+// strLit consumes one or more adjacent string literals
+func (p *parser) strLit() (string, error) {
+	t := p.next()
+	if t.kind != tokString {
+		return "", errorf(t.pos, "expected string, found %s", t)
+	}
+	s := t.text
+	for p.peek().kind == tokString {
+		s += p.next().text
+	}
+	return s, nil
+}
+
+// intLit consumes an optionally signed integer literal
+func (p *parser) intLit() (int64, Position, error) {
+	pos := p.peek().pos
+	neg := p.accept("-")
+	t := p.next()
+	if t.kind != tokInt {
+		return 0, pos, errorf(t.pos, "expected integer, found %s", t)
+	}
+	u, err := strconv.ParseUint(t.text, 0, 64)
+	if err != nil || (!neg && u > math.MaxInt64) || (neg && u > -math.MinInt64) {
+		return 0, pos, errorf(t.pos, "integer %s out of range", t.text)
+	}
+	if neg {
+		return -int64(u), pos, nil
+	}
+	return int64(u), pos, nil
+}
+
+// endOfBlock consumes the closing brace of a block, reporting an error if
+// the file ends first
+func (p *parser) endOfBlock(open token) (bool, error) {
+	if p.accept("}") {
+		return true, nil
+	}
+	if p.peek().kind == tokEOF {
+		return false, errorf(open.pos, "unclosed block, expected \"}\"")
+	}
+	return false, nil
+}
+
+/*******************************************************
+ *                    Declarations                     *
+ *******************************************************/
+
+// parse parses the whole file
+func (p *parser) parse() error {
+	fd := p.file.fd
+
+	if p.accept("syntax") {
+		if _, err := p.expect("="); err != nil {
+			return err
+		}
+		pos := p.peek().pos
+		syntax, err := p.strLit()
+		if err != nil {
+			return err
+		}
+		if syntax != "proto2" && syntax != "proto3" {
+			return errorf(pos, "unknown syntax %q", syntax)
+		}
+		if _, err := p.expect(";"); err != nil {
+			return err
+		}
+		p.proto3 = syntax == "proto3"
+		fd.Syntax = proto.String(syntax)
+	}
+
+	for p.peek().kind != tokEOF {
+		t := p.peek()
+		var err error
+		switch {
+		case p.accept(";"):
+		case p.accept("package"):
+			err = p.packageDecl(t)
+		case p.accept("import"):
+			err = p.importDecl()
+		case p.accept("option"):
+			err = p.option(&fd.Options)
+		case p.accept("message"):
+			var msg *descriptor.DescriptorProto
+			if msg, err = p.message(""); err == nil {
+				fd.MessageType = append(fd.MessageType, msg)
+			}
+		case p.accept("enum"):
+			var e *descriptor.EnumDescriptorProto
+			if e, err = p.enum(); err == nil {
+				fd.EnumType = append(fd.EnumType, e)
+			}
+		case p.accept("service"):
+			var s *descriptor.ServiceDescriptorProto
+			if s, err = p.service(); err == nil {
+				fd.Service = append(fd.Service, s)
+			}
+		case p.accept("extend"):
+			fd.Extension, err = p.extend("", fd.Extension)
+		case t.kind == tokIdent && t.text == "syntax":
+			err = errorf(t.pos, "syntax must be the first statement")
+		default:
+			err = errorf(t.pos, "unexpected %s", t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) packageDecl(t token) error {
+	if p.file.fd.Package != nil {
+		return errorf(t.pos, "multiple package statements")
+	}
+	name, _, err := p.fullIdent()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return err
+	}
+	p.file.fd.Package = proto.String(name)
+	return nil
+}
+
+func (p *parser) importDecl() error {
+	fd := p.file.fd
+	public, weak := p.accept("public"), false
+	if !public {
+		weak = p.accept("weak")
+	}
+	pos := p.peek().pos
+	path, err := p.strLit()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect(";"); err != nil {
+		return err
+	}
+	for _, dep := range fd.Dependency {
+		if dep == path {
+			return errorf(pos, "%q imported twice", path)
+		}
+	}
+
+	index := int32(len(fd.Dependency))
+	fd.Dependency = append(fd.Dependency, path)
+	p.file.imports = append(p.file.imports, pos)
+	if public {
+		fd.PublicDependency = append(fd.PublicDependency, index)
+	}
+	if weak {
+		fd.WeakDependency = append(fd.WeakDependency, index)
+	}
+	return nil
+}
+
+// message parses a message definition within scope
+func (p *parser) message(scope string) (*descriptor.DescriptorProto, error) {
+	nameTok, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	msg := &descriptor.DescriptorProto{Name: proto.String(nameTok.text)}
+	full := qualify(scope, nameTok.text)
+	v := newMessageValidator(msg)
+
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if done, err := p.endOfBlock(open); done || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err := p.messageElement(msg, full, v); err != nil {
+			return nil, err
+		}
+	}
+	return msg, v.validate()
+}
+
+// messageElement parses one statement inside of a message body
+func (p *parser) messageElement(msg *descriptor.DescriptorProto, full string, v *messageValidator) error {
+	t := p.peek()
+	switch {
+	case p.accept(";"):
+		return nil
+	case p.accept("option"):
+		return p.option(&msg.Options)
+	case p.accept("message"):
+		nested, err := p.message(full)
+		if err != nil {
+			return err
+		}
+		msg.NestedType = append(msg.NestedType, nested)
+		return nil
+	case p.accept("enum"):
+		e, err := p.enum()
+		if err != nil {
+			return err
+		}
+		msg.EnumType = append(msg.EnumType, e)
+		return nil
+	case p.accept("extend"):
+		var err error
+		msg.Extension, err = p.extend(full, msg.Extension)
+		return err
+	case p.accept("extensions"):
+		return p.extensions(msg, v)
+	case p.accept("reserved"):
+		return p.reserved(&msg.ReservedRange, &msg.ReservedName, v)
+	case p.accept("oneof"):
+		return p.oneof(msg, full, v)
+	case p.is("map") && p.peekAt(1).text == "<":
+		p.next()
+		return p.mapField(msg, full, v)
+	}
+
+	f, err := p.field(full, false)
+	if err != nil {
+		return err
+	}
+	msg.Field = append(msg.Field, f)
+	v.addField(f, t.pos)
+	return nil
+}
+
+// field parses a regular field declaration. Fields inside a oneof
+// don't have labels.
+func (p *parser) field(scope string, inOneof bool) (*descriptor.FieldDescriptorProto, error) {
+	start := p.peek()
+	f := new(descriptor.FieldDescriptorProto)
+
+	switch {
+	case p.is("required") || p.is("optional") || p.is("repeated"):
+		t := p.next()
+		if inOneof {
+			return nil, errorf(t.pos, "fields in a oneof must not have labels")
+		}
+		label := descriptor.FieldDescriptorProto_Label_value["LABEL_"+strings.ToUpper(t.text)]
+		if p.proto3 && t.text == "required" {
+			return nil, errorf(t.pos, "required fields are not allowed in proto3")
+		}
+		f.Label = descriptor.FieldDescriptorProto_Label(label).Enum()
+	case inOneof || p.proto3:
+		f.Label = descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	default:
+		return nil, errorf(start.pos, "expected field label (required, optional or repeated), found %s", start)
+	}
+
+	if p.is("group") {
+		return nil, errorf(p.peek().pos, "groups are not supported")
+	}
+	typeName, typePos, err := p.typeName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.fieldRest(f); err != nil {
+		return nil, err
+	}
+	p.setFieldType(f, scope, typeName, typePos)
+	return f, nil
+}
+
+// fieldRest parses the name, number and options of a field, everything
+// after the type
+func (p *parser) fieldRest(f *descriptor.FieldDescriptorProto) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect("="); err != nil {
+		return err
+	}
+	number, pos, err := p.intLit()
+	if err != nil {
+		return err
+	}
+	if number < 1 || number > maxFieldNumber {
+		return errorf(pos, "field number %d out of range", number)
+	}
+	if number >= firstReservedNumber && number <= lastReservedNumber {
+		return errorf(pos, "field number %d is reserved for the Protobuf implementation", number)
+	}
+	f.Name = proto.String(name.text)
+	f.Number = proto.Int32(int32(number))
+	f.JsonName = proto.String(jsonName(name.text))
+
+	if p.accept("[") {
+		if err := p.fieldOptions(f); err != nil {
+			return err
+		}
+	}
+	_, err = p.expect(";")
+	return err
+}
+
+// setFieldType sets the type of f from the type name, either directly for
+// scalars or later through linking
+func (p *parser) setFieldType(f *descriptor.FieldDescriptorProto, scope, typeName string, pos Position) {
+	if typ, ok := scalarTypes[typeName]; ok {
+		f.Type = typ.Enum()
+		return
+	}
+	f.TypeName = proto.String(typeName)
+	p.file.refs = append(p.file.refs, typeRef{pos: pos, scope: scope, name: typeName, field: f})
+}
+
+// mapField parses a map<K,V> field and synthesizes its map entry type,
+// just like protoc does
+func (p *parser) mapField(msg *descriptor.DescriptorProto, full string, v *messageValidator) error {
+	start := p.toks[p.i-1]
+	if _, err := p.expect("<"); err != nil {
+		return err
+	}
+	keyTok, err := p.ident()
+	if err != nil {
+		return err
+	}
+	keyType, ok := scalarTypes[keyTok.text]
+	if !ok || keyType == descriptor.FieldDescriptorProto_TYPE_DOUBLE ||
+		keyType == descriptor.FieldDescriptorProto_TYPE_FLOAT ||
+		keyType == descriptor.FieldDescriptorProto_TYPE_BYTES {
+		return errorf(keyTok.pos, "invalid map key type %s", keyTok.text)
+	}
+	if _, err := p.expect(","); err != nil {
+		return err
+	}
+	valueName, valuePos, err := p.typeName()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect(">"); err != nil {
+		return err
+	}
+
+	f := &descriptor.FieldDescriptorProto{
+		Label: descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		Type:  descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+	}
+	if err := p.fieldRest(f); err != nil {
+		return err
+	}
+
+	entryName := mapEntryName(f.GetName())
+	optional := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	key := &descriptor.FieldDescriptorProto{
+		Name:     proto.String("key"),
+		Number:   proto.Int32(1),
+		Label:    optional.Enum(),
+		Type:     keyType.Enum(),
+		JsonName: proto.String("key"),
+	}
+	value := &descriptor.FieldDescriptorProto{
+		Name:     proto.String("value"),
+		Number:   proto.Int32(2),
+		Label:    optional.Enum(),
+		JsonName: proto.String("value"),
+	}
+	p.setFieldType(value, full, valueName, valuePos)
+	msg.NestedType = append(msg.NestedType, &descriptor.DescriptorProto{
+		Name:    proto.String(entryName),
+		Field:   []*descriptor.FieldDescriptorProto{key, value},
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	})
+
+	p.setFieldType(f, full, entryName, start.pos)
+	msg.Field = append(msg.Field, f)
+	v.addField(f, start.pos)
+	return nil
+}
+
+// oneof parses a oneof block, whose fields are added to msg
+func (p *parser) oneof(msg *descriptor.DescriptorProto, full string, v *messageValidator) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	index := int32(len(msg.OneofDecl))
+	decl := &descriptor.OneofDescriptorProto{Name: proto.String(name.text)}
+	msg.OneofDecl = append(msg.OneofDecl, decl)
+
+	open, err := p.expect("{")
+	if err != nil {
+		return err
+	}
+	members := 0
+	for {
+		if done, err := p.endOfBlock(open); done || err != nil {
+			if err != nil {
+				return err
+			}
+			break
+		}
+		t := p.peek()
+		switch {
+		case p.accept(";"):
+		case p.accept("option"):
+			var opts *descriptor.OneofOptions
+			if err := p.option(&opts); err != nil {
+				return err
+			}
+			decl.Options = opts
+		case p.is("map") && p.peekAt(1).text == "<":
+			return errorf(t.pos, "map fields are not allowed in a oneof")
+		default:
+			f, err := p.field(full, true)
+			if err != nil {
+				return err
+			}
+			f.OneofIndex = proto.Int32(index)
+			msg.Field = append(msg.Field, f)
+			v.addField(f, t.pos)
+			members++
+		}
+	}
+	if members == 0 {
+		return errorf(name.pos, "oneof %s must have at least one field", name.text)
+	}
+	return nil
+}
+
+// ranges parses a comma separated list of "N", "N to M" or "N to max"
+// ranges, returning them as inclusive [start, end] pairs
+func (p *parser) ranges(max int64) ([][2]int64, []Position, error) {
+	var ranges [][2]int64
+	var positions []Position
+	for {
+		start, pos, err := p.intLit()
+		if err != nil {
+			return nil, nil, err
+		}
+		end := start
+		if p.accept("to") {
+			if p.accept("max") {
+				end = max
+			} else if end, _, err = p.intLit(); err != nil {
+				return nil, nil, err
+			}
+		}
+		if end < start {
+			return nil, nil, errorf(pos, "range end %d is before start %d", end, start)
+		}
+		ranges = append(ranges, [2]int64{start, end})
+		positions = append(positions, pos)
+		if !p.accept(",") {
+			return ranges, positions, nil
+		}
+	}
+}
+
+// reserved parses a reserved statement of either numbers or names
+func (p *parser) reserved(ranges *[]*descriptor.DescriptorProto_ReservedRange, names *[]string, v *messageValidator) error {
+	if p.peek().kind == tokString {
+		for {
+			pos := p.peek().pos
+			name, err := p.strLit()
+			if err != nil {
+				return err
+			}
+			v.addReservedName(name, pos)
+			*names = append(*names, name)
+			if !p.accept(",") {
+				break
+			}
+		}
+		_, err := p.expect(";")
+		return err
+	}
+
+	rs, positions, err := p.ranges(maxFieldNumber)
+	if err != nil {
+		return err
+	}
+	for i, r := range rs {
+		if r[0] < 1 {
+			return errorf(positions[i], "reserved field number %d out of range", r[0])
+		}
+		*ranges = append(*ranges, &descriptor.DescriptorProto_ReservedRange{
+			Start: proto.Int32(int32(r[0])),
+			End:   proto.Int32(int32(r[1] + 1)), // exclusive
+		})
+		v.addReservedRange(r, positions[i])
+	}
+	_, err = p.expect(";")
+	return err
+}
+
+// extensions parses an extension range statement
+func (p *parser) extensions(msg *descriptor.DescriptorProto, v *messageValidator) error {
+	rs, positions, err := p.ranges(maxFieldNumber)
+	if err != nil {
+		return err
+	}
+	for i, r := range rs {
+		if r[0] < 1 {
+			return errorf(positions[i], "extension number %d out of range", r[0])
+		}
+		msg.ExtensionRange = append(msg.ExtensionRange, &descriptor.DescriptorProto_ExtensionRange{
+			Start: proto.Int32(int32(r[0])),
+			End:   proto.Int32(int32(r[1] + 1)), // exclusive
+		})
+		v.addExtensionRange(r, positions[i])
+	}
+	// Extension range options have no home in the descriptor, so they are
+	// checked for syntax and dropped
+	if p.accept("[") {
+		if err := p.optionList(func(*optionName, optionValue) error { return nil }); err != nil {
+			return err
+		}
+	}
+	_, err = p.expect(";")
+	return err
+}
+
+// extend parses an extend block, appending the extension fields to exts
+func (p *parser) extend(scope string, exts []*descriptor.FieldDescriptorProto) ([]*descriptor.FieldDescriptorProto, error) {
+	extendee, pos, err := p.typeName()
+	if err != nil {
+		return nil, err
+	}
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	var fields []*descriptor.FieldDescriptorProto
+	for {
+		if done, err := p.endOfBlock(open); done || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		if p.accept(";") {
+			continue
+		}
+		f, err := p.field(scope, false)
+		if err != nil {
+			return nil, err
+		}
+		f.Extendee = proto.String(extendee)
+		p.file.refs = append(p.file.refs, typeRef{pos: pos, scope: scope, name: extendee, target: &f.Extendee})
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, errorf(pos, "extend block for %s has no fields", extendee)
+	}
+	return append(exts, fields...), nil
+}
+
+// enum parses an enum definition
+func (p *parser) enum() (*descriptor.EnumDescriptorProto, error) {
+	nameTok, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	e := &descriptor.EnumDescriptorProto{Name: proto.String(nameTok.text)}
+
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	numbers := make(map[int32]Position)
+	var aliasPos *Position
+	var reservedRanges [][2]int64
+	var reservedNames []string
+
+	for {
+		if done, err := p.endOfBlock(open); done || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		t := p.peek()
+		switch {
+		case p.accept(";"):
+		case p.accept("option"):
+			if err := p.option(&e.Options); err != nil {
+				return nil, err
+			}
+		case p.accept("reserved"):
+			// The descriptor version in use has no home for reserved enum
+			// values, so they are only enforced
+			if p.peek().kind == tokString {
+				for {
+					name, err := p.strLit()
+					if err != nil {
+						return nil, err
+					}
+					reservedNames = append(reservedNames, name)
+					if !p.accept(",") {
+						break
+					}
+				}
+			} else {
+				rs, _, err := p.ranges(math.MaxInt32)
+				if err != nil {
+					return nil, err
+				}
+				reservedRanges = append(reservedRanges, rs...)
+			}
+			if _, err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		default:
+			nameTok, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("="); err != nil {
+				return nil, err
+			}
+			number, pos, err := p.intLit()
+			if err != nil {
+				return nil, err
+			}
+			if number < math.MinInt32 || number > math.MaxInt32 {
+				return nil, errorf(pos, "enum value %d out of range", number)
+			}
+			if p.proto3 && len(e.Value) == 0 && number != 0 {
+				return nil, errorf(pos, "the first enum value must be zero in proto3")
+			}
+			if names[nameTok.text] {
+				return nil, errorf(t.pos, "duplicate enum value name %s", nameTok.text)
+			}
+			if _, dup := numbers[int32(number)]; dup && aliasPos == nil {
+				p := t.pos
+				aliasPos = &p
+			}
+			names[nameTok.text] = true
+			numbers[int32(number)] = t.pos
+
+			val := &descriptor.EnumValueDescriptorProto{
+				Name:   proto.String(nameTok.text),
+				Number: proto.Int32(int32(number)),
+			}
+			if p.accept("[") {
+				if err := p.optionList(func(name *optionName, v optionValue) error {
+					return p.applyOption(&val.Options, name, v)
+				}); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := p.expect(";"); err != nil {
+				return nil, err
+			}
+			e.Value = append(e.Value, val)
+		}
+	}
+
+	if len(e.Value) == 0 {
+		return nil, errorf(nameTok.pos, "enum %s must have at least one value", nameTok.text)
+	}
+	if aliasPos != nil && !e.GetOptions().GetAllowAlias() {
+		return nil, errorf(*aliasPos, "duplicate enum value number without allow_alias")
+	}
+	for _, v := range e.Value {
+		for _, r := range reservedRanges {
+			if int64(v.GetNumber()) >= r[0] && int64(v.GetNumber()) <= r[1] {
+				return nil, errorf(numbers[v.GetNumber()], "enum value %s uses reserved number %d", v.GetName(), v.GetNumber())
+			}
+		}
+		for _, name := range reservedNames {
+			if v.GetName() == name {
+				return nil, errorf(nameTok.pos, "enum value %s uses a reserved name", name)
+			}
+		}
+	}
+	return e, nil
+}
+
+// service parses a service definition with its rpc methods
+func (p *parser) service() (*descriptor.ServiceDescriptorProto, error) {
+	nameTok, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	s := &descriptor.ServiceDescriptorProto{Name: proto.String(nameTok.text)}
+
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if done, err := p.endOfBlock(open); done || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		t := p.peek()
+		switch {
+		case p.accept(";"):
+		case p.accept("option"):
+			if err := p.option(&s.Options); err != nil {
+				return nil, err
+			}
+		case p.accept("rpc"):
+			m, err := p.method()
+			if err != nil {
+				return nil, err
+			}
+			s.Method = append(s.Method, m)
+		default:
+			return nil, errorf(t.pos, "unexpected %s in service", t)
+		}
+	}
+	return s, nil
+}
+
+// method parses a single rpc declaration
+func (p *parser) method() (*descriptor.MethodDescriptorProto, error) {
+	nameTok, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m := &descriptor.MethodDescriptorProto{Name: proto.String(nameTok.text)}
+
+	// messageType parses "( [stream] Type )"
+	messageType := func(target **string) (bool, error) {
+		if _, err := p.expect("("); err != nil {
+			return false, err
+		}
+		stream := false
+		// "stream" is only a keyword when a type name follows it, rather
+		// than ")" or the rest of a dotted name such as "stream.Type"
+		if p.is("stream") && p.peekAt(1).text != ")" && !adjacent(p.peek(), p.peekAt(1)) {
+			p.next()
+			stream = true
+		}
+		name, pos, err := p.typeName()
+		if err != nil {
+			return false, err
+		}
+		*target = proto.String(name)
+		p.file.refs = append(p.file.refs, typeRef{pos: pos, name: name, target: target})
+		_, err = p.expect(")")
+		return stream, err
+	}
+
+	stream, err := messageType(&m.InputType)
+	if err != nil {
+		return nil, err
+	}
+	m.ClientStreaming = proto.Bool(stream)
+	if _, err := p.expect("returns"); err != nil {
+		return nil, err
+	}
+	if stream, err = messageType(&m.OutputType); err != nil {
+		return nil, err
+	}
+	m.ServerStreaming = proto.Bool(stream)
+
+	if p.accept(";") {
+		return m, nil
+	}
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if done, err := p.endOfBlock(open); done || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+		t := p.peek()
+		switch {
+		case p.accept(";"):
+		case p.accept("option"):
+			if err := p.option(&m.Options); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errorf(t.pos, "unexpected %s in rpc", t)
+		}
+	}
+	return m, nil
+}
+
+// adjacent reports whether b directly follows a, with no space between them
+func adjacent(a, b token) bool {
+	return a.pos.Line == b.pos.Line && a.pos.Col+len(a.text) == b.pos.Col
+}
+
+/*******************************************************
+ *                      Naming                         *
+ *******************************************************/
+
+// qualify joins a scope and a name into a dotted name
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// jsonName computes the default JSON name of a field, the same way
+// protoc does. Underscores are dropped and the following letter is
+// capitalized.
+func jsonName(name string) string {
+	var sb strings.Builder
+	upper := false
+	for _, c := range name {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// mapEntryName computes the name of the synthesized map entry message for
+// a map field, the same way protoc does
+func mapEntryName(field string) string {
+	name := jsonName(field)
+	if name != "" && name[0] >= 'a' && name[0] <= 'z' {
+		name = string(name[0]-('a'-'A')) + name[1:]
+	}
+	return name + "Entry"
+}
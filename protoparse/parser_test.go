@@ -0,0 +1,276 @@
+package protoparse
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/linux4life798/dproto"
+)
+
+// memFiles serves .proto sources from memory
+func memFiles(files map[string]string) func(string) (io.ReadCloser, error) {
+	return func(name string) (io.ReadCloser, error) {
+		src, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return ioutil.NopCloser(strings.NewReader(src)), nil
+	}
+}
+
+func TestParseTestProtobuf(t *testing.T) {
+	p := Parser{ImportPaths: []string{".."}}
+	r, err := p.LoadRegistry("testprotobuf.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm, ok := r.GetMessage("TestMessage")
+	if !ok {
+		t.Fatal("TestMessage was not loaded")
+	}
+
+	buf, err := ioutil.ReadFile("../testprotobuf.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal("Error Decoding: " + err.Error())
+	}
+	expected := map[string]interface{}{
+		"myint32":  int32(32423),
+		"myenum":   uint64(2),
+		"mysint64": int64(-3932764127),
+	}
+	for _, v := range values {
+		name, _ := fm.GetFieldName(v.Field)
+		if want, ok := expected[name]; ok && want != v.Value {
+			t.Errorf("%s = %#v, expected %#v", name, v.Value, want)
+		}
+	}
+}
+
+const userProto = `
+syntax = "proto3";
+
+package test.user;
+
+import public "common.proto";
+
+option go_package = "example.com/user";
+
+// A user of the system
+message User {
+	reserved 4, 10 to 12;
+	reserved "password";
+
+	string name = 1 [json_name = "fullName"];
+	repeated Address addresses = 2;
+	map<string, int64> scores = 3;
+	oneof contact {
+		string email = 5;
+		uint64 phone = 6 [deprecated = true];
+	}
+	test.common.Status status = 7;
+
+	message Address {
+		string street = 1;
+		Kind kind = 2;
+		enum Kind {
+			HOME = 0;
+			WORK = 1;
+		}
+	}
+}
+
+service Users {
+	rpc Get (User) returns (stream .test.user.User);
+}
+`
+
+const commonProto = `
+syntax = "proto3";
+package test.common;
+
+enum Status {
+	option allow_alias = true;
+	UNKNOWN = 0;
+	ACTIVE = 1;
+	ENABLED = 1;
+	DISABLED = 2 [(my.custom) = "x"];
+}
+`
+
+func TestParseProto3(t *testing.T) {
+	files := map[string]string{
+		"user.proto":   userProto,
+		"common.proto": commonProto,
+	}
+	p := Parser{Accessor: memFiles(files)}
+	fds, err := p.ParseFiles("user.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fds) != 2 || fds[0].GetName() != "common.proto" {
+		t.Fatal("Expected common.proto to be ordered before user.proto")
+	}
+
+	user := fds[1].MessageType[0]
+	if user.Field[0].GetJsonName() != "fullName" || user.Field[1].GetJsonName() != "addresses" {
+		t.Error("Unexpected json names")
+	}
+	if user.Field[1].GetTypeName() != ".test.user.User.Address" {
+		t.Errorf("Unexpected type name %s", user.Field[1].GetTypeName())
+	}
+	if user.Field[5].GetType() != descriptor.FieldDescriptorProto_TYPE_ENUM {
+		t.Error("status should have been resolved to an enum")
+	}
+	if !user.Field[4].GetOptions().GetDeprecated() {
+		t.Error("phone should be deprecated")
+	}
+	if fds[1].GetOptions().GetGoPackage() != "example.com/user" {
+		t.Error("go_package option was not set")
+	}
+	if len(user.ReservedRange) != 2 || user.ReservedRange[1].GetEnd() != 13 {
+		t.Error("Reserved ranges should be recorded with exclusive ends")
+	}
+	method := fds[1].Service[0].Method[0]
+	if method.GetInputType() != ".test.user.User" || !method.GetServerStreaming() {
+		t.Error("Unexpected method descriptor")
+	}
+
+	r := dproto.NewRegistry()
+	if err := r.AddFiles(fds...); err != nil {
+		t.Fatal(err)
+	}
+	fm, ok := r.GetMessage("test.user.User")
+	if !ok {
+		t.Fatal("test.user.User was not loaded")
+	}
+	if f, ok := fm.GetFieldByName("scores"); !ok || f != 3 {
+		t.Fatal("scores map was not loaded")
+	}
+	if _, ok := fm.GetOneof("contact"); !ok {
+		t.Fatal("contact oneof was not loaded")
+	}
+
+	values := []dproto.FieldValue{
+		{Field: 1, Value: "Ada"},
+		{Field: 3, Value: map[string]int64{"chess": 2000}},
+		{Field: 6, Value: uint64(5551234)},
+		{Field: 7, Value: "ACTIVE"},
+	}
+	buf, err := fm.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+	decoded, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal("Error Decoding: " + err.Error())
+	}
+	for _, v := range decoded {
+		if v.Field == 3 && v.Value.(map[interface{}]interface{})["chess"] != int64(2000) {
+			t.Errorf("Unexpected scores %v", v.Value)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"syntax = \"proto3\";\nmessage M {\n  int32 a = 1\n}", "test.proto:4:1: expected \";\""},
+		{"message M {\n  optional int32 a = 1;\n  optional int32 b = 1;\n}", "test.proto:3:"},
+		{"message M {\n  optional Missing a = 1;\n}", "test.proto:2:12: unknown type Missing"},
+		{"message M {\n  reserved 2;\n  optional int32 a = 2;\n}", "field number 2 is reserved"},
+		{"syntax = \"proto3\";\nenum E {\n  A = 1;\n}", "test.proto:3:"},
+		{"message M {\n  optional int32 a = 19001;\n}", "test.proto:2:"},
+		{"message M { /* unterminated", "test.proto:1:13: unterminated block comment"},
+	}
+	for _, test := range tests {
+		_, err := Parse("test.proto", strings.NewReader(test.src))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Expected a ParseError for %q, got %v", test.src, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Expected error containing %q, got %q", test.want, err.Error())
+		}
+	}
+}
+
+func TestParseImportErrors(t *testing.T) {
+	files := map[string]string{
+		"a.proto": "import \"b.proto\";",
+		"b.proto": "import \"a.proto\";",
+		"c.proto": "\nimport \"missing.proto\";",
+		"d.proto": "import \"e.proto\";\nmessage D { optional F f = 1; }",
+		"e.proto": "import \"f.proto\";",
+		"f.proto": "message F {}",
+	}
+	p := Parser{Accessor: memFiles(files)}
+
+	if _, err := p.ParseFiles("a.proto"); err == nil || !strings.Contains(err.Error(), "import cycle") {
+		t.Errorf("Expected an import cycle error, got %v", err)
+	}
+	if _, err := p.ParseFiles("c.proto"); err == nil || !strings.HasPrefix(err.Error(), "c.proto:2:8: file not found") {
+		t.Errorf("Expected a file not found error, got %v", err)
+	}
+	// F is only imported transitively, without public
+	if _, err := p.ParseFiles("d.proto"); err == nil || !strings.Contains(err.Error(), "unknown type F") {
+		t.Errorf("Expected an unknown type error, got %v", err)
+	}
+}
+
+func TestParseWriteProto(t *testing.T) {
+	files := map[string]string{
+		"user.proto":   userProto,
+		"common.proto": commonProto,
+	}
+	r, err := Parser{Accessor: memFiles(files)}.LoadRegistry("user.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ := r.GetMessage("test.user.User")
+
+	var sb strings.Builder
+	if err := user.WriteProto(&sb, "User"); err != nil {
+		t.Fatal(err)
+	}
+	files["written.proto"] = "syntax = \"proto2\";\npackage test.user;\nimport \"common.proto\";\n" + sb.String()
+	fds, err := Parser{Accessor: memFiles(files)}.ParseFiles("written.proto")
+	if err != nil {
+		t.Fatalf("Written proto does not parse: %v\n%s", err, files["written.proto"])
+	}
+	written := fds[len(fds)-1].MessageType[0]
+	if len(written.Field) != 6 || len(written.OneofDecl) != 1 || len(written.NestedType) != 2 {
+		t.Errorf("Written proto lost fields:\n%s", files["written.proto"])
+	}
+}
+
+func TestRegistryWatcherProtoFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{"user.proto": userProto, "common.proto": commonProto} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := dproto.NewRegistryWatcher(dir, Parser{}.ParseInDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.Registry().GetEnum("test.common.Status"); !ok {
+		t.Error("test.common.Status was not loaded")
+	}
+	if _, ok := w.Registry().GetMessage("test.user.User"); !ok {
+		t.Error("test.user.User was not loaded")
+	}
+}
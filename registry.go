@@ -0,0 +1,214 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the Registry, which keeps many named message schemas
+// and enums together, so that references between them can be resolved.
+
+package dproto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// versionSeparator separates a type name from a version tag, as in
+// "mypackage.MyMessage@v2"
+const versionSeparator = "@"
+
+// Registry stores ProtoFieldMaps and ProtoEnums under their fully
+// qualified names, such as "mypackage.MyMessage".
+//
+// A type may also be stored under several version tags. The version added
+// last is the current one, which is what a plain name refers to. A specific
+// version is referred to as "mypackage.MyMessage@v1".
+type Registry struct {
+	messages        map[string]*ProtoFieldMap
+	enums           map[string]*ProtoEnum
+	messageVersions map[string]map[string]*ProtoFieldMap
+	enumVersions    map[string]map[string]*ProtoEnum
+	versions        map[string][]string
+	files           map[string]bool
+}
+
+// NewRegistry creates a new empty Registry object.
+func NewRegistry() *Registry {
+	var r = new(Registry)
+	r.Reset()
+	return r
+}
+
+// Reset clears all schemas stored in the Registry
+func (r *Registry) Reset() {
+	r.messages = make(map[string]*ProtoFieldMap)
+	r.enums = make(map[string]*ProtoEnum)
+	r.messageVersions = make(map[string]map[string]*ProtoFieldMap)
+	r.enumVersions = make(map[string]map[string]*ProtoEnum)
+	r.versions = make(map[string][]string)
+	r.files = make(map[string]bool)
+}
+
+// normalizeTypeName strips the leading dot that descriptors use to mark
+// fully qualified names
+func normalizeTypeName(name string) string {
+	return strings.TrimPrefix(name, ".")
+}
+
+// versionedName joins a name and version tag into "name@version", or just
+// returns the name if there is no version
+func versionedName(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + versionSeparator + version
+}
+
+// splitVersion splits "name@version" into its name and version tag
+func splitVersion(typeName string) (name, version string) {
+	name = normalizeTypeName(typeName)
+	if i := strings.LastIndex(name, versionSeparator); i >= 0 {
+		return name[:i], name[i+len(versionSeparator):]
+	}
+	return name, ""
+}
+
+// AddMessage adds the message schema fm to the Registry under fm.Name().
+// It returns false if fm is unnamed or the name is already taken.
+func (r *Registry) AddMessage(fm *ProtoFieldMap) bool {
+	name := normalizeTypeName(fm.Name())
+	if name == "" {
+		return false
+	}
+	if _, ok := r.messages[name]; ok {
+		return false
+	}
+	r.messages[name] = fm
+	return true
+}
+
+// AddEnum adds the enum e to the Registry under e.Name().
+// It returns false if e is unnamed or the name is already taken.
+func (r *Registry) AddEnum(e *ProtoEnum) bool {
+	name := normalizeTypeName(e.Name())
+	if name == "" {
+		return false
+	}
+	if _, ok := r.enums[name]; ok {
+		return false
+	}
+	r.enums[name] = e
+	return true
+}
+
+// AddMessageVersion adds the message schema fm to the Registry under
+// fm.Name() and the version tag, making it the current version.
+// It returns false if fm is unnamed, version is empty or that version of
+// the message was already added.
+func (r *Registry) AddMessageVersion(fm *ProtoFieldMap, version string) bool {
+	name := normalizeTypeName(fm.Name())
+	if name == "" || version == "" {
+		return false
+	}
+	if _, ok := r.messageVersions[name][version]; ok {
+		return false
+	}
+	if r.messageVersions[name] == nil {
+		r.messageVersions[name] = make(map[string]*ProtoFieldMap)
+	}
+	r.messageVersions[name][version] = fm
+	r.versions[name] = append(r.versions[name], version)
+	r.messages[name] = fm
+	return true
+}
+
+// AddEnumVersion adds the enum e to the Registry under e.Name() and the
+// version tag, making it the current version.
+// It returns false if e is unnamed, version is empty or that version of
+// the enum was already added.
+func (r *Registry) AddEnumVersion(e *ProtoEnum, version string) bool {
+	name := normalizeTypeName(e.Name())
+	if name == "" || version == "" {
+		return false
+	}
+	if _, ok := r.enumVersions[name][version]; ok {
+		return false
+	}
+	if r.enumVersions[name] == nil {
+		r.enumVersions[name] = make(map[string]*ProtoEnum)
+	}
+	r.enumVersions[name][version] = e
+	r.versions[name] = append(r.versions[name], version)
+	r.enums[name] = e
+	return true
+}
+
+// Versions returns the version tags of the named message or enum, in the
+// order they were added
+func (r *Registry) Versions(name string) []string {
+	return append([]string(nil), r.versions[normalizeTypeName(name)]...)
+}
+
+// GetMessage gets the message schema with the fully qualified name.
+// A specific version can be selected with "name@version".
+func (r *Registry) GetMessage(name string) (*ProtoFieldMap, bool) {
+	name, version := splitVersion(name)
+	if version != "" {
+		fm, ok := r.messageVersions[name][version]
+		return fm, ok
+	}
+	fm, ok := r.messages[name]
+	return fm, ok
+}
+
+// GetEnum gets the enum with the fully qualified name.
+// A specific version can be selected with "name@version".
+func (r *Registry) GetEnum(name string) (*ProtoEnum, bool) {
+	name, version := splitVersion(name)
+	if version != "" {
+		e, ok := r.enumVersions[name][version]
+		return e, ok
+	}
+	e, ok := r.enums[name]
+	return e, ok
+}
+
+// MessageNames returns the names of all message schemas in the Registry,
+// in sorted order
+func (r *Registry) MessageNames() []string {
+	names := make([]string, 0, len(r.messages))
+	for name := range r.messages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnumNames returns the names of all enums in the Registry, in sorted order
+func (r *Registry) EnumNames() []string {
+	names := make([]string, 0, len(r.enums))
+	for name := range r.enums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Decode decodes buf as the message typeName, which may select a specific
+// version with "name@version"
+func (r *Registry) Decode(typeName string, buf []byte) ([]FieldValue, error) {
+	fm, ok := r.GetMessage(typeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolvedType, typeName)
+	}
+	return fm.DecodeBuffer(buf)
+}
+
+// Encode encodes values as the message typeName, which may select a specific
+// version with "name@version"
+func (r *Registry) Encode(typeName string, values []FieldValue) ([]byte, error) {
+	fm, ok := r.GetMessage(typeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolvedType, typeName)
+	}
+	return fm.EncodeBuffer(values)
+}
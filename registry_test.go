@@ -0,0 +1,99 @@
+package dproto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestRegistryVersions(t *testing.T) {
+	v1 := testMessageFile()
+	v2 := testMessageFile()
+	// v2 changes myint32 into a string
+	v2.MessageType[0].Field[0].Type = descriptor.FieldDescriptorProto_TYPE_STRING.Enum()
+	v2.EnumType[0].Value = append(v2.EnumType[0].Value, &descriptor.EnumValueDescriptorProto{
+		Name: proto.String("FIFTH"), Number: proto.Int32(4),
+	})
+
+	r := NewRegistry()
+	if err := r.AddFilesVersion("v1", v1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddFilesVersion("v2", v2); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddFilesVersion("v2", v2); err == nil {
+		t.Error("Expected duplicate version to fail")
+	}
+	if versions := r.Versions("TestMessage"); len(versions) != 2 || versions[0] != "v1" || versions[1] != "v2" {
+		t.Errorf("Unexpected versions %v", versions)
+	}
+
+	// The current version is the last one added
+	current, _ := r.GetMessage("TestMessage")
+	latest, _ := r.GetMessage(".TestMessage@v2")
+	if current != latest {
+		t.Error("Expected v2 to be the current version")
+	}
+	// Each version refers to the enum of the same version
+	enum, _ := current.GetEnum(8)
+	if _, ok := enum.GetNumber("FIFTH"); !ok {
+		t.Error("v2 message should use the v2 enum")
+	}
+	old, _ := r.GetMessage("TestMessage@v1")
+	if enum, _ := old.GetEnum(8); enum == nil {
+		t.Fatal("v1 message should have an enum")
+	} else if _, ok := enum.GetNumber("FIFTH"); ok {
+		t.Error("v1 message should use the v1 enum")
+	}
+
+	buf, err := r.Encode("TestMessage", []FieldValue{{Field: 1, Value: "hi"}})
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+	if _, err := r.Decode("TestMessage@v1", buf); err == nil {
+		t.Error("Expected decoding a string as a v1 int32 to fail")
+	}
+	values, err := r.Decode("TestMessage@v2", buf)
+	if err != nil || len(values) != 1 || values[0].Value != "hi" {
+		t.Errorf("Unexpected decode %v, %v", values, err)
+	}
+
+	if _, err := r.Decode("Missing", buf); !errors.Is(err, ErrUnresolvedType) {
+		t.Errorf("Expected ErrUnresolvedType, got %v", err)
+	}
+	if _, err := r.Encode("TestMessage@v3", nil); !errors.Is(err, ErrUnresolvedType) {
+		t.Errorf("Expected ErrUnresolvedType, got %v", err)
+	}
+}
+
+func TestRegistryAddMessageVersion(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.SetName("pkg.Msg")
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_BOOL)
+
+	r := NewRegistry()
+	if r.AddMessageVersion(fm, "") {
+		t.Error("Empty versions should be rejected")
+	}
+	if !r.AddMessageVersion(fm, "2024-01") {
+		t.Fatal("Failed to add version")
+	}
+	if r.AddMessageVersion(fm, "2024-01") {
+		t.Error("Duplicate versions should be rejected")
+	}
+	if r.AddMessage(fm) {
+		t.Error("Unversioned add should fail once the name is taken")
+	}
+
+	buf, err := r.Encode("pkg.Msg@2024-01", []FieldValue{{Field: 1, Value: true}})
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+	values, err := r.Decode("pkg.Msg", buf)
+	if err != nil || len(values) != 1 || values[0].Value != true {
+		t.Errorf("Unexpected decode %v, %v", values, err)
+	}
+}
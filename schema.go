@@ -0,0 +1,466 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file defines a plain serializable form of the ProtoFieldMap, so
+// that schemas can be kept in JSON or YAML configuration files.
+//
+// A schema looks like this in JSON:
+//
+//	{
+//	  "name": "lights.LightStatus",
+//	  "fields": [
+//	    {"number": 1, "name": "status", "type": "bool"},
+//	    {"number": 2, "name": "color", "type": "enum", "enum": "lights.Color"},
+//	    {"number": 3, "name": "zones", "type": "map", "key": "string", "value": "message", "message": "lights.Zone"},
+//	    {"number": 4, "name": "tags", "type": "string", "label": "repeated"}
+//	  ],
+//	  "oneofs": [{"name": "source", "fields": [5, 6]}],
+//	  "extension_ranges": [{"start": 100, "end": 199}],
+//	  "messages": [{"name": "lights.Zone", "fields": [{"number": 1, "name": "level", "type": "int32"}]}],
+//	  "enums": [{"name": "lights.Color", "values": [{"name": "RED", "number": 0}]}]
+//	}
+//
+// Every message and enum referenced by name, directly or through other
+// messages, is defined once in the top level "messages" and "enums" lists.
+
+package dproto
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Schema type names, in addition to the scalar types like "int32"
+const (
+	schemaTypeMessage = "message"
+	schemaTypeEnum    = "enum"
+	schemaTypeMap     = "map"
+)
+
+// MessageSchema is the serializable form of a ProtoFieldMap.
+// It has both JSON and YAML struct tags.
+type MessageSchema struct {
+	Name   string        `json:"name,omitempty" yaml:"name,omitempty"`
+	Fields []FieldSchema `json:"fields" yaml:"fields"`
+	Oneofs []OneofSchema `json:"oneofs,omitempty" yaml:"oneofs,omitempty"`
+
+	ExtensionRanges []ExtensionRangeSchema `json:"extension_ranges,omitempty" yaml:"extension_ranges,omitempty"`
+
+	// Messages and Enums define the types referenced by name. They are
+	// only used at the top level.
+	Messages []MessageSchema `json:"messages,omitempty" yaml:"messages,omitempty"`
+	Enums    []EnumSchema    `json:"enums,omitempty" yaml:"enums,omitempty"`
+}
+
+// FieldSchema is the serializable form of a single field.
+//
+// Type is a scalar type like "int32", or one of "message", "enum" or "map".
+// Message and Enum name the nested schema of message and enum fields, or of
+// map values. They may be left empty for a message or enum without a
+// schema. Key and Value hold the types of a map field.
+// Label is "optional", "required" or "repeated", and defaults to optional.
+// Packed repeated scalars are encoded in the packed form.
+type FieldSchema struct {
+	Number  FieldNum `json:"number" yaml:"number"`
+	Name    string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Type    string   `json:"type" yaml:"type"`
+	Label   string   `json:"label,omitempty" yaml:"label,omitempty"`
+	Packed  bool     `json:"packed,omitempty" yaml:"packed,omitempty"`
+	Message string   `json:"message,omitempty" yaml:"message,omitempty"`
+	Enum    string   `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Key     string   `json:"key,omitempty" yaml:"key,omitempty"`
+	Value   string   `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// OneofSchema is the serializable form of a oneof
+type OneofSchema struct {
+	Name   string     `json:"name" yaml:"name"`
+	Fields []FieldNum `json:"fields" yaml:"fields"`
+}
+
+// ExtensionRangeSchema is the serializable form of an extension range.
+// End is included, like in ExtensionRange.
+type ExtensionRangeSchema struct {
+	Start FieldNum `json:"start" yaml:"start"`
+	End   FieldNum `json:"end" yaml:"end"`
+}
+
+// EnumSchema is the serializable form of a ProtoEnum
+type EnumSchema struct {
+	Name   string            `json:"name" yaml:"name"`
+	Values []EnumValueSchema `json:"values" yaml:"values"`
+}
+
+// EnumValueSchema is the serializable form of a single enum value
+type EnumValueSchema struct {
+	Name   string `json:"name" yaml:"name"`
+	Number int32  `json:"number" yaml:"number"`
+}
+
+// Schema creates the serializable form of the ProtoFieldMap.
+//
+// Nested message schemas and enums without a name are given one, made from
+// the field name, so that they can be referenced.
+func (fm *ProtoFieldMap) Schema() *MessageSchema {
+	w := &schemaWriter{
+		msgNames:  make(map[*ProtoFieldMap]string),
+		enumNames: make(map[*ProtoEnum]string),
+		taken:     make(map[string]bool),
+	}
+	w.msgNames[fm] = fm.name
+	w.taken[fm.name] = true
+
+	s := w.message(fm)
+	for len(w.pending) > 0 {
+		sub := w.pending[0]
+		w.pending = w.pending[1:]
+		s.Messages = append(s.Messages, *w.message(sub))
+	}
+	// A reference back to an unnamed top level message names it
+	s.Name = w.msgNames[fm]
+
+	for e, name := range w.enumNames {
+		s.Enums = append(s.Enums, e.schema(name))
+	}
+	sort.Slice(s.Messages, func(i, j int) bool { return s.Messages[i].Name < s.Messages[j].Name })
+	sort.Slice(s.Enums, func(i, j int) bool { return s.Enums[i].Name < s.Enums[j].Name })
+	return s
+}
+
+// NewProtoFieldMapFromSchema creates a ProtoFieldMap from its serializable
+// form. All message and enum references must be defined in s.
+func NewProtoFieldMapFromSchema(s *MessageSchema) (*ProtoFieldMap, error) {
+	r := &schemaReader{
+		messages: make(map[string]*ProtoFieldMap),
+		enums:    make(map[string]*ProtoEnum),
+	}
+
+	for _, es := range s.Enums {
+		if _, dup := r.enums[es.Name]; dup || es.Name == "" {
+			return nil, fmt.Errorf("invalid or duplicate enum name %q", es.Name)
+		}
+		e := NewProtoEnum(es.Name)
+		for _, v := range es.Values {
+			if !e.Add(v.Name, v.Number) {
+				return nil, fmt.Errorf("enum %s: duplicate value %s", es.Name, v.Name)
+			}
+		}
+		r.enums[es.Name] = e
+	}
+
+	// Declare every message first, so that they can reference each other
+	fm := NewProtoFieldMap()
+	fm.SetName(s.Name)
+	if s.Name != "" {
+		r.messages[s.Name] = fm
+	}
+	subs := make([]*ProtoFieldMap, len(s.Messages))
+	for i, ms := range s.Messages {
+		if _, dup := r.messages[ms.Name]; dup || ms.Name == "" {
+			return nil, fmt.Errorf("invalid or duplicate message name %q", ms.Name)
+		}
+		subs[i] = NewProtoFieldMap()
+		subs[i].SetName(ms.Name)
+		r.messages[ms.Name] = subs[i]
+	}
+
+	if err := r.fill(fm, s); err != nil {
+		return nil, err
+	}
+	for i := range s.Messages {
+		if err := r.fill(subs[i], &s.Messages[i]); err != nil {
+			return nil, err
+		}
+	}
+	return fm, nil
+}
+
+// MarshalJSON encodes the ProtoFieldMap as its MessageSchema
+func (fm *ProtoFieldMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fm.Schema())
+}
+
+// UnmarshalJSON replaces the contents of the ProtoFieldMap with the
+// MessageSchema encoded in data
+func (fm *ProtoFieldMap) UnmarshalJSON(data []byte) error {
+	var s MessageSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return fm.setSchema(&s)
+}
+
+// MarshalYAML returns the MessageSchema of the ProtoFieldMap, which makes
+// YAML libraries, such as gopkg.in/yaml, encode it the same way as JSON
+func (fm *ProtoFieldMap) MarshalYAML() (interface{}, error) {
+	return fm.Schema(), nil
+}
+
+// UnmarshalYAML replaces the contents of the ProtoFieldMap with the
+// MessageSchema decoded by unmarshal. It implements the Unmarshaler
+// interface of gopkg.in/yaml.
+func (fm *ProtoFieldMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s MessageSchema
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return fm.setSchema(&s)
+}
+
+// setSchema replaces the contents of the ProtoFieldMap with s.
+// References back to the top level message point at fm itself.
+func (fm *ProtoFieldMap) setSchema(s *MessageSchema) error {
+	fm.checkMutable()
+	loaded, err := NewProtoFieldMapFromSchema(s)
+	if err != nil {
+		return err
+	}
+	*fm = *loaded
+	redirect(fm, loaded, fm, make(map[*ProtoFieldMap]bool))
+	return nil
+}
+
+// redirect replaces references to from with to, in fm and everything
+// reachable from it
+func redirect(fm, from, to *ProtoFieldMap, visited map[*ProtoFieldMap]bool) {
+	if visited[fm] {
+		return
+	}
+	visited[fm] = true
+	for field, sub := range fm.field2msg {
+		if sub == from {
+			fm.field2msg[field] = to
+		} else {
+			redirect(sub, from, to, visited)
+		}
+	}
+	for field, entry := range fm.maps {
+		if entry.valueMsg == from {
+			entry.valueMsg = to
+			fm.maps[field] = entry
+		} else if entry.valueMsg != nil {
+			redirect(entry.valueMsg, from, to, visited)
+		}
+	}
+}
+
+// schemaWriter names the nested schemas found while writing a MessageSchema
+type schemaWriter struct {
+	msgNames  map[*ProtoFieldMap]string
+	enumNames map[*ProtoEnum]string
+	taken     map[string]bool
+	pending   []*ProtoFieldMap
+}
+
+// uniqueName returns name, or name with a number appended if it's taken
+func (w *schemaWriter) uniqueName(name string) string {
+	unique := name
+	for i := 2; w.taken[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	w.taken[unique] = true
+	return unique
+}
+
+// messageRef returns the name used to reference sub, queueing it to
+// be defined
+func (w *schemaWriter) messageRef(sub *ProtoFieldMap, scope, fieldName string) string {
+	if name, ok := w.msgNames[sub]; ok && name != "" {
+		return name
+	}
+	name := sub.name
+	if name == "" {
+		name = w.uniqueName(qualify(scope, camelCase(fieldName)))
+	} else {
+		w.taken[name] = true
+	}
+	if _, seen := w.msgNames[sub]; !seen {
+		w.pending = append(w.pending, sub)
+	}
+	w.msgNames[sub] = name
+	return name
+}
+
+// enumRef returns the name used to reference e
+func (w *schemaWriter) enumRef(e *ProtoEnum, scope, fieldName string) string {
+	if name, ok := w.enumNames[e]; ok {
+		return name
+	}
+	name := e.name
+	if name == "" {
+		name = w.uniqueName(qualify(scope, camelCase(fieldName)))
+	}
+	w.enumNames[e] = name
+	return name
+}
+
+// message writes the fields and oneofs of fm
+func (w *schemaWriter) message(fm *ProtoFieldMap) *MessageSchema {
+	s := &MessageSchema{Name: w.msgNames[fm], Fields: []FieldSchema{}}
+	scope := s.Name
+
+	for _, field := range fm.GetFieldNums() {
+		name, _ := fm.GetFieldName(field)
+		fieldName := fm.descriptorFieldName(field)
+		f := FieldSchema{
+			Number: field,
+			Name:   name,
+			Type:   typeString(fm.field2type[field]),
+		}
+		if label, ok := fm.field2label[field]; ok && label != descriptor.FieldDescriptorProto_LABEL_OPTIONAL {
+			f.Label = labelString(label)
+		}
+		f.Packed = fm.IsPacked(field)
+
+		sub, hasMsg := fm.field2msg[field]
+		enum, hasEnum := fm.field2enum[field]
+		if entry, isMap := fm.maps[field]; isMap {
+			f.Type = schemaTypeMap
+			f.Key = typeString(entry.key)
+			f.Value = typeString(entry.value)
+			sub, hasMsg = entry.valueMsg, entry.valueMsg != nil
+			enum, hasEnum = entry.valueEnum, entry.valueEnum != nil
+		}
+		if hasMsg {
+			f.Message = w.messageRef(sub, scope, fieldName)
+		}
+		if hasEnum {
+			f.Enum = w.enumRef(enum, scope, fieldName)
+		}
+		s.Fields = append(s.Fields, f)
+	}
+
+	for name, fields := range fm.oneofs {
+		s.Oneofs = append(s.Oneofs, OneofSchema{Name: name, Fields: append([]FieldNum(nil), fields...)})
+	}
+	sort.Slice(s.Oneofs, func(i, j int) bool { return s.Oneofs[i].Name < s.Oneofs[j].Name })
+
+	for _, r := range fm.extRanges {
+		s.ExtensionRanges = append(s.ExtensionRanges, ExtensionRangeSchema{Start: r.Start, End: r.End})
+	}
+	return s
+}
+
+// schema creates the serializable form of e under name
+func (e *ProtoEnum) schema(name string) EnumSchema {
+	s := EnumSchema{Name: name, Values: []EnumValueSchema{}}
+	for _, value := range e.Names() {
+		s.Values = append(s.Values, EnumValueSchema{Name: value, Number: e.name2number[value]})
+	}
+	return s
+}
+
+// schemaReader resolves the references of a MessageSchema
+type schemaReader struct {
+	messages map[string]*ProtoFieldMap
+	enums    map[string]*ProtoEnum
+}
+
+// fill adds the fields and oneofs of s to fm
+func (r *schemaReader) fill(fm *ProtoFieldMap, s *MessageSchema) error {
+	for _, f := range s.Fields {
+		if err := r.field(fm, f); err != nil {
+			return fmt.Errorf("%sfield %d: %w", schemaPrefix(s), f.Number, err)
+		}
+	}
+	for _, o := range s.Oneofs {
+		if !fm.AddOneof(o.Name, o.Fields...) {
+			return fmt.Errorf("%sinvalid oneof %s", schemaPrefix(s), o.Name)
+		}
+	}
+	for _, r := range s.ExtensionRanges {
+		if !fm.AddExtensionRange(r.Start, r.End) {
+			return fmt.Errorf("%sinvalid extension range %d to %d", schemaPrefix(s), r.Start, r.End)
+		}
+	}
+	return nil
+}
+
+// schemaPrefix prefixes error messages with the message name, if known
+func schemaPrefix(s *MessageSchema) string {
+	if s.Name == "" {
+		return ""
+	}
+	return s.Name + ": "
+}
+
+// field adds the field described by f to fm
+func (r *schemaReader) field(fm *ProtoFieldMap, f FieldSchema) error {
+	var sub *ProtoFieldMap
+	var enum *ProtoEnum
+	if f.Message != "" {
+		var ok bool
+		if sub, ok = r.messages[f.Message]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnresolvedType, f.Message)
+		}
+	}
+	if f.Enum != "" {
+		var ok bool
+		if enum, ok = r.enums[f.Enum]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnresolvedType, f.Enum)
+		}
+	}
+
+	switch f.Type {
+	case schemaTypeMap:
+		key, ok := ParseProtobufType(f.Key)
+		if !ok {
+			return fmt.Errorf("%w: map key %q", ErrInvalidProtoBufType, f.Key)
+		}
+		value, ok := parseSchemaType(f.Value)
+		if !ok || !fm.AddMap(f.Number, key, value) {
+			return fmt.Errorf("%w: map<%s, %s>", ErrInvalidProtoBufType, f.Key, f.Value)
+		}
+		entry := fm.maps[f.Number]
+		entry.valueMsg, entry.valueEnum = sub, enum
+		fm.maps[f.Number] = entry
+	case schemaTypeMessage:
+		if sub != nil {
+			fm.AddMessage(f.Number, sub)
+		} else {
+			fm.Add(f.Number, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+		}
+	case schemaTypeEnum:
+		if enum != nil {
+			fm.AddEnum(f.Number, enum)
+		} else {
+			fm.Add(f.Number, descriptor.FieldDescriptorProto_TYPE_ENUM)
+		}
+	default:
+		typ, ok := ParseProtobufType(f.Type)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrInvalidProtoBufType, f.Type)
+		}
+		fm.Add(f.Number, typ)
+	}
+
+	if f.Label != "" {
+		label, ok := descriptor.FieldDescriptorProto_Label_value["LABEL_"+strings.ToUpper(f.Label)]
+		if !ok {
+			return fmt.Errorf("invalid label %q", f.Label)
+		}
+		fm.SetLabel(f.Number, descriptor.FieldDescriptorProto_Label(label))
+	}
+	if f.Packed && !fm.SetPacked(f.Number, true) {
+		return fmt.Errorf("%w: %s can not be packed", ErrInvalidProtoBufType, f.Type)
+	}
+	if f.Name != "" && !fm.SetFieldName(f.Number, f.Name) {
+		return fmt.Errorf("duplicate field name %s", f.Name)
+	}
+	return nil
+}
+
+// parseSchemaType parses a scalar type name, or "message" or "enum"
+func parseSchemaType(name string) (descriptor.FieldDescriptorProto_Type, bool) {
+	switch name {
+	case schemaTypeMessage:
+		return descriptor.FieldDescriptorProto_TYPE_MESSAGE, true
+	case schemaTypeEnum:
+		return descriptor.FieldDescriptorProto_TYPE_ENUM, true
+	}
+	return ParseProtobufType(name)
+}
@@ -0,0 +1,145 @@
+package dproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const lightSchemaJSON = `{
+  "name": "lights.LightStatus",
+  "fields": [
+    {"number": 1, "name": "status", "type": "bool"},
+    {"number": 2, "name": "color", "type": "enum", "enum": "lights.Color"},
+    {"number": 3, "name": "zones", "type": "map", "key": "string", "value": "message", "message": "lights.Zone"},
+    {"number": 4, "name": "tags", "type": "string", "label": "repeated"},
+    {"number": 5, "name": "manual", "type": "bool"},
+    {"number": 6, "name": "sensor", "type": "string"},
+    {"number": 7, "name": "parent", "type": "message", "message": "lights.LightStatus"}
+  ],
+  "oneofs": [{"name": "source", "fields": [5, 6]}],
+  "messages": [{"name": "lights.Zone", "fields": [{"number": 1, "name": "level", "type": "int32"}]}],
+  "enums": [{"name": "lights.Color", "values": [{"name": "RED", "number": 0}, {"name": "BLUE", "number": 1}]}]
+}`
+
+func TestProtoFieldMapUnmarshalJSON(t *testing.T) {
+	fm := NewProtoFieldMap()
+	if err := json.Unmarshal([]byte(lightSchemaJSON), fm); err != nil {
+		t.Fatal(err)
+	}
+	if fm.Name() != "lights.LightStatus" {
+		t.Errorf("Unexpected name %s", fm.Name())
+	}
+	if field, ok := fm.GetFieldByName("tags"); !ok || fm.GetLabel(field) != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		t.Error("tags should be repeated")
+	}
+	if _, value, ok := fm.GetMap(3); !ok || value != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		t.Error("zones should be a map of messages")
+	}
+	if parent, _ := fm.GetMessage(7); parent != fm {
+		t.Error("parent should refer back to the top level message")
+	}
+	if fields, ok := fm.GetOneof("source"); !ok || len(fields) != 2 {
+		t.Error("source oneof was not loaded")
+	}
+
+	values := []FieldValue{
+		{Field: 2, Value: "BLUE"},
+		{Field: 3, Value: map[string]interface{}{"kitchen": []FieldValue{{Field: 1, Value: int32(7)}}}},
+		{Field: 7, Value: []FieldValue{{Field: 1, Value: true}}},
+	}
+	if _, err := fm.EncodeBuffer(values); err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+}
+
+func TestProtoFieldMapJSONRoundTrip(t *testing.T) {
+	fm := NewProtoFieldMap()
+	if err := json.Unmarshal([]byte(lightSchemaJSON), fm); err != nil {
+		t.Fatal(err)
+	}
+	first, err := json.Marshal(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again := NewProtoFieldMap()
+	if err := json.Unmarshal(first, again); err != nil {
+		t.Fatal(err)
+	}
+	second, err := json.Marshal(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("Schema is not stable:\n%s\n%s", first, second)
+	}
+}
+
+func TestProtoFieldMapSchemaNamesNested(t *testing.T) {
+	point := NewProtoFieldMap()
+	point.Add(1, descriptor.FieldDescriptorProto_TYPE_SINT32)
+
+	fm := NewProtoFieldMap()
+	fm.SetName("Shape")
+	fm.AddMessage(1, point)
+	fm.SetFieldName(1, "center")
+	fm.AddMessage(2, point)
+
+	s := fm.Schema()
+	if len(s.Messages) != 1 || s.Messages[0].Name != "Shape.Center" {
+		t.Fatalf("Expected one nested message Shape.Center, got %+v", s.Messages)
+	}
+	if s.Fields[1].Message != "Shape.Center" {
+		t.Error("Shared nested schema should be referenced by the same name")
+	}
+}
+
+func TestProtoFieldMapYAML(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_FIXED64)
+	fm.SetFieldName(1, "id")
+
+	v, err := fm.MarshalYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stand in for a YAML library, which would fill in the schema
+	unmarshal := func(out interface{}) error {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(buf, out)
+	}
+	loaded := NewProtoFieldMap()
+	if err := loaded.UnmarshalYAML(unmarshal); err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := loaded.Get(1); typ != descriptor.FieldDescriptorProto_TYPE_FIXED64 {
+		t.Error("Field 1 was not loaded")
+	}
+}
+
+func TestProtoFieldMapUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`{"fields": [{"number": 1, "type": "int33"}]}`, "Invalid protobuf type"},
+		{`{"fields": [{"number": 1, "type": "message", "message": "Missing"}]}`, "Unresolved type name"},
+		{`{"fields": [{"number": 1, "type": "int32", "label": "sometimes"}]}`, "invalid label"},
+		{`{"fields": [{"number": 1, "type": "map", "key": "double", "value": "int32"}]}`, "field 1: Invalid protobuf type: map<double, int32>"},
+		{`{"fields": [], "oneofs": [{"name": "o", "fields": [1]}]}`, "invalid oneof"},
+	}
+	for _, test := range tests {
+		fm := NewProtoFieldMap()
+		err := json.Unmarshal([]byte(test.json), fm)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: expected error containing %q, got %v", test.json, test.want, err)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file maps plain Go structs onto WireMessages, using struct tags
+// instead of a ProtoFieldMap. This suits schemas known at compile time.
+//
+// A field is tagged with its field number and Protobuf type, like
+//
+//	ID    int64             `dproto:"1,sint64"`
+//	Inner *Inner            `dproto:"2,message"`
+//	Tags  []string          `dproto:"3,string"`
+//	Count *uint32           `dproto:"4"`
+//	Attrs map[string]int32  `dproto:"5,map,string,int32"`
+//
+// The type may be left out, in which case it is inferred from the Go type.
+// Enums use the type "enum" on any integer field. Map fields use "map",
+// optionally followed by the key and value types.
+//
+// Slices are repeated fields, except for []byte, which is bytes. Nil pointers
+// are absent fields. Other fields holding their zero value are not encoded.
+// Untagged fields and fields tagged "-" are ignored.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ErrInvalidStruct is returned when a value can not be mapped onto a
+// Protobuf message, like a struct with a malformed dproto tag
+var ErrInvalidStruct = errors.New("Invalid struct for dproto")
+
+const (
+	structTagName   = "dproto"
+	structTagMap    = "map"
+	structTagEnum   = "enum"
+	structTagMsg    = "message"
+	structTagIgnore = "-"
+)
+
+// structField describes a single tagged struct field
+type structField struct {
+	index int
+	name  string
+	field FieldNum
+	typ   descriptor.FieldDescriptorProto_Type
+	// key and value are the entry types of map fields
+	isMap      bool
+	key, value descriptor.FieldDescriptorProto_Type
+}
+
+// structInfos caches the parsed tags of every struct type seen
+var structInfos sync.Map // reflect.Type -> []structField
+
+// MarshalStruct encodes the tagged fields of v, which must be a struct or a
+// pointer to one, into a Protobuf buffer
+func MarshalStruct(v interface{}) ([]byte, error) {
+	m, err := EncodeStruct(v)
+	if err != nil {
+		return nil, err
+	}
+	return m.Marshal()
+}
+
+// UnmarshalStruct decodes the Protobuf buffer buf into v, which must be a
+// pointer to a struct. The struct is cleared first. Fields in buf that
+// have no tagged struct field are ignored.
+func UnmarshalStruct(buf []byte, v interface{}) error {
+	m, err := Unmarshal(buf)
+	if err != nil {
+		return err
+	}
+	return DecodeStruct(m, v)
+}
+
+// EncodeStruct encodes the tagged fields of v, which must be a struct or a
+// pointer to one, into a new WireMessage
+func EncodeStruct(v interface{}) (*WireMessage, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: expected a struct, got %T", ErrInvalidStruct, v)
+	}
+	return encodeStruct(rv)
+}
+
+// DecodeStruct decodes the WireMessage m into v, which must be a pointer to
+// a struct. See UnmarshalStruct.
+func DecodeStruct(m *WireMessage, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: expected a pointer to a struct, got %T", ErrInvalidStruct, v)
+	}
+	return decodeStruct(m, rv.Elem())
+}
+
+// getStructFields returns the tagged fields of struct type t
+func getStructFields(t reflect.Type) ([]structField, error) {
+	if fields, ok := structInfos.Load(t); ok {
+		return fields.([]structField), nil
+	}
+
+	var fields []structField
+	seen := make(map[FieldNum]string)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup(structTagName)
+		if !ok || tag == structTagIgnore {
+			continue
+		}
+		f, err := parseStructTag(sf, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s.%s: %v", ErrInvalidStruct, t.Name(), sf.Name, err)
+		}
+		f.index = i
+		if other, dup := seen[f.field]; dup {
+			return nil, fmt.Errorf("%w: %s: fields %s and %s both use number %d", ErrInvalidStruct, t.Name(), other, sf.Name, f.field)
+		}
+		seen[f.field] = sf.Name
+		fields = append(fields, f)
+	}
+
+	structInfos.Store(t, fields)
+	return fields, nil
+}
+
+// parseStructTag parses the dproto tag of sf and checks it against the
+// Go type of the field
+func parseStructTag(sf reflect.StructField, tag string) (structField, error) {
+	f := structField{name: sf.Name}
+	if sf.PkgPath != "" {
+		return f, errors.New("field is not exported")
+	}
+
+	parts := strings.Split(tag, ",")
+	number, err := strconv.ParseUint(parts[0], 10, 29)
+	if err != nil || number == 0 {
+		return f, fmt.Errorf("invalid field number %q", parts[0])
+	}
+	f.field = FieldNum(number)
+	t := sf.Type
+
+	if len(parts) > 1 && parts[1] == structTagMap {
+		if t.Kind() != reflect.Map || len(parts) > 4 {
+			return f, errors.New("map tag needs a map field, with at most a key and value type")
+		}
+		f.isMap = true
+		f.typ = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+		if f.key, err = structFieldType(t.Key(), parts[2:]); err != nil {
+			return f, err
+		}
+		if !validMapKeyType(f.key) {
+			return f, fmt.Errorf("invalid map key type %s", typeString(f.key))
+		}
+		var valueParts []string
+		if len(parts) > 3 {
+			valueParts = parts[3:]
+		}
+		f.value, err = structFieldType(t.Elem(), valueParts)
+		return f, err
+	}
+	if len(parts) > 2 {
+		return f, fmt.Errorf("too many options in %q", tag)
+	}
+
+	// Strip the slice of repeated fields and the pointer of optional ones
+	elem := t
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		elem = t.Elem()
+	} else if t.Kind() == reflect.Ptr {
+		elem = t.Elem()
+	}
+	f.typ, err = structFieldType(elem, parts[1:])
+	return f, err
+}
+
+// structFieldType finds the Protobuf type of a value of Go type t, given
+// the optional type name from the tag
+func structFieldType(t reflect.Type, name []string) (descriptor.FieldDescriptorProto_Type, error) {
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		t = t.Elem()
+	}
+	if len(name) == 0 || name[0] == "" {
+		typ, ok := inferProtoType(t)
+		if !ok {
+			return 0, fmt.Errorf("can not infer Protobuf type of %s", t)
+		}
+		return typ, nil
+	}
+
+	var typ descriptor.FieldDescriptorProto_Type
+	switch name[0] {
+	case structTagMsg:
+		typ = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+	case structTagEnum:
+		typ = descriptor.FieldDescriptorProto_TYPE_ENUM
+	default:
+		var ok bool
+		if typ, ok = ParseProtobufType(name[0]); !ok {
+			return 0, fmt.Errorf("unknown Protobuf type %q", name[0])
+		}
+	}
+	if !goTypeFits(t, typ) {
+		return 0, fmt.Errorf("%s can not hold a %s", t, typeString(typ))
+	}
+	return typ, nil
+}
+
+// inferProtoType picks the natural Protobuf type for Go type t
+func inferProtoType(t reflect.Type) (descriptor.FieldDescriptorProto_Type, bool) {
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return descriptor.FieldDescriptorProto_TYPE_INT32, true
+	case reflect.Int, reflect.Int64:
+		return descriptor.FieldDescriptorProto_TYPE_INT64, true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return descriptor.FieldDescriptorProto_TYPE_UINT32, true
+	case reflect.Uint, reflect.Uint64:
+		return descriptor.FieldDescriptorProto_TYPE_UINT64, true
+	case reflect.Bool:
+		return descriptor.FieldDescriptorProto_TYPE_BOOL, true
+	case reflect.Float32:
+		return descriptor.FieldDescriptorProto_TYPE_FLOAT, true
+	case reflect.Float64:
+		return descriptor.FieldDescriptorProto_TYPE_DOUBLE, true
+	case reflect.String:
+		return descriptor.FieldDescriptorProto_TYPE_STRING, true
+	case reflect.Struct:
+		return descriptor.FieldDescriptorProto_TYPE_MESSAGE, true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return descriptor.FieldDescriptorProto_TYPE_BYTES, true
+		}
+	}
+	return 0, false
+}
+
+// goTypeFits indicates if values of the Protobuf type typ can be stored in
+// Go type t. Integers of any width fit, but values outside the range of
+// either type fail with ErrOverflow when encoding or decoding.
+func goTypeFits(t reflect.Type, typ descriptor.FieldDescriptorProto_Type) bool {
+	isInt := t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64
+	isUint := t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return isInt
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return isUint
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return isInt || isUint
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return t.Kind() == reflect.Bool
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return t.Kind() == reflect.String
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return t.Kind() == reflect.Struct
+	}
+	return false
+}
+
+/////////////////////////////// Encoding /////////////////////////////////////
+
+// encodeStruct encodes the struct value rv into a new WireMessage
+func encodeStruct(rv reflect.Value) (*WireMessage, error) {
+	fields, err := getStructFields(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	m := NewWireMessage()
+	for _, f := range fields {
+		if err := f.encode(m, rv.Field(f.index)); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return m, nil
+}
+
+// encode adds the struct field value fv to m
+func (f structField) encode(m *WireMessage, fv reflect.Value) error {
+	switch {
+	case f.isMap:
+		keys := fv.MapKeys()
+		wireKeys := make([]interface{}, len(keys))
+		for i, k := range keys {
+			key, err := toWireValue(k, f.key)
+			if err != nil {
+				return err
+			}
+			wireKeys[i] = key
+		}
+		// Entries are emitted in sorted key order, like mapEntryType.encode
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool {
+			return lessMapKey(wireKeys[order[i]], wireKeys[order[j]])
+		})
+		for _, i := range order {
+			entry := NewWireMessage()
+			if err := entry.EncodeAs(mapEntryKeyField, wireKeys[i], f.key); err != nil {
+				return err
+			}
+			v, err := toWireValue(fv.MapIndex(keys[i]), f.value)
+			if err != nil {
+				return err
+			}
+			if err := entry.EncodeAs(mapEntryValueField, v, f.value); err != nil {
+				return err
+			}
+			buf, err := entry.Marshal()
+			if err != nil {
+				return err
+			}
+			m.AppendBytes(f.field, buf)
+		}
+	case fv.Kind() == reflect.Slice && f.typ != descriptor.FieldDescriptorProto_TYPE_BYTES:
+		for i := 0; i < fv.Len(); i++ {
+			v, err := toWireValue(fv.Index(i), f.typ)
+			if err != nil {
+				return err
+			}
+			if err := m.AppendAs(f.field, v, f.typ); err != nil {
+				return err
+			}
+		}
+	case fv.Kind() == reflect.Ptr:
+		if fv.IsNil() {
+			return nil
+		}
+		v, err := toWireValue(fv, f.typ)
+		if err != nil {
+			return err
+		}
+		return m.EncodeAs(f.field, v, f.typ)
+	default:
+		if fv.IsZero() {
+			return nil
+		}
+		v, err := toWireValue(fv, f.typ)
+		if err != nil {
+			return err
+		}
+		return m.EncodeAs(f.field, v, f.typ)
+	}
+	return nil
+}
+
+// toWireValue converts a Go value into the exact type EncodeAs expects
+// for the Protobuf type typ
+func toWireValue(v reflect.Value, typ descriptor.FieldDescriptorProto_Type) (interface{}, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			// Elements of repeated fields and maps can't be absent
+			return zeroValue(typ), nil
+		}
+		v = v.Elem()
+	}
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return encodeStruct(v)
+	case descriptor.FieldDescriptorProto_TYPE_BOOL,
+		descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return v.Convert(reflect.TypeOf(zeroValue(typ))).Interface(), nil
+	}
+	// Go integers may be wider than the Protobuf type
+	return CoerceAs(v.Interface(), typ)
+}
+
+/////////////////////////////// Decoding /////////////////////////////////////
+
+// decodeStruct clears the struct value rv and decodes m into it
+func decodeStruct(m *WireMessage, rv reflect.Value) error {
+	fields, err := getStructFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.Zero(rv.Type()))
+	for _, f := range fields {
+		if err := f.decode(m, rv.Field(f.index)); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// decode sets the struct field value fv from m
+func (f structField) decode(m *WireMessage, fv reflect.Value) error {
+	switch {
+	case f.isMap:
+		entries := m.GetRepeatedBytes(f.field)
+		if len(entries) == 0 {
+			return nil
+		}
+		fv.Set(reflect.MakeMapWithSize(fv.Type(), len(entries)))
+		for _, buf := range entries {
+			entry, err := Unmarshal(buf)
+			if err != nil {
+				return err
+			}
+			k, err := decodeEntryField(entry, mapEntryKeyField, f.key)
+			if err != nil {
+				return err
+			}
+			v, err := decodeEntryField(entry, mapEntryValueField, f.value)
+			if err != nil {
+				return err
+			}
+			key := reflect.New(fv.Type().Key()).Elem()
+			if err := fromWireValue(k, key, f.key); err != nil {
+				return err
+			}
+			value := reflect.New(fv.Type().Elem()).Elem()
+			if err := fromWireValue(v, value, f.value); err != nil {
+				return err
+			}
+			fv.SetMapIndex(key, value)
+		}
+	case fv.Kind() == reflect.Slice && f.typ != descriptor.FieldDescriptorProto_TYPE_BYTES:
+		vals, err := m.DecodeRepeatedAs(f.field, f.typ)
+		if err == ErrMessageFieldMissing {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, v := range vals {
+			if err := fromWireValue(v, slice.Index(i), f.typ); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+	default:
+		v, err := m.DecodeAs(f.field, f.typ)
+		if err == ErrMessageFieldMissing {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return fromWireValue(v, fv, f.typ)
+	}
+	return nil
+}
+
+// fromWireValue stores v, as returned by DecodeAs for the Protobuf type
+// typ, into dst. Pointers are allocated as needed.
+func fromWireValue(v interface{}, dst reflect.Value, typ descriptor.FieldDescriptorProto_Type) error {
+	if dst.Kind() == reflect.Ptr {
+		dst.Set(reflect.New(dst.Type().Elem()))
+		dst = dst.Elem()
+	}
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return decodeStruct(v.(*WireMessage), dst)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// Enums are sign extended int32 values
+		v = int64(v.(uint64))
+	}
+	rv := reflect.ValueOf(v)
+	// Go integers may be narrower than the Protobuf type
+	if overflows(rv, dst.Type()) {
+		return fmt.Errorf("%w: %v does not fit in %s", ErrOverflow, v, dst.Type())
+	}
+	dst.Set(rv.Convert(dst.Type()))
+	return nil
+}
+
+// overflows indicates if the number rv is out of the range of the numeric
+// type t. Rounding a double to a float is not an overflow.
+func overflows(rv reflect.Value, t reflect.Type) bool {
+	z := reflect.Zero(t)
+	switch {
+	case rv.CanInt() && z.CanInt():
+		return z.OverflowInt(rv.Int())
+	case rv.CanInt() && z.CanUint():
+		return rv.Int() < 0 || z.OverflowUint(uint64(rv.Int()))
+	case rv.CanUint() && z.CanUint():
+		return z.OverflowUint(rv.Uint())
+	case rv.CanUint() && z.CanInt():
+		return rv.Uint() > math.MaxInt64 || z.OverflowInt(int64(rv.Uint()))
+	case rv.CanFloat() && z.CanFloat():
+		return !math.IsInf(rv.Float(), 0) && z.OverflowFloat(rv.Float())
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package dproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+type structTestColor int32
+
+type structTestInner struct {
+	Label string           `dproto:"1"`
+	Next  *structTestInner `dproto:"2,message"`
+}
+
+type structTestOuter struct {
+	ID      int64                       `dproto:"1,sint64"`
+	Inner   *structTestInner            `dproto:"2,message"`
+	Tags    []string                    `dproto:"3,string"`
+	Count   *uint32                     `dproto:"4,fixed32"`
+	Attrs   map[string]int32            `dproto:"5,map,string,sint32"`
+	Color   structTestColor             `dproto:"6,enum"`
+	Data    []byte                      `dproto:"7"`
+	Items   []structTestInner           `dproto:"8"`
+	ByID    map[uint64]*structTestInner `dproto:"9,map"`
+	Ratio   float32                     `dproto:"10,double"`
+	Ignored string                      `dproto:"-"`
+	Other   string
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	count := uint32(0)
+	in := structTestOuter{
+		ID:    -42,
+		Inner: &structTestInner{Label: "a", Next: &structTestInner{Label: "b"}},
+		Tags:  []string{"x", "y"},
+		Count: &count,
+		Attrs: map[string]int32{"one": -1, "two": 2},
+		Color: 3,
+		Data:  []byte{1, 2},
+		Items: []structTestInner{{Label: "i0"}, {Label: "i1"}},
+		ByID:  map[uint64]*structTestInner{7: {Label: "seven"}},
+		Ratio: 0.5,
+		Other: "not encoded",
+	}
+
+	buf, err := MarshalStruct(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out structTestOuter
+	out.Ignored = "cleared"
+	if err := UnmarshalStruct(buf, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	in.Other = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Round trip mismatch:\n in: %+v\nout: %+v", in, out)
+	}
+	// A set pointer keeps its presence, even holding the zero value
+	if out.Count == nil {
+		t.Error("Present zero field lost its presence")
+	}
+}
+
+func TestStructMatchesFieldMap(t *testing.T) {
+	count := uint32(9)
+	buf, err := MarshalStruct(structTestOuter{ID: -3, Count: &count, Attrs: map[string]int32{"k": -7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_SINT64)
+	fm.Add(4, descriptor.FieldDescriptorProto_TYPE_FIXED32)
+	fm.AddMap(5, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_SINT32)
+	values, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FieldValue{
+		{1, int64(-3)},
+		{4, uint32(9)},
+		{5, map[interface{}]interface{}{"k": int32(-7)}},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestStructZeroValuesOmitted(t *testing.T) {
+	buf, err := MarshalStruct(structTestOuter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != 0 {
+		t.Errorf("Expected an empty buffer, got %v", buf)
+	}
+}
+
+func TestStructInvalid(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			A string `dproto:"1,int32"`
+		}{},
+		&struct {
+			A int32 `dproto:"0"`
+		}{},
+		&struct {
+			A int32 `dproto:"1"`
+			B int32 `dproto:"1"`
+		}{},
+		&struct {
+			A map[float64]int32 `dproto:"1,map"`
+		}{},
+		&struct {
+			a int32 `dproto:"1"`
+		}{},
+		&struct {
+			A int32 `dproto:"1,nosuchtype"`
+		}{},
+		42,
+	}
+	for i, v := range tests {
+		if _, err := MarshalStruct(v); !errors.Is(err, ErrInvalidStruct) {
+			t.Errorf("Test %d: expected ErrInvalidStruct, got %v", i, err)
+		}
+	}
+	if err := UnmarshalStruct(nil, structTestOuter{}); !errors.Is(err, ErrInvalidStruct) {
+		t.Errorf("Expected ErrInvalidStruct for a non pointer, got %v", err)
+	}
+}
+
+func TestStructOverflow(t *testing.T) {
+	type wide struct {
+		Count int64   `dproto:"1,int32"`
+		Size  uint64  `dproto:"2,fixed32"`
+		Ratio float64 `dproto:"3,float"`
+		Color int64   `dproto:"4,enum"`
+	}
+	type narrow struct {
+		Count int8    `dproto:"1,int32"`
+		Size  uint16  `dproto:"2,fixed32"`
+		Ratio float32 `dproto:"3,float"`
+		Color uint8   `dproto:"4,enum"`
+	}
+
+	encodeTests := []wide{
+		{Count: 1 << 40},
+		{Size: 1 << 32},
+		{Ratio: 1e300},
+		{Color: 1 << 31},
+	}
+	for i, v := range encodeTests {
+		if _, err := MarshalStruct(&v); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Encode test %d: expected ErrOverflow, got %v", i, err)
+		}
+	}
+
+	decodeTests := []wide{
+		{Count: 300},
+		{Count: -129},
+		{Size: 1 << 16},
+		{Color: -1},
+	}
+	for i, v := range decodeTests {
+		buf, err := MarshalStruct(&v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n narrow
+		if err := UnmarshalStruct(buf, &n); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Decode test %d: expected ErrOverflow, got %v (%+v)", i, err, n)
+		}
+	}
+
+	buf, err := MarshalStruct(&wide{Count: -128, Size: 65535, Ratio: 0.5, Color: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n narrow
+	if err := UnmarshalStruct(buf, &n); err != nil || n != (narrow{-128, 65535, 0.5, 255}) {
+		t.Errorf("Values in range did not decode: %+v (%v)", n, err)
+	}
+}
+
+func TestStructMapKeyOverflow(t *testing.T) {
+	type attrs struct {
+		Attrs map[int64]string `dproto:"5,map,int32,string"`
+	}
+	v := attrs{Attrs: map[int64]string{1 << 40: "a", 1 << 41: "b"}}
+	if _, err := MarshalStruct(&v); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow for an out of range map key, got %v", err)
+	}
+}
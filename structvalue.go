@@ -0,0 +1,279 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the well-known types Struct, Value and ListValue, which
+// carry arbitrary JSON-like data. They convert to and from the Go values
+// encoding/json uses: map[string]interface{}, []interface{}, float64,
+// string, bool and nil.
+
+package dproto
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Names of the JSON-like well-known types
+const (
+	StructName    = "google.protobuf.Struct"
+	ValueName     = "google.protobuf.Value"
+	ListValueName = "google.protobuf.ListValue"
+	NullValueName = "google.protobuf.NullValue"
+)
+
+// Field numbers of the kind oneof in Value
+const (
+	valueNullField FieldNum = iota + 1
+	valueNumberField
+	valueStringField
+	valueBoolField
+	valueStructField
+	valueListField
+)
+
+// newStructSchemas creates the schemas of Struct, Value and ListValue,
+// which refer to each other
+func newStructSchemas() (structFM, valueFM, listFM *ProtoFieldMap) {
+	null := NewProtoEnum(NullValueName)
+	null.Add("NULL_VALUE", 0)
+
+	structFM, valueFM, listFM = NewProtoFieldMap(), NewProtoFieldMap(), NewProtoFieldMap()
+	structFM.SetName(StructName)
+	structFM.AddMap(1, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	structFM.maps[1] = mapEntryType{
+		key:      descriptor.FieldDescriptorProto_TYPE_STRING,
+		value:    descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		valueMsg: valueFM,
+	}
+	structFM.SetFieldName(1, "fields")
+
+	valueFM.SetName(ValueName)
+	valueFM.AddEnum(valueNullField, null)
+	valueFM.SetFieldName(valueNullField, "null_value")
+	valueFM.Add(valueNumberField, descriptor.FieldDescriptorProto_TYPE_DOUBLE)
+	valueFM.SetFieldName(valueNumberField, "number_value")
+	valueFM.Add(valueStringField, descriptor.FieldDescriptorProto_TYPE_STRING)
+	valueFM.SetFieldName(valueStringField, "string_value")
+	valueFM.Add(valueBoolField, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	valueFM.SetFieldName(valueBoolField, "bool_value")
+	valueFM.AddMessage(valueStructField, structFM)
+	valueFM.SetFieldName(valueStructField, "struct_value")
+	valueFM.AddMessage(valueListField, listFM)
+	valueFM.SetFieldName(valueListField, "list_value")
+	valueFM.AddOneof("kind", valueNullField, valueNumberField, valueStringField,
+		valueBoolField, valueStructField, valueListField)
+
+	listFM.SetName(ListValueName)
+	listFM.AddMessage(1, valueFM)
+	listFM.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	listFM.SetFieldName(1, "values")
+
+	// Freeze all three together, so they keep referring to each other
+	f := &freezer{
+		messages: make(map[*ProtoFieldMap]*ProtoFieldMap),
+		enums:    make(map[*ProtoEnum]*ProtoEnum),
+	}
+	return f.message(structFM), f.message(valueFM), f.message(listFM)
+}
+
+// WireToStruct converts a Struct message into a map[string]interface{}
+func WireToStruct(m *WireMessage) (map[string]interface{}, error) {
+	entries, err := m.DecodeRepeatedAs(1, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	if err != nil && err != ErrMessageFieldMissing {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(entries))
+	for _, e := range entries {
+		entry := e.(*WireMessage)
+		key, err := decodeEntryField(entry, mapEntryKeyField, descriptor.FieldDescriptorProto_TYPE_STRING)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if sub, err := entry.DecodeMessage(mapEntryValueField); err == nil {
+			if value, err = WireToValue(sub); err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+		} else if err != ErrMessageFieldMissing {
+			return nil, err
+		}
+		// Later entries with the same key win
+		result[key.(string)] = value
+	}
+	return result, nil
+}
+
+// WireToValue converts a Value message into a float64, string, bool, nil,
+// map[string]interface{} or []interface{}. A Value without a kind is nil.
+func WireToValue(m *WireMessage) (interface{}, error) {
+	// Like any oneof, the member seen last wins
+	kind, last, found := FieldNum(0), uint64(0), false
+	for field := valueNullField; field <= valueListField; field++ {
+		if seq, ok := m.LastOccurrence(field); ok && (!found || seq > last) {
+			kind, last, found = field, seq, true
+		}
+	}
+
+	switch kind {
+	case valueNumberField:
+		return m.DecodeAs(kind, descriptor.FieldDescriptorProto_TYPE_DOUBLE)
+	case valueStringField:
+		return m.DecodeAs(kind, descriptor.FieldDescriptorProto_TYPE_STRING)
+	case valueBoolField:
+		return m.DecodeAs(kind, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	case valueStructField, valueListField:
+		sub, err := m.DecodeMessage(kind)
+		if err != nil {
+			return nil, err
+		}
+		if kind == valueStructField {
+			return WireToStruct(sub)
+		}
+		return WireToList(sub)
+	}
+	return nil, nil
+}
+
+// WireToList converts a ListValue message into a []interface{}
+func WireToList(m *WireMessage) ([]interface{}, error) {
+	elems, err := m.DecodeRepeatedAs(1, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
+	if err != nil && err != ErrMessageFieldMissing {
+		return nil, err
+	}
+	result := make([]interface{}, len(elems))
+	for i, e := range elems {
+		if result[i], err = WireToValue(e.(*WireMessage)); err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// StructToWire converts fields into a Struct message. Keys are emitted in
+// sorted order, so that the output is reproducible.
+func StructToWire(fields map[string]interface{}) (*WireMessage, error) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := NewWireMessage()
+	for _, k := range keys {
+		value, err := ValueToWire(fields[k])
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k, err)
+		}
+		entry := NewWireMessage()
+		entry.EncodeString(mapEntryKeyField, k)
+		if err := entry.EncodeMessage(mapEntryValueField, value); err != nil {
+			return nil, err
+		}
+		if err := m.AppendAs(1, entry, descriptor.FieldDescriptorProto_TYPE_MESSAGE); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ValueToWire converts value into a Value message. Besides the types
+// WireToValue returns, any Go integer or float is accepted as a number,
+// as long as a float64 holds it exactly.
+func ValueToWire(value interface{}) (*WireMessage, error) {
+	m := NewWireMessage()
+	var err error
+	switch v := value.(type) {
+	case nil:
+		m.EncodeEnum(valueNullField, 0)
+	case string:
+		m.EncodeString(valueStringField, v)
+	case bool:
+		m.EncodeBool(valueBoolField, v)
+	case map[string]interface{}:
+		var sub *WireMessage
+		if sub, err = StructToWire(v); err == nil {
+			err = m.EncodeMessage(valueStructField, sub)
+		}
+	case []interface{}:
+		var sub *WireMessage
+		if sub, err = ListToWire(v); err == nil {
+			err = m.EncodeMessage(valueListField, sub)
+		}
+	default:
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			var f interface{}
+			if f, err = CoerceAs(value, descriptor.FieldDescriptorProto_TYPE_DOUBLE); err == nil {
+				m.EncodeDouble(valueNumberField, f.(float64))
+			}
+		default:
+			err = fmt.Errorf("%w: can not use %T as a %s", ErrInvalidProtoBufType, value, ValueName)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ListToWire converts values into a ListValue message
+func ListToWire(values []interface{}) (*WireMessage, error) {
+	m := NewWireMessage()
+	for i, v := range values {
+		value, err := ValueToWire(v)
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+		if err := m.AppendAs(1, value, descriptor.FieldDescriptorProto_TYPE_MESSAGE); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func decodeStructValue(m *WireMessage) (interface{}, error) {
+	return WireToStruct(m)
+}
+
+func encodeStructValue(value interface{}) (*WireMessage, bool, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	if fields == nil {
+		return nil, true, nil
+	}
+	m, err := StructToWire(fields)
+	return m, true, err
+}
+
+func decodeList(m *WireMessage) (interface{}, error) {
+	return WireToList(m)
+}
+
+func encodeList(value interface{}) (*WireMessage, bool, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+	if values == nil {
+		return nil, true, nil
+	}
+	m, err := ListToWire(values)
+	return m, true, err
+}
+
+func encodeValue(value interface{}) (*WireMessage, bool, error) {
+	switch value.(type) {
+	case *WireMessage, []FieldValue, *DynamicMessage:
+		// Already in terms of the Value schema
+		return nil, false, nil
+	}
+	m, err := ValueToWire(value)
+	return m, true, err
+}
@@ -0,0 +1,128 @@
+package dproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestStructValueRoundTrip(t *testing.T) {
+	in := map[string]interface{}{
+		"name":    "dproto",
+		"stars":   float64(42),
+		"public":  true,
+		"license": nil,
+		"tags":    []interface{}{"go", float64(1.5), nil, []interface{}{}},
+		"owner":   map[string]interface{}{"id": float64(-7)},
+	}
+	m, err := StructToWire(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = Unmarshal(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := WireToStruct(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Expected %v, got %v", in, out)
+	}
+}
+
+func TestValueLayout(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{nil, []byte{0x08, 0x00}},
+		{int32(1), []byte{0x11, 0, 0, 0, 0, 0, 0, 0xF0, 0x3F}},
+		{"a", []byte{0x1A, 0x01, 'a'}},
+		{false, []byte{0x20, 0x00}},
+		{map[string]interface{}{}, []byte{0x2A, 0x00}},
+		{[]interface{}{true}, []byte{0x32, 0x04, 0x0A, 0x02, 0x20, 0x01}},
+	}
+	for i, test := range tests {
+		m, err := ValueToWire(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(buf, test.expected) {
+			t.Errorf("Test %d: expected %# x, got %# x", i, test.expected, buf)
+		}
+	}
+}
+
+func TestValueInvalid(t *testing.T) {
+	if _, err := ValueToWire(struct{}{}); !errors.Is(err, ErrInvalidProtoBufType) {
+		t.Errorf("Expected ErrInvalidProtoBufType, got %v", err)
+	}
+	if _, err := StructToWire(map[string]interface{}{"n": int64(1<<53 + 1)}); !errors.Is(err, ErrPrecisionLoss) {
+		t.Errorf("Expected ErrPrecisionLoss, got %v", err)
+	}
+}
+
+func TestValueLastKindWins(t *testing.T) {
+	m := NewWireMessage()
+	m.EncodeString(valueStringField, "first")
+	m.EncodeBool(valueBoolField, true)
+	v, err := WireToValue(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != true {
+		t.Errorf("Expected true, got %v", v)
+	}
+	if v, err := WireToValue(NewWireMessage()); v != nil || err != nil {
+		t.Errorf("Expected nil for a Value without a kind, got %v, %v", v, err)
+	}
+}
+
+func TestStructValueFieldMap(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.AddWellKnown(1, StructName)
+	fm.AddWellKnown(2, ValueName)
+	fm.AddWellKnown(3, ListValueName)
+	fm.AddWellKnown(4, ValueName)
+	fm.SetLabel(4, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+
+	values := []FieldValue{
+		{1, map[string]interface{}{"k": "v"}},
+		{2, nil},
+		{3, []interface{}{float64(1), "two"}},
+		{4, []interface{}{"a", map[string]interface{}{"b": false}}},
+	}
+	buf, err := fm.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("Expected %v, got %v", values, decoded)
+	}
+
+	// The schemas describe the messages, too
+	valueFM, _ := WellKnownSchema(ValueName)
+	if fields, ok := valueFM.GetOneof("kind"); !ok || len(fields) != 6 {
+		t.Errorf("Unexpected kind oneof %v", fields)
+	}
+	structFM, _ := WellKnownSchema(StructName)
+	if structFM.maps[1].valueMsg != valueFM {
+		t.Error("Struct does not refer to the shared Value schema")
+	}
+}
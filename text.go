@@ -0,0 +1,769 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the Protobuf text format, as read by "protoc --encode" and
+// written by "protoc --decode", of messages described by a named
+// ProtoFieldMap. For example:
+//
+//	name: "dproto"
+//	tags: "go"
+//	tags: "protobuf"
+//	owner {
+//	  id: 7
+//	}
+
+package dproto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ErrInvalidText is returned when text does not match the message schema
+var ErrInvalidText = errors.New("Invalid Protobuf text")
+
+// TextOptions configures the text format
+type TextOptions struct {
+	// Resolver finds the schemas of messages packed in Any fields, which
+	// are then written in their expanded form, like
+	// "[type.googleapis.com/pkg.Msg] { ... }"
+	Resolver AnyResolver
+}
+
+// MarshalText converts the message m, described by fm, into the text format
+func MarshalText(fm *ProtoFieldMap, m *WireMessage) ([]byte, error) {
+	return TextOptions{}.Marshal(fm, m)
+}
+
+// UnmarshalText converts the text format into a message described by fm
+func UnmarshalText(fm *ProtoFieldMap, data []byte) (*WireMessage, error) {
+	return TextOptions{}.Unmarshal(fm, data)
+}
+
+// Marshal converts the message m, described by fm, into the text format.
+// Fields are written in field number order, and map entries in key order.
+// Fields of m that are not in fm are dropped.
+func (o TextOptions) Marshal(fm *ProtoFieldMap, m *WireMessage) ([]byte, error) {
+	w := &textWriter{opts: o}
+	if err := w.message(fm, m, 0); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// Unmarshal converts the text format into a message described by fm
+func (o TextOptions) Unmarshal(fm *ProtoFieldMap, data []byte) (*WireMessage, error) {
+	p := &textParser{opts: o, s: string(data), line: 1}
+	return p.message(fm, "")
+}
+
+// textFieldName returns the name of field as written in the text format.
+// Extensions are written in brackets.
+func textFieldName(fm *ProtoFieldMap, field FieldNum) string {
+	name := fm.descriptorFieldName(field)
+	if fm.InExtensionRange(field) {
+		return "[" + name + "]"
+	}
+	return name
+}
+
+// textLookupField finds the field with the name, including the generated
+// names of unnamed fields
+func textLookupField(fm *ProtoFieldMap, name string) (FieldNum, bool) {
+	if field, ok := fm.GetFieldByName(name); ok {
+		return field, true
+	}
+	for _, field := range fm.GetFieldNums() {
+		if name == fm.descriptorFieldName(field) {
+			return field, true
+		}
+	}
+	return 0, false
+}
+
+/////////////////////////////// Marshalling ///////////////////////////////////
+
+type textWriter struct {
+	opts TextOptions
+	buf  bytes.Buffer
+}
+
+func (w *textWriter) indent(depth int) {
+	w.buf.WriteString(strings.Repeat("  ", depth))
+}
+
+func (w *textWriter) message(fm *ProtoFieldMap, m *WireMessage, depth int) error {
+	if isAnySchema(fm) && w.opts.Resolver != nil {
+		if ok, err := w.expandedAny(m, depth); ok || err != nil {
+			return err
+		}
+	}
+
+	for _, field := range fm.GetFieldNums() {
+		if _, ok := m.LastOccurrence(field); !ok || !fm.oneofWinner(m, field) {
+			continue
+		}
+		if err := w.field(fm, m, field, depth); err != nil {
+			return fmt.Errorf("field %d: %w", field, err)
+		}
+	}
+	return nil
+}
+
+func (w *textWriter) field(fm *ProtoFieldMap, m *WireMessage, field FieldNum, depth int) error {
+	name := textFieldName(fm, field)
+	typ := fm.field2type[field]
+	sub, enum := fm.field2msg[field], fm.field2enum[field]
+
+	if entry, isMap := fm.maps[field]; isMap {
+		keys, entries, err := entry.rawEntries(m, field)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			w.indent(depth)
+			w.buf.WriteString(name + " {\n")
+			if err := w.value("key", k, entry.key, nil, nil, depth+1); err != nil {
+				return err
+			}
+			if err := w.value("value", entries[k], entry.value, entry.valueMsg, entry.valueEnum, depth+1); err != nil {
+				return err
+			}
+			w.indent(depth)
+			w.buf.WriteString("}\n")
+		}
+		return nil
+	}
+
+	if fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		vals, err := m.DecodeRepeatedAs(field, typ)
+		if err != nil {
+			return err
+		}
+		for _, v := range vals {
+			if err := w.value(name, v, typ, sub, enum, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	v, err := m.DecodeAs(field, typ)
+	if err != nil {
+		return err
+	}
+	return w.value(name, v, typ, sub, enum, depth)
+}
+
+// value writes a single "name: value" line, or a nested message block
+func (w *textWriter) value(name string, v interface{}, typ descriptor.FieldDescriptorProto_Type, sub *ProtoFieldMap, enum *ProtoEnum, depth int) error {
+	w.indent(depth)
+	if wm, ok := v.(*WireMessage); ok {
+		if sub == nil {
+			// Without a schema, the message can only be written as bytes
+			buf, err := wm.Marshal()
+			if err != nil {
+				return err
+			}
+			v = buf
+		} else {
+			w.buf.WriteString(name + " {\n")
+			if err := w.message(sub, wm, depth+1); err != nil {
+				return err
+			}
+			w.indent(depth)
+			w.buf.WriteString("}\n")
+			return nil
+		}
+	}
+
+	w.buf.WriteString(name + ": ")
+	switch x := v.(type) {
+	case float32:
+		w.buf.WriteString(formatTextFloat(float64(x), 32))
+	case float64:
+		w.buf.WriteString(formatTextFloat(x, 64))
+	case string:
+		w.buf.WriteString(quoteText([]byte(x)))
+	case []byte:
+		w.buf.WriteString(quoteText(x))
+	case uint64:
+		if typ != descriptor.FieldDescriptorProto_TYPE_ENUM {
+			w.buf.WriteString(strconv.FormatUint(x, 10))
+			break
+		}
+		number := int32(x)
+		if name, ok := enumName(enum, number); ok {
+			w.buf.WriteString(name)
+		} else {
+			w.buf.WriteString(strconv.FormatInt(int64(number), 10))
+		}
+	default:
+		fmt.Fprint(&w.buf, v)
+	}
+	w.buf.WriteByte('\n')
+	return nil
+}
+
+// enumName gets the name of number in enum, which may be nil
+func enumName(enum *ProtoEnum, number int32) (string, bool) {
+	if enum == nil {
+		return "", false
+	}
+	return enum.GetName(number)
+}
+
+// expandedAny writes the Any message m as "[type URL] { ... }". It returns
+// false if the type of the packed message can not be resolved.
+func (w *textWriter) expandedAny(m *WireMessage, depth int) (bool, error) {
+	typeURL, _ := m.DecodeString(anyTypeURLField)
+	if typeURL == "" {
+		return false, nil
+	}
+	d, err := UnpackAny(m, w.opts.Resolver)
+	if err != nil {
+		if errors.Is(err, ErrUnresolvedType) {
+			return false, nil
+		}
+		return true, err
+	}
+	w.indent(depth)
+	w.buf.WriteString("[" + typeURL + "] {\n")
+	if err := w.message(d.fm, d.wire, depth+1); err != nil {
+		return true, err
+	}
+	w.indent(depth)
+	w.buf.WriteString("}\n")
+	return true, nil
+}
+
+// formatTextFloat formats f like protoc, using the shortest of 6 or 9
+// digits for floats and 15 or 17 digits for doubles that reads back exactly
+func formatTextFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	short, long := 15, 17
+	if bits == 32 {
+		short, long = 6, 9
+	}
+	s := strconv.FormatFloat(f, 'g', short, bits)
+	if v, _ := strconv.ParseFloat(s, bits); v != f {
+		s = strconv.FormatFloat(f, 'g', long, bits)
+	}
+	// Like C's %g, drop trailing zeros of the exponent form
+	if mantissa, exp, ok := strings.Cut(s, "e"); ok && strings.Contains(mantissa, ".") {
+		mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+		s = mantissa + "e" + exp
+	}
+	return s
+}
+
+// quoteText quotes b like protoc
+func quoteText(b []byte) string {
+	return `"` + escapeText(b) + `"`
+}
+
+// escapeText escapes quotes, backslashes and control characters in b, and
+// writes all other non-ASCII bytes as octal escapes
+func escapeText(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7F {
+				fmt.Fprintf(&sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return sb.String()
+}
+
+////////////////////////////// Unmarshalling //////////////////////////////////
+
+// textParser reads the text format. Errors report the line they occurred on.
+type textParser struct {
+	opts TextOptions
+	s    string
+	pos  int
+	line int
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidText, p.line, fmt.Sprintf(format, args...))
+}
+
+// wrap adds the line number to an error from converting a value
+func (p *textParser) wrap(err error) error {
+	return fmt.Errorf("line %d: %w", p.line, err)
+}
+
+// skip skips whitespace and comments
+func (p *textParser) skip() {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next character, or 0 at the end of the input
+func (p *textParser) peek() byte {
+	p.skip()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// accept consumes the character c if it is next
+func (p *textParser) accept(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// isWordChar indicates if c may be part of an identifier or number
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// word reads an identifier or number. Signs are allowed within the
+// exponent of a decimal float.
+func (p *textParser) word() string {
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if isWordChar(c) {
+			p.pos++
+			continue
+		}
+		if (c == '+' || c == '-') && p.pos > start && strings.ContainsRune("eE", rune(p.s[p.pos-1])) &&
+			!strings.HasPrefix(strings.ToLower(p.s[start:p.pos]), "0x") {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+// bracketName reads the name inside brackets, like an extension name or
+// the type URL of an expanded Any
+func (p *textParser) bracketName() (string, error) {
+	end := strings.IndexByte(p.s[p.pos:], ']')
+	if end < 0 {
+		return "", p.errorf("missing ]")
+	}
+	name := strings.Join(strings.Fields(p.s[p.pos:p.pos+end]), "")
+	p.line += strings.Count(p.s[p.pos:p.pos+end], "\n")
+	p.pos += end + 1
+	return name, nil
+}
+
+// message reads fields until the closing character end, or the end of the
+// input if end is empty
+func (p *textParser) message(fm *ProtoFieldMap, end string) (*WireMessage, error) {
+	m := NewWireMessage()
+	seen := make(map[FieldNum]bool)
+	oneofs := make(map[string]FieldNum)
+	for {
+		c := p.peek()
+		if c == 0 {
+			if end != "" {
+				return nil, p.errorf("missing %s", end)
+			}
+			return m, nil
+		}
+		if end != "" && c == end[0] {
+			p.pos++
+			return m, nil
+		}
+
+		var name string
+		if p.accept('[') {
+			var err error
+			if name, err = p.bracketName(); err != nil {
+				return nil, err
+			}
+			if strings.Contains(name, "/") {
+				if err := p.expandedAny(m, fm, name); err != nil {
+					return nil, err
+				}
+				p.separator()
+				continue
+			}
+		} else if name = p.word(); name == "" {
+			return nil, p.errorf("unexpected %q", c)
+		}
+
+		field, ok := textLookupField(fm, name)
+		if !ok {
+			return nil, p.errorf("unknown field %s", name)
+		}
+		repeated := fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED
+		if _, isMap := fm.maps[field]; !repeated && !isMap {
+			if seen[field] {
+				return nil, p.errorf("field %s given twice", name)
+			}
+			seen[field] = true
+		}
+		if oneof, ok := fm.field2oneof[field]; ok {
+			if other, set := oneofs[oneof]; set && other != field {
+				return nil, p.wrap(fmt.Errorf("%w: fields %d and %d of %s", ErrOneofConflict, other, field, oneof))
+			}
+			oneofs[oneof] = field
+		}
+		if err := p.field(m, fm, field); err != nil {
+			return nil, err
+		}
+		p.separator()
+	}
+}
+
+// separator skips an optional field separator
+func (p *textParser) separator() {
+	if !p.accept(',') {
+		p.accept(';')
+	}
+}
+
+// field reads the value of field, after its name
+func (p *textParser) field(m *WireMessage, fm *ProtoFieldMap, field FieldNum) error {
+	typ := fm.field2type[field]
+	sub, enum := fm.field2msg[field], fm.field2enum[field]
+	entry, isMap := fm.maps[field]
+	if isMap {
+		sub = entry.schema()
+	}
+
+	// The colon is optional before messages
+	if !p.accept(':') && (sub == nil || typ != descriptor.FieldDescriptorProto_TYPE_MESSAGE) {
+		return p.errorf("missing : after %s", fm.descriptorFieldName(field))
+	}
+
+	// add reads a single value and adds it to m
+	add := func() error {
+		v, err := p.value(typ, sub, enum)
+		if err != nil {
+			return err
+		}
+		if isMap {
+			buf, err := v.(*WireMessage).Marshal()
+			if err != nil {
+				return err
+			}
+			m.AppendBytes(field, buf)
+			return nil
+		}
+		if fm.GetLabel(field) == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return m.AppendAs(field, v, typ)
+		}
+		return m.EncodeAs(field, v, typ)
+	}
+
+	if !p.accept('[') {
+		return add()
+	}
+	if fm.GetLabel(field) != descriptor.FieldDescriptorProto_LABEL_REPEATED && !isMap {
+		return p.errorf("list given for singular field %s", fm.descriptorFieldName(field))
+	}
+	for i := 0; !p.accept(']'); i++ {
+		if i > 0 && !p.accept(',') {
+			return p.errorf("missing , in list")
+		}
+		if err := add(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value reads a single value of the Protobuf type typ
+func (p *textParser) value(typ descriptor.FieldDescriptorProto_Type, sub *ProtoFieldMap, enum *ProtoEnum) (interface{}, error) {
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if sub == nil {
+			b, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			return Unmarshal(b)
+		}
+		switch {
+		case p.accept('{'):
+			return p.message(sub, "}")
+		case p.accept('<'):
+			return p.message(sub, ">")
+		}
+		return nil, p.errorf("missing { for message")
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		b, err := p.str()
+		return string(b), err
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return p.str()
+	}
+
+	neg := p.accept('-')
+	word := p.word()
+	if word == "" {
+		return nil, p.errorf("missing value")
+	}
+	sign := ""
+	if neg {
+		sign = "-"
+	}
+
+	switch typ {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		switch word {
+		case "true", "True", "t", "1":
+			if !neg {
+				return true, nil
+			}
+		case "false", "False", "f", "0":
+			if !neg {
+				return false, nil
+			}
+		}
+		return nil, p.errorf("invalid bool %s%s", sign, word)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum != nil && !neg {
+			if number, ok := enum.GetNumber(word); ok {
+				return uint64(int64(number)), nil
+			}
+		}
+		n, err := strconv.ParseInt(sign+word, 0, 32)
+		if err != nil || strings.Contains(word, "_") {
+			return nil, p.errorf("invalid enum value %s%s", sign, word)
+		}
+		return uint64(n), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		f, err := parseTextFloat(word)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", word)
+		}
+		if neg {
+			f = -f
+		}
+		v, err := CoerceAs(f, typ)
+		if err != nil {
+			return nil, p.wrap(err)
+		}
+		return v, nil
+	}
+
+	// Integers may be decimal, hexadecimal or octal, but unlike in Go,
+	// digits can not be separated by underscores
+	var n interface{}
+	if strings.Contains(word, "_") {
+		return nil, p.errorf("invalid integer %s%s", sign, word)
+	} else if i, err := strconv.ParseInt(sign+word, 0, 64); err == nil {
+		n = i
+	} else if u, err := strconv.ParseUint(sign+word, 0, 64); err == nil {
+		n = u
+	} else if errors.Is(err, strconv.ErrRange) {
+		return nil, p.wrap(fmt.Errorf("%w: %s%s does not fit in a %s", ErrOverflow, sign, word, typeString(typ)))
+	} else {
+		return nil, p.errorf("invalid integer %s%s", sign, word)
+	}
+	v, err := CoerceAs(n, typ)
+	if err != nil {
+		return nil, p.wrap(err)
+	}
+	return v, nil
+}
+
+// parseTextFloat parses a float, which may end in f and may be inf, infinity
+// or nan in any case
+func parseTextFloat(word string) (float64, error) {
+	switch strings.ToLower(word) {
+	case "inf", "infinity", "inff", "infinityf":
+		return math.Inf(1), nil
+	case "nan", "nanf":
+		return math.NaN(), nil
+	}
+	lower := strings.ToLower(word)
+	if strings.Contains(word, "_") {
+		return 0, fmt.Errorf("%w: %s is not a float", ErrInvalidText, word)
+	} else if !strings.HasPrefix(lower, "0x") {
+		word = strings.TrimSuffix(strings.TrimSuffix(word, "f"), "F")
+	} else if i, err := strconv.ParseInt(word, 0, 64); err == nil {
+		return float64(i), nil
+	}
+	return strconv.ParseFloat(word, 64)
+}
+
+// str reads one or more adjacent quoted strings and joins them
+func (p *textParser) str() ([]byte, error) {
+	var result []byte
+	for {
+		q := p.peek()
+		if q != '"' && q != '\'' {
+			break
+		}
+		p.pos++
+		start := p.line
+		for {
+			if p.pos >= len(p.s) || p.s[p.pos] == '\n' {
+				p.line = start
+				return nil, p.errorf("unterminated string")
+			}
+			c := p.s[p.pos]
+			p.pos++
+			if c == q {
+				break
+			}
+			if c != '\\' {
+				result = append(result, c)
+				continue
+			}
+			var err error
+			if result, err = p.escape(result); err != nil {
+				return nil, err
+			}
+		}
+		if result == nil {
+			result = []byte{}
+		}
+	}
+	if result == nil {
+		return nil, p.errorf("missing string")
+	}
+	return result, nil
+}
+
+// escape reads the escape sequence after a backslash and appends the
+// character it stands for to b
+func (p *textParser) escape(b []byte) ([]byte, error) {
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unterminated string")
+	}
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case 'a':
+		return append(b, '\a'), nil
+	case 'b':
+		return append(b, '\b'), nil
+	case 'f':
+		return append(b, '\f'), nil
+	case 'n':
+		return append(b, '\n'), nil
+	case 'r':
+		return append(b, '\r'), nil
+	case 't':
+		return append(b, '\t'), nil
+	case 'v':
+		return append(b, '\v'), nil
+	case '\\', '\'', '"', '?':
+		return append(b, c), nil
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		// Up to three octal digits
+		n := int(c - '0')
+		for i := 0; i < 2 && p.pos < len(p.s) && '0' <= p.s[p.pos] && p.s[p.pos] <= '7'; i++ {
+			n = n*8 + int(p.s[p.pos]-'0')
+			p.pos++
+		}
+		if n > 0xFF {
+			return nil, p.errorf("octal escape out of range")
+		}
+		return append(b, byte(n)), nil
+	case 'x', 'X':
+		// Up to two hex digits
+		digits := 0
+		for digits < 2 && p.pos+digits < len(p.s) && isHexDigit(p.s[p.pos+digits]) {
+			digits++
+		}
+		if digits == 0 {
+			return nil, p.errorf("invalid hex escape")
+		}
+		n, _ := strconv.ParseUint(p.s[p.pos:p.pos+digits], 16, 8)
+		p.pos += digits
+		return append(b, byte(n)), nil
+	case 'u', 'U':
+		digits := 4
+		if c == 'U' {
+			digits = 8
+		}
+		if p.pos+digits > len(p.s) {
+			return nil, p.errorf("invalid unicode escape")
+		}
+		n, err := strconv.ParseUint(p.s[p.pos:p.pos+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return nil, p.errorf("invalid unicode escape")
+		}
+		p.pos += digits
+		return utf8.AppendRune(b, rune(n)), nil
+	}
+	return nil, p.errorf("invalid escape \\%c", c)
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// expandedAny reads the message after "[type URL]" into the Any message m
+func (p *textParser) expandedAny(m *WireMessage, fm *ProtoFieldMap, typeURL string) error {
+	if !isAnySchema(fm) {
+		return p.errorf("expanded Any given in %s", fm.name)
+	}
+	if p.opts.Resolver == nil {
+		return p.wrap(fmt.Errorf("%w: no resolver for %s", ErrUnresolvedType, typeURL))
+	}
+	sub, ok := p.opts.Resolver.ResolveAny(typeURL)
+	if !ok {
+		return p.wrap(fmt.Errorf("%w: %s", ErrUnresolvedType, typeURL))
+	}
+	p.accept(':')
+	v, err := p.value(descriptor.FieldDescriptorProto_TYPE_MESSAGE, sub, nil)
+	if err != nil {
+		return err
+	}
+	buf, err := v.(*WireMessage).Marshal()
+	if err != nil {
+		return err
+	}
+	m.EncodeString(anyTypeURLField, typeURL)
+	m.EncodeBytes(anyValueField, buf)
+	return nil
+}
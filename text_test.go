@@ -0,0 +1,350 @@
+package dproto
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// newTextTestMap creates a schema with every kind of field the text format
+// handles
+func newTextTestMap() *ProtoFieldMap {
+	color := NewProtoEnum("test.Color")
+	color.Add("RED", 0)
+	color.Add("GREEN", 1)
+
+	inner := NewProtoFieldMap()
+	inner.SetName("test.Inner")
+	inner.Add(1, descriptor.FieldDescriptorProto_TYPE_INT32)
+	inner.SetFieldName(1, "id")
+
+	fm := NewProtoFieldMap()
+	fm.SetName("test.Outer")
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+	fm.SetFieldName(1, "name")
+	fm.Add(2, descriptor.FieldDescriptorProto_TYPE_SINT64)
+	fm.SetFieldName(2, "offset")
+	fm.Add(3, descriptor.FieldDescriptorProto_TYPE_DOUBLE)
+	fm.SetFieldName(3, "ratio")
+	fm.Add(4, descriptor.FieldDescriptorProto_TYPE_BYTES)
+	fm.SetFieldName(4, "data")
+	fm.AddEnum(5, color)
+	fm.SetFieldName(5, "color")
+	fm.Add(6, descriptor.FieldDescriptorProto_TYPE_UINT32)
+	fm.SetLabel(6, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	fm.SetFieldName(6, "ids")
+	fm.AddMessage(7, inner)
+	fm.SetFieldName(7, "inner")
+	fm.AddMap(8, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_INT32)
+	fm.SetFieldName(8, "counts")
+	fm.Add(9, descriptor.FieldDescriptorProto_TYPE_BOOL)
+	fm.SetFieldName(9, "enabled")
+	fm.Add(10, descriptor.FieldDescriptorProto_TYPE_FLOAT)
+	fm.SetFieldName(10, "score")
+	fm.AddOneof("choice", 1, 9)
+	return fm
+}
+
+func TestTextMarshal(t *testing.T) {
+	fm := newTextTestMap()
+	m, err := fm.EncodeMessage([]FieldValue{
+		{1, "a\"b\n\x01é"},
+		{2, int64(-5)},
+		{3, 0.1},
+		{4, []byte{0, 'z', 0xFF}},
+		{5, "GREEN"},
+		{6, []uint32{3, 1}},
+		{7, []FieldValue{{1, int32(7)}}},
+		{8, map[string]int32{"b": 2, "a": 1}},
+		{10, float32(1e20)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `name: "a\"b\n\001\303\251"
+offset: -5
+ratio: 0.1
+data: "\000z\377"
+color: GREEN
+ids: 3
+ids: 1
+inner {
+  id: 7
+}
+counts {
+  key: "a"
+  value: 1
+}
+counts {
+  key: "b"
+  value: 2
+}
+score: 1e+20
+`
+	buf, err := MarshalText(fm, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf)
+	}
+
+	back, err := UnmarshalText(fm, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mustMarshal(t, back), mustMarshal(t, m)) {
+		t.Errorf("Round trip changed the message:\n%s", mustMarshalText(t, fm, back))
+	}
+}
+
+func TestTextUnmarshalForms(t *testing.T) {
+	fm := newTextTestMap()
+	input := `# A comment
+enabled: t
+offset: -0x10 ratio: -1.5e-3;
+data: 'x\x41\101' "é\n"
+color: 1
+ids: [1, 0x2, 010]
+inner: < id: -1 >
+counts { key: "k" value: 3 }
+counts [{ key: "j" }]
+score: -inff
+`
+	m, err := UnmarshalText(fm, []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := fm.DecodeMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[FieldNum]interface{})
+	for _, fv := range values {
+		got[fv.Field] = fv.Value
+	}
+	if got[9] != true || got[2] != int64(-16) || got[3] != -1.5e-3 || got[5] != uint64(1) {
+		t.Errorf("Unexpected scalar values %v", got)
+	}
+	if data, _ := got[4].([]byte); string(data) != "xAAé\n" {
+		t.Errorf("Unexpected bytes %q", data)
+	}
+	if ids, _ := got[6].([]interface{}); len(ids) != 3 || ids[0] != uint32(1) || ids[1] != uint32(2) || ids[2] != uint32(8) {
+		t.Errorf("Unexpected ids %v", got[6])
+	}
+	if inner, _ := got[7].([]FieldValue); len(inner) != 1 || inner[0].Value != int32(-1) {
+		t.Errorf("Unexpected inner message %v", got[7])
+	}
+	if counts, _ := got[8].(map[interface{}]interface{}); len(counts) != 2 || counts["k"] != int32(3) || counts["j"] != int32(0) {
+		t.Errorf("Unexpected map %v", got[8])
+	}
+	if score, _ := got[10].(float32); !math.IsInf(float64(score), -1) {
+		t.Errorf("Unexpected score %v", got[10])
+	}
+}
+
+func TestTextFloats(t *testing.T) {
+	cases := []struct {
+		f        float64
+		bits     int
+		expected string
+	}{
+		{0.1, 64, "0.1"},
+		{1.0 / 3, 64, "0.33333333333333331"},
+		{float64(float32(0.1)), 32, "0.1"},
+		{1e100, 64, "1e+100"},
+		{-2.5e-10, 64, "-2.5e-10"},
+		{math.NaN(), 64, "nan"},
+		{math.Inf(1), 32, "inf"},
+	}
+	for _, c := range cases {
+		if s := formatTextFloat(c.f, c.bits); s != c.expected {
+			t.Errorf("Expected %v as %s, got %s", c.f, c.expected, s)
+		}
+	}
+}
+
+func TestTextUnmarshalErrors(t *testing.T) {
+	fm := newTextTestMap()
+	cases := []struct {
+		input string
+		err   error
+	}{
+		{"unknown: 1", ErrInvalidText},
+		{"offset 1", ErrInvalidText},
+		{"offset: 1\noffset: 2", ErrInvalidText},
+		{"offset: [1]", ErrInvalidText},
+		{"inner { id: 1", ErrInvalidText},
+		{"name: \"abc", ErrInvalidText},
+		{"name: 'a\\q'", ErrInvalidText},
+		{"enabled: yes", ErrInvalidText},
+		{"color: BLUE", ErrInvalidText},
+		{"color: 1_0", ErrInvalidText},
+		{"offset: 1_000", ErrInvalidText},
+		{"ratio: 0x1_0", ErrInvalidText},
+		{"ids: -1", ErrOverflow},
+		{"inner { id: 2147483648 }", ErrOverflow},
+		{"name: \"a\" enabled: true", ErrOneofConflict},
+		{"[type.googleapis.com/test.Inner] { id: 1 }", ErrInvalidText},
+	}
+	for _, c := range cases {
+		if _, err := UnmarshalText(fm, []byte(c.input)); !errors.Is(err, c.err) {
+			t.Errorf("Input %q: expected %v, got %v", c.input, c.err, err)
+		}
+	}
+
+	_, err := UnmarshalText(fm, []byte("offset: 1\n\nbad: 2"))
+	if err == nil || err.Error() != "Invalid Protobuf text: line 3: unknown field bad" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestTextExtensionsAndAny(t *testing.T) {
+	r := newAnyTestRegistry(t)
+	envelope, _ := r.GetMessage("test.Envelope")
+	envelope = envelope.clone()
+	envelope.AddExtensionRange(100, 199)
+	envelope.Add(100, descriptor.FieldDescriptorProto_TYPE_INT32)
+	envelope.SetFieldName(100, "test.priority")
+
+	input := `1: "ignored"`
+	if _, err := UnmarshalText(envelope, []byte(input)); !errors.Is(err, ErrInvalidText) {
+		t.Errorf("Expected ErrInvalidText for a field number, got %v", err)
+	}
+
+	input = `field_2 {
+  [type.googleapis.com/test.Point] {
+    field_1: -3
+  }
+}
+[test.priority]: 4
+`
+	if _, err := UnmarshalText(envelope, []byte(input)); !errors.Is(err, ErrUnresolvedType) {
+		t.Errorf("Expected ErrUnresolvedType without a resolver, got %v", err)
+	}
+
+	opts := TextOptions{Resolver: r}
+	m, err := opts.Unmarshal(envelope, []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.DecodeAs(100, descriptor.FieldDescriptorProto_TYPE_INT32); err != nil || v != int32(4) {
+		t.Errorf("Unexpected extension value %v (%v)", v, err)
+	}
+	any, err := m.DecodeMessage(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := UnpackAny(any, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.wire.DecodeAs(1, descriptor.FieldDescriptorProto_TYPE_SINT32); err != nil || v != int32(-3) {
+		t.Errorf("Unexpected packed value %v (%v)", v, err)
+	}
+
+	buf, err := opts.Marshal(envelope, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != input {
+		t.Errorf("Expected:\n%s\ngot:\n%s", input, buf)
+	}
+
+	// Without a resolver, the Any is written field by field
+	buf, err = MarshalText(envelope, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "field_2 {\n  type_url: \"type.googleapis.com/test.Point\"\n  value: \"\\010\\005\"\n}\n[test.priority]: 4\n"
+	if string(buf) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf)
+	}
+}
+
+// textTestMessage is written and read by golang/protobuf, to cross-check the
+// text dproto reads and writes
+type textTestMessage struct {
+	Name   *string          `protobuf:"bytes,1,opt,name=name"`
+	Offset *int64           `protobuf:"zigzag64,2,opt,name=offset"`
+	Ratio  *float64         `protobuf:"fixed64,3,opt,name=ratio"`
+	Data   []byte           `protobuf:"bytes,4,opt,name=data"`
+	Ids    []uint32         `protobuf:"varint,6,rep,name=ids"`
+	Inner  *textTestInner   `protobuf:"bytes,7,opt,name=inner"`
+	Counts map[string]int32 `protobuf:"bytes,8,rep,name=counts" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Score  *float32         `protobuf:"fixed32,10,opt,name=score"`
+}
+
+func (m *textTestMessage) Reset()         { *m = textTestMessage{} }
+func (m *textTestMessage) String() string { return proto.CompactTextString(m) }
+func (*textTestMessage) ProtoMessage()    {}
+
+type textTestInner struct {
+	Id *int32 `protobuf:"varint,1,opt,name=id"`
+}
+
+func (m *textTestInner) Reset()         { *m = textTestInner{} }
+func (m *textTestInner) String() string { return proto.CompactTextString(m) }
+func (*textTestInner) ProtoMessage()    {}
+
+func TestTextMatchesGolangProtobuf(t *testing.T) {
+	fm := newTextTestMap()
+	name, offset, ratio, score, id := "tab\there\\ 'q' \x7F", int64(-42), 1.0/3, float32(0.3), int32(-7)
+	msg := &textTestMessage{
+		Name:   &name,
+		Offset: &offset,
+		Ratio:  &ratio,
+		Data:   []byte{0, 1, 0x80, '"'},
+		Ids:    []uint32{5, 6},
+		Inner:  &textTestInner{Id: &id},
+		Counts: map[string]int32{"x": 1, "y": -1},
+		Score:  &score,
+	}
+
+	// Read what golang/protobuf writes. Its map entries are in random order,
+	// so the bytes are compared by decoding them.
+	m, err := UnmarshalText(fm, []byte(proto.MarshalTextString(msg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshalled textTestMessage
+	if err := proto.Unmarshal(mustMarshal(t, m), &unmarshalled); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(&unmarshalled, msg) {
+		t.Errorf("Expected %v, got %v", msg, &unmarshalled)
+	}
+
+	// Write what golang/protobuf reads
+	var decoded textTestMessage
+	if err := proto.UnmarshalText(string(mustMarshalText(t, fm, m)), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(&decoded, msg) {
+		t.Errorf("Expected %v, got %v", msg, &decoded)
+	}
+}
+
+func mustMarshal(t *testing.T, m *WireMessage) []byte {
+	t.Helper()
+	buf, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func mustMarshalText(t *testing.T, fm *ProtoFieldMap, m *WireMessage) []byte {
+	t.Helper()
+	buf, err := MarshalText(fm, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
@@ -0,0 +1,258 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds typed field handles, which pair a field number with its
+// Protobuf type at compile time. Declaring the schema once, like
+//
+//	var Intensity = dproto.Int64Field(2)
+//	var Tags = dproto.Repeated(dproto.StringField(3))
+//
+// turns a mismatched Go type into a compile error, instead of the runtime
+// ErrInvalidProtoBufType returned by DecodeAs and EncodeAs.
+
+package dproto
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Field is a handle for a singular field holding Go values of type T
+type Field[T any] struct {
+	num    FieldNum
+	pbtype descriptor.FieldDescriptorProto_Type
+	decode func(m *WireMessage, field FieldNum) (T, bool)
+	encode func(m *WireMessage, field FieldNum, value T) error
+}
+
+// Num returns the field number
+func (f Field[T]) Num() FieldNum {
+	return f.num
+}
+
+// Type returns the Protobuf type of the field
+func (f Field[T]) Type() descriptor.FieldDescriptorProto_Type {
+	return f.pbtype
+}
+
+// Get decodes the field from m. It returns false if the field is missing.
+func (f Field[T]) Get(m *WireMessage) (T, bool) {
+	return f.decode(m, f.num)
+}
+
+// Set encodes value into m, replacing any previous value. Only strings
+// can fail, if they are rejected by the UTF-8 policy of m.
+func (f Field[T]) Set(m *WireMessage, value T) error {
+	return f.encode(m, f.num, value)
+}
+
+// Has indicates if the field is present in m
+func (f Field[T]) Has(m *WireMessage) bool {
+	_, ok := m.LastOccurrence(f.num)
+	return ok
+}
+
+// Clear removes the field from m
+func (f Field[T]) Clear(m *WireMessage) {
+	m.Remove(f.num)
+}
+
+// newField creates a Field from the matching WireMessage decode and encode
+// methods
+func newField[T any](num FieldNum, pbtype descriptor.FieldDescriptorProto_Type,
+	decode func(*WireMessage, FieldNum) (T, bool),
+	encode func(*WireMessage, FieldNum, T)) Field[T] {
+	return Field[T]{num: num, pbtype: pbtype, decode: decode,
+		encode: func(m *WireMessage, field FieldNum, value T) error {
+			encode(m, field, value)
+			return nil
+		}}
+}
+
+// Int32Field creates a handle for an int32 field
+func Int32Field(num FieldNum) Field[int32] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_INT32, (*WireMessage).DecodeInt32, (*WireMessage).EncodeInt32)
+}
+
+// Int64Field creates a handle for an int64 field
+func Int64Field(num FieldNum) Field[int64] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_INT64, (*WireMessage).DecodeInt64, (*WireMessage).EncodeInt64)
+}
+
+// Uint32Field creates a handle for a uint32 field
+func Uint32Field(num FieldNum) Field[uint32] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_UINT32, (*WireMessage).DecodeUint32, (*WireMessage).EncodeUint32)
+}
+
+// Uint64Field creates a handle for a uint64 field
+func Uint64Field(num FieldNum) Field[uint64] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_UINT64, (*WireMessage).DecodeUint64, (*WireMessage).EncodeUint64)
+}
+
+// Sint32Field creates a handle for a sint32 field
+func Sint32Field(num FieldNum) Field[int32] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_SINT32, (*WireMessage).DecodeSint32, (*WireMessage).EncodeSint32)
+}
+
+// Sint64Field creates a handle for a sint64 field
+func Sint64Field(num FieldNum) Field[int64] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_SINT64, (*WireMessage).DecodeSint64, (*WireMessage).EncodeSint64)
+}
+
+// BoolField creates a handle for a bool field
+func BoolField(num FieldNum) Field[bool] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_BOOL, (*WireMessage).DecodeBool, (*WireMessage).EncodeBool)
+}
+
+// EnumField creates a handle for an enum field
+func EnumField(num FieldNum) Field[uint64] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_ENUM, (*WireMessage).DecodeEnum, (*WireMessage).EncodeEnum)
+}
+
+// Fixed32Field creates a handle for a fixed32 field
+func Fixed32Field(num FieldNum) Field[uint32] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_FIXED32, (*WireMessage).DecodeFixed32, (*WireMessage).EncodeFixed32)
+}
+
+// Sfixed32Field creates a handle for a sfixed32 field
+func Sfixed32Field(num FieldNum) Field[int32] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_SFIXED32, (*WireMessage).DecodeSfixed32, (*WireMessage).EncodeSfixed32)
+}
+
+// FloatField creates a handle for a float field
+func FloatField(num FieldNum) Field[float32] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_FLOAT, (*WireMessage).DecodeFloat, (*WireMessage).EncodeFloat)
+}
+
+// Fixed64Field creates a handle for a fixed64 field
+func Fixed64Field(num FieldNum) Field[uint64] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_FIXED64, (*WireMessage).DecodeFixed64, (*WireMessage).EncodeFixed64)
+}
+
+// Sfixed64Field creates a handle for a sfixed64 field
+func Sfixed64Field(num FieldNum) Field[int64] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_SFIXED64, (*WireMessage).DecodeSfixed64, (*WireMessage).EncodeSfixed64)
+}
+
+// DoubleField creates a handle for a double field
+func DoubleField(num FieldNum) Field[float64] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_DOUBLE, (*WireMessage).DecodeDouble, (*WireMessage).EncodeDouble)
+}
+
+// StringField creates a handle for a string field, which applies the UTF-8
+// policy of the message. Get returns false if the string is rejected.
+func StringField(num FieldNum) Field[string] {
+	str := descriptor.FieldDescriptorProto_TYPE_STRING
+	return Field[string]{num: num, pbtype: str,
+		decode: func(m *WireMessage, field FieldNum) (string, bool) {
+			v, err := m.DecodeAs(field, str)
+			if err != nil {
+				return "", false
+			}
+			return v.(string), true
+		},
+		encode: func(m *WireMessage, field FieldNum, value string) error {
+			return m.EncodeAs(field, value, str)
+		}}
+}
+
+// BytesField creates a handle for a bytes field
+func BytesField(num FieldNum) Field[[]byte] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_BYTES, (*WireMessage).DecodeBytes, (*WireMessage).EncodeBytes)
+}
+
+// MessageField creates a handle for an embedded message field.
+// Get returns false if the field is missing or can not be unmarshalled.
+func MessageField(num FieldNum) Field[*WireMessage] {
+	return newField(num, descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		func(m *WireMessage, field FieldNum) (*WireMessage, bool) {
+			sub, err := m.DecodeMessage(field)
+			return sub, err == nil
+		},
+		func(m *WireMessage, field FieldNum, value *WireMessage) {
+			// Marshalling a WireMessage can not fail
+			m.EncodeMessage(field, value)
+		})
+}
+
+// RepeatedField is a handle for a repeated field holding Go values of
+// type T
+type RepeatedField[T any] struct {
+	num    FieldNum
+	pbtype descriptor.FieldDescriptorProto_Type
+}
+
+// Repeated creates a handle for a repeated field with the number and type
+// of the singular handle f, like Repeated(StringField(3))
+func Repeated[T any](f Field[T]) RepeatedField[T] {
+	return RepeatedField[T]{num: f.num, pbtype: f.pbtype}
+}
+
+// Num returns the field number
+func (f RepeatedField[T]) Num() FieldNum {
+	return f.num
+}
+
+// Type returns the Protobuf type of the field elements
+func (f RepeatedField[T]) Type() descriptor.FieldDescriptorProto_Type {
+	return f.pbtype
+}
+
+// Get decodes every occurrence of the field in m. It returns nil if the
+// field is missing, and an error if an element can not be decoded.
+func (f RepeatedField[T]) Get(m *WireMessage) ([]T, error) {
+	vals, err := m.DecodeRepeatedAs(f.num, f.pbtype)
+	if err == ErrMessageFieldMissing {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	result := make([]T, len(vals))
+	for i, v := range vals {
+		result[i] = v.(T)
+	}
+	return result, nil
+}
+
+// Set replaces all occurrences of the field in m with values
+func (f RepeatedField[T]) Set(m *WireMessage, values []T) error {
+	m.Remove(f.num)
+	return f.Append(m, values...)
+}
+
+// Append adds values to the end of the field in m. The type of the values
+// always matches, but they may be rejected, like by the UTF-8 policy of m.
+func (f RepeatedField[T]) Append(m *WireMessage, values ...T) error {
+	for _, v := range values {
+		if err := m.AppendAs(f.num, v, f.pbtype); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of elements of the field in m, counting each
+// value of a packed occurrence, like Get does
+func (f RepeatedField[T]) Len(m *WireMessage) int {
+	var n int
+	wire := protoType2WireType[f.pbtype]
+	switch wire {
+	case proto.WireVarint:
+		n = len(m.varint[f.num])
+	case proto.WireFixed32:
+		n = len(m.fixed32[f.num])
+	case proto.WireFixed64:
+		n = len(m.fixed64[f.num])
+	default:
+		return len(m.bytes[f.num])
+	}
+	for _, b := range m.bytes[f.num] {
+		n += packedLen(b, wire)
+	}
+	return n
+}
+
+// Clear removes the field from m
+func (f RepeatedField[T]) Clear(m *WireMessage) {
+	m.Remove(f.num)
+}
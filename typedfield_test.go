@@ -0,0 +1,187 @@
+package dproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+var (
+	testIntensity = Int64Field(2)
+	testOffset    = Sint32Field(3)
+	testName      = StringField(4)
+	testRatio     = DoubleField(5)
+	testTags      = Repeated(StringField(6))
+	testSamples   = Repeated(Sfixed32Field(7))
+	testInner     = MessageField(8)
+	testChildren  = Repeated(MessageField(9))
+)
+
+func TestTypedFieldGetSet(t *testing.T) {
+	m := NewWireMessage()
+	if _, ok := testIntensity.Get(m); ok || testIntensity.Has(m) {
+		t.Error("Missing field was reported as present")
+	}
+
+	testIntensity.Set(m, 1<<40)
+	testOffset.Set(m, -7)
+	testName.Set(m, "lamp")
+	testRatio.Set(m, 0.25)
+
+	buf, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = Unmarshal(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := testIntensity.Get(m); !ok || v != 1<<40 {
+		t.Errorf("Expected intensity %d, got %d (%v)", int64(1<<40), v, ok)
+	}
+	if v, ok := testOffset.Get(m); !ok || v != -7 {
+		t.Errorf("Expected offset -7, got %d (%v)", v, ok)
+	}
+	if v, _ := testName.Get(m); v != "lamp" {
+		t.Errorf("Expected name lamp, got %q", v)
+	}
+	if v, _ := testRatio.Get(m); v != 0.25 {
+		t.Errorf("Expected ratio 0.25, got %v", v)
+	}
+
+	// The handles agree with the runtime typed decoder
+	if v, err := m.DecodeAs(testOffset.Num(), testOffset.Type()); err != nil || v != int32(-7) {
+		t.Errorf("DecodeAs disagrees: %v (%v)", v, err)
+	}
+
+	testName.Clear(m)
+	if testName.Has(m) {
+		t.Error("Cleared field is still present")
+	}
+}
+
+func TestTypedFieldRepeated(t *testing.T) {
+	m := NewWireMessage()
+	if err := testTags.Set(m, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testTags.Append(m, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testSamples.Set(m, []int32{-1, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := testTags.Get(m); err != nil || !reflect.DeepEqual(v, []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected tags %v (%v)", v, err)
+	}
+	if n := testTags.Len(m); n != 3 {
+		t.Errorf("Expected 3 tags, got %d", n)
+	}
+	if v, err := testSamples.Get(m); err != nil || !reflect.DeepEqual(v, []int32{-1, 0, 1}) {
+		t.Errorf("Unexpected samples %v (%v)", v, err)
+	}
+
+	testTags.Set(m, nil)
+	if v, err := testTags.Get(m); err != nil || v != nil {
+		t.Errorf("Expected no tags, got %v (%v)", v, err)
+	}
+}
+
+func TestTypedFieldRepeatedErrors(t *testing.T) {
+	m := NewWireMessage()
+	m.SetUTF8Policy(UTF8Reject, nil)
+	if err := testTags.Append(m, "ok", "bad\xff"); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 from Append, got %v", err)
+	}
+	if err := testTags.Set(m, []string{"bad\xff"}); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 from Set, got %v", err)
+	}
+
+	m = NewWireMessage()
+	m.AppendBytes(6, []byte("bad\xff"))
+	m.SetUTF8Policy(UTF8Reject, nil)
+	if v, err := testTags.Get(m); !errors.Is(err, ErrInvalidUTF8) || v != nil {
+		t.Errorf("Expected ErrInvalidUTF8 from Get, got %v (%v)", v, err)
+	}
+
+	m = NewWireMessage()
+	m.AppendBytes(7, []byte{1, 2})
+	if _, err := testSamples.Get(m); !errors.Is(err, ErrMalformedProtoBuf) {
+		t.Errorf("Expected ErrMalformedProtoBuf for a truncated packed run, got %v", err)
+	}
+}
+
+func TestTypedFieldMessage(t *testing.T) {
+	inner := NewWireMessage()
+	testName.Set(inner, "child")
+
+	m := NewWireMessage()
+	testInner.Set(m, inner)
+	testChildren.Append(m, inner, inner)
+
+	got, ok := testInner.Get(m)
+	if !ok {
+		t.Fatal("Message field is missing")
+	}
+	if v, _ := testName.Get(got); v != "child" {
+		t.Errorf("Expected nested name child, got %q", v)
+	}
+	if children, err := testChildren.Get(m); err != nil || len(children) != 2 {
+		t.Errorf("Expected 2 children, got %d (%v)", len(children), err)
+	}
+	if testChildren.Type() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		t.Errorf("Unexpected repeated type %v", testChildren.Type())
+	}
+}
+
+func TestTypedFieldStringUTF8(t *testing.T) {
+	m := NewWireMessage()
+	m.SetUTF8Policy(UTF8Reject, nil)
+	if err := testName.Set(m, "a\xffb"); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 from Set, got %v", err)
+	}
+	if testName.Has(m) {
+		t.Error("Rejected string was written")
+	}
+
+	m.EncodeString(testName.Num(), "a\xffb")
+	if v, ok := testName.Get(m); ok {
+		t.Errorf("Rejected string was returned: %q", v)
+	}
+
+	m.SetUTF8Policy(UTF8Repair, nil)
+	if v, ok := testName.Get(m); !ok || v != "a�b" {
+		t.Errorf("Expected a repaired string, got %q (%v)", v, ok)
+	}
+}
+
+func TestTypedFieldRepeatedPacked(t *testing.T) {
+	counts := Repeated(Int64Field(2))
+	samples := Repeated(Sfixed32Field(7))
+	m, err := Unmarshal([]byte{
+		0x12, 0x03, 0x01, 0x02, 0x03, // 2: packed [1, 2, 3]
+		0x10, 0x96, 0x01, // 2: 150
+		0x12, 0x02, 0xff, 0x01, // 2: packed [255]
+		0x3a, 0x04, 0xff, 0xff, 0xff, 0xff, // 7: packed [-1]
+		0x3d, 0x02, 0x00, 0x00, 0x00, // 7: 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := counts.Get(m); err != nil || !reflect.DeepEqual(v, []int64{1, 2, 3, 150, 255}) {
+		t.Errorf("Unexpected counts %v (%v)", v, err)
+	}
+	if n := counts.Len(m); n != 5 {
+		t.Errorf("Expected 5 counts, got %d", n)
+	}
+	if v, err := samples.Get(m); err != nil || !reflect.DeepEqual(v, []int32{-1, 2}) {
+		t.Errorf("Unexpected samples %v (%v)", v, err)
+	}
+	if n := samples.Len(m); n != 2 {
+		t.Errorf("Expected 2 samples, got %d", n)
+	}
+}
@@ -0,0 +1,100 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the UTF-8 validation of string fields. Proto3 requires
+// strings to be valid UTF-8, but peers don't always get that right.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF8 is the error wrapped by InvalidUTF8Error
+var ErrInvalidUTF8 = errors.New("Invalid UTF-8")
+
+// InvalidUTF8Error reports a string field that is not valid UTF-8
+type InvalidUTF8Error struct {
+	Field FieldNum
+	// Offset is the index of the first invalid byte in the string
+	Offset int
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("%v: field %d at byte %d", ErrInvalidUTF8, e.Field, e.Offset)
+}
+
+// Unwrap returns ErrInvalidUTF8
+func (e *InvalidUTF8Error) Unwrap() error {
+	return ErrInvalidUTF8
+}
+
+// UTF8Policy selects how string fields with invalid UTF-8 are handled
+type UTF8Policy int
+
+const (
+	// UTF8Off does not validate strings
+	UTF8Off UTF8Policy = iota
+	// UTF8Warn passes invalid strings through unchanged, but reports them
+	// to the warn function given to SetUTF8Policy
+	UTF8Warn
+	// UTF8Reject fails with an InvalidUTF8Error
+	UTF8Reject
+	// UTF8Repair replaces each invalid sequence with the Unicode
+	// replacement character U+FFFD
+	UTF8Repair
+)
+
+// SetUTF8Policy selects how DecodeAs, DecodeRepeatedAs, EncodeAs, AppendAs
+// and the StringField handles treat string fields that are not valid UTF-8.
+// DecodeString and EncodeString do not validate. The policy is UTF8Off by
+// default. Embedded messages decoded from m inherit it.
+//
+// In UTF8Warn mode, warn is called with an *InvalidUTF8Error for every
+// invalid string. If warn is nil, the error is logged with log.Print.
+func (m *WireMessage) SetUTF8Policy(policy UTF8Policy, warn func(error)) {
+	m.mode.utf8 = policy
+	m.mode.utf8Warn = warn
+}
+
+// UTF8Policy returns the UTF-8 validation policy of m
+func (m *WireMessage) UTF8Policy() UTF8Policy {
+	return m.mode.utf8
+}
+
+// checkUTF8 applies the UTF-8 policy of m to the string s of field
+func (m *WireMessage) checkUTF8(field FieldNum, s string) (string, error) {
+	if m.mode.utf8 == UTF8Off || utf8.ValidString(s) {
+		return s, nil
+	}
+	err := &InvalidUTF8Error{Field: field, Offset: invalidUTF8Offset(s)}
+	switch m.mode.utf8 {
+	case UTF8Warn:
+		if m.mode.utf8Warn != nil {
+			m.mode.utf8Warn(err)
+		} else {
+			log.Print("dproto: ", err)
+		}
+	case UTF8Reject:
+		return "", err
+	case UTF8Repair:
+		return strings.ToValidUTF8(s, string(utf8.RuneError)), nil
+	}
+	return s, nil
+}
+
+// invalidUTF8Offset returns the index of the first invalid byte in s
+func invalidUTF8Offset(s string) int {
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
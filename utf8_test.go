@@ -0,0 +1,155 @@
+package dproto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const utf8TestInvalid = "ok\xffbad\xc3"
+
+func newUTF8TestMessage(t *testing.T) *WireMessage {
+	m := NewWireMessage()
+	m.EncodeString(1, "héllo")
+	m.EncodeString(2, utf8TestInvalid)
+	buf, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = Unmarshal(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestUTF8Policies(t *testing.T) {
+	str := descriptor.FieldDescriptorProto_TYPE_STRING
+	m := newUTF8TestMessage(t)
+
+	if v, err := m.DecodeAs(2, str); err != nil || v != utf8TestInvalid {
+		t.Errorf("UTF8Off changed the string: %q (%v)", v, err)
+	}
+
+	m.SetUTF8Policy(UTF8Reject, nil)
+	if v, err := m.DecodeAs(1, str); err != nil || v != "héllo" {
+		t.Errorf("Valid string was rejected: %q (%v)", v, err)
+	}
+	_, err := m.DecodeAs(2, str)
+	var utf8Err *InvalidUTF8Error
+	if !errors.As(err, &utf8Err) || utf8Err.Field != 2 || utf8Err.Offset != 2 {
+		t.Fatalf("Expected InvalidUTF8Error for field 2 at byte 2, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidUTF8) || err.Error() != "Invalid UTF-8: field 2 at byte 2" {
+		t.Errorf("Unexpected error %q", err)
+	}
+	if _, err := m.DecodeRepeatedAs(2, str); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 for repeated field, got %v", err)
+	}
+
+	var warnings []error
+	m.SetUTF8Policy(UTF8Warn, func(err error) { warnings = append(warnings, err) })
+	if v, err := m.DecodeAs(2, str); err != nil || v != utf8TestInvalid {
+		t.Errorf("UTF8Warn changed the string: %q (%v)", v, err)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrInvalidUTF8) {
+		t.Errorf("Expected one warning, got %v", warnings)
+	}
+
+	m.SetUTF8Policy(UTF8Repair, nil)
+	if v, err := m.DecodeAs(2, str); err != nil || v != "ok�bad�" {
+		t.Errorf("Unexpected repaired string %q (%v)", v, err)
+	}
+}
+
+func TestUTF8Encode(t *testing.T) {
+	str := descriptor.FieldDescriptorProto_TYPE_STRING
+	m := NewWireMessage()
+	m.SetUTF8Policy(UTF8Reject, nil)
+	if err := m.EncodeAs(1, utf8TestInvalid, str); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8, got %v", err)
+	}
+	if err := m.AppendAs(2, utf8TestInvalid, str); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Expected ErrInvalidUTF8 from AppendAs, got %v", err)
+	}
+	if m.GetFieldCount() != 0 {
+		t.Error("Rejected strings were encoded")
+	}
+
+	m.SetUTF8Policy(UTF8Repair, nil)
+	if err := m.EncodeAs(1, "\xff", str); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.DecodeString(1); v != "�" {
+		t.Errorf("Expected a repaired string, got %q", v)
+	}
+}
+
+func TestUTF8Inherited(t *testing.T) {
+	inner := NewWireMessage()
+	inner.EncodeString(1, utf8TestInvalid)
+	m := NewWireMessage()
+	m.EncodeMessage(3, inner)
+	m.SetUTF8Policy(UTF8Reject, nil)
+
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+	outer := NewProtoFieldMap()
+	outer.AddMessage(3, fm)
+	if _, err := outer.DecodeMessage(m); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Embedded message did not inherit the UTF-8 policy: %v", err)
+	}
+}
+
+func TestUTF8WireOptions(t *testing.T) {
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_STRING)
+	outer := NewProtoFieldMap()
+	outer.SetName("test.Outer")
+	outer.AddMessage(3, fm)
+	outer.AddMap(4, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_INT32)
+
+	values := []FieldValue{{Field: 3, Value: []FieldValue{{Field: 1, Value: utf8TestInvalid}}}}
+	buf, err := outer.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reject := WireOptions{UTF8: UTF8Reject}
+	if _, err := reject.EncodeBuffer(outer, values); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("EncodeBuffer accepted an invalid embedded string: %v", err)
+	}
+	badKey := []FieldValue{{Field: 4, Value: map[string]int32{utf8TestInvalid: 1}}}
+	if _, err := reject.EncodeMessage(outer, badKey); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("EncodeMessage accepted an invalid map key: %v", err)
+	}
+	if _, err := reject.DecodeBuffer(outer, buf); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("DecodeBuffer accepted an invalid embedded string: %v", err)
+	}
+	if result := reject.DecodeBufferResult(outer, buf, UnknownFieldsIgnore); !errors.Is(result.Err, ErrInvalidUTF8) {
+		t.Errorf("DecodeBufferResult accepted an invalid embedded string: %v", result.Err)
+	}
+
+	r := NewRegistry()
+	r.AddMessage(outer)
+	var warnings []error
+	warn := WireOptions{UTF8: UTF8Warn, UTF8Warn: func(err error) { warnings = append(warnings, err) }}
+	if _, err := warn.Encode(r, "test.Outer", values); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := warn.Decode(r, "test.Outer", buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Expected 2 warnings, got %v", warnings)
+	}
+
+	decoded, err := WireOptions{UTF8: UTF8Repair}.DecodeBuffer(outer, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := decoded[0].Value.([]FieldValue)[0].Value; s != "ok�bad�" {
+		t.Errorf("String was not repaired: %q", s)
+	}
+}
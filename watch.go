@@ -0,0 +1,250 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds the RegistryWatcher, which keeps a Registry loaded from
+// a directory of schema files up to date while a service is running.
+
+package dproto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// descriptorSetExts are the file extensions treated as binary
+// FileDescriptorSets, as written by "protoc --descriptor_set_out"
+var descriptorSetExts = map[string]bool{
+	".pb":       true,
+	".desc":     true,
+	".protoset": true,
+}
+
+// ProtoFileParser parses .proto files into linked file descriptors.
+// The filenames are relative to dir and use forward slashes.
+// See the protoparse subpackage for an implementation.
+type ProtoFileParser func(dir string, filenames []string) ([]*descriptor.FileDescriptorProto, error)
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// RegistryWatcher loads a Registry from the descriptor set and .proto files
+// in a directory, and reloads it whenever they change.
+//
+// Every load creates a new Registry snapshot, which is swapped in atomically.
+// Snapshots must be treated as read only, which lets them be shared by any
+// number of goroutines. A decode that already holds a snapshot keeps using
+// it, even after a newer one has been swapped in.
+// If a load fails, the last good snapshot is kept.
+type RegistryWatcher struct {
+	dir        string
+	parseProto ProtoFileParser
+
+	snapshot atomic.Value // *Registry
+
+	mu      sync.Mutex // serializes loads
+	stamps  map[string]fileStamp
+	lastErr error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRegistryWatcher creates a RegistryWatcher for dir and loads the first
+// snapshot. Files ending in .pb, .desc or .protoset are read as binary
+// FileDescriptorSets. Files ending in .proto are parsed with parseProto,
+// or ignored if parseProto is nil.
+//
+// An error is returned if the first load fails.
+func NewRegistryWatcher(dir string, parseProto ProtoFileParser) (*RegistryWatcher, error) {
+	w := &RegistryWatcher{dir: dir, parseProto: parseProto}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Registry returns the current snapshot. It must not be modified.
+func (w *RegistryWatcher) Registry() *Registry {
+	r, _ := w.snapshot.Load().(*Registry)
+	return r
+}
+
+// Err returns the error from the most recent load, or nil if it succeeded
+func (w *RegistryWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+// Reload checks the directory for changes and, if there are any, loads and
+// swaps in a new snapshot. It returns true if a new snapshot was swapped in.
+//
+// On error, the previous snapshot stays in place. The same files are not
+// retried until they change again, and Err keeps reporting the error
+// until then.
+func (w *RegistryWatcher) Reload() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stamps, err := w.scan()
+	if err != nil {
+		// Load again once the directory can be read
+		w.stamps = nil
+		w.lastErr = err
+		return false, err
+	}
+	if w.stamps != nil && sameStamps(w.stamps, stamps) {
+		return false, nil
+	}
+	w.stamps = stamps
+
+	r, err := w.load(stamps)
+	w.lastErr = err
+	if err != nil {
+		return false, err
+	}
+	w.snapshot.Store(r)
+	return true, nil
+}
+
+// Start polls the directory for changes every interval, until Stop is
+// called. Load errors are passed to onError, if it isn't nil.
+func (w *RegistryWatcher) Start(interval time.Duration, onError func(error)) {
+	w.mu.Lock()
+	if w.stop != nil {
+		w.mu.Unlock()
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	stop, done := w.stop, w.done
+	w.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := w.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops polling started by Start and waits for it to finish
+func (w *RegistryWatcher) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// scan finds all schema files in the directory and their stamps.
+// Paths are relative to the directory and use forward slashes.
+func (w *RegistryWatcher) scan() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	err := filepath.Walk(w.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || !(descriptorSetExts[ext] || ext == ".proto") {
+			return nil
+		}
+		rel, err := filepath.Rel(w.dir, path)
+		if err != nil {
+			return err
+		}
+		stamps[filepath.ToSlash(rel)] = fileStamp{info.ModTime(), info.Size()}
+		return nil
+	})
+	return stamps, err
+}
+
+// sameStamps indicates if no file was added, removed or modified
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, stamp := range a {
+		if other, ok := b[name]; !ok || !other.modTime.Equal(stamp.modTime) || other.size != stamp.size {
+			return false
+		}
+	}
+	return true
+}
+
+// load builds a new Registry from the scanned files
+func (w *RegistryWatcher) load(stamps map[string]fileStamp) (*Registry, error) {
+	names := make([]string, 0, len(stamps))
+	for name := range stamps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The same file may be included in several sets, so only the first
+	// copy of each is kept
+	var files []*descriptor.FileDescriptorProto
+	seen := make(map[string]bool)
+	add := func(fds []*descriptor.FileDescriptorProto) {
+		for _, fd := range fds {
+			if !seen[fd.GetName()] {
+				seen[fd.GetName()] = true
+				files = append(files, fd)
+			}
+		}
+	}
+
+	var protoFiles []string
+	for _, name := range names {
+		if strings.HasSuffix(name, ".proto") {
+			protoFiles = append(protoFiles, name)
+			continue
+		}
+		buf, err := ioutil.ReadFile(filepath.Join(w.dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		set, err := unmarshalFileDescriptorSet(buf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		add(set.GetFile())
+	}
+	if len(protoFiles) > 0 && w.parseProto != nil {
+		fds, err := w.parseProto(w.dir, protoFiles)
+		if err != nil {
+			return nil, err
+		}
+		add(fds)
+	}
+
+	r := NewRegistry()
+	if err := r.AddFiles(files...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,120 @@
+package dproto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// writeDescriptorSet writes the files as a descriptor set with the given
+// modification time
+func writeDescriptorSet(t *testing.T, path string, mtime time.Time, files ...*descriptor.FileDescriptorProto) {
+	buf, err := proto.Marshal(&descriptor.FileDescriptorSet{File: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, mtime, buf)
+}
+
+// writeFile atomically replaces the file at path, so that a polling
+// watcher never sees it half written
+func writeFile(t *testing.T, path string, mtime time.Time, buf []byte) {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(tmp, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	set := filepath.Join(dir, "schemas.pb")
+	start := time.Now().Add(-time.Hour)
+	writeDescriptorSet(t, set, start, testMessageFile())
+	// Files with other extensions are ignored
+	writeFile(t, filepath.Join(dir, "README"), start, []byte("not a schema"))
+
+	w, err := NewRegistryWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := w.Registry()
+	if _, ok := old.GetMessage("TestMessage"); !ok {
+		t.Fatal("TestMessage was not loaded")
+	}
+	if changed, err := w.Reload(); changed || err != nil {
+		t.Errorf("Expected no change, got %v, %v", changed, err)
+	}
+
+	// A broken file must not replace the last good snapshot
+	writeFile(t, set, start.Add(time.Minute), []byte{0xFF, 0xFF})
+	if changed, err := w.Reload(); changed || err == nil {
+		t.Fatalf("Expected a load error, got %v, %v", changed, err)
+	}
+	if w.Registry() != old || w.Err() == nil {
+		t.Error("Last good snapshot should be kept after a failed load")
+	}
+
+	v2 := testMessageFile()
+	v2.MessageType[0].Name = proto.String("NewMessage")
+	writeDescriptorSet(t, set, start.Add(2*time.Minute), v2)
+	if changed, err := w.Reload(); !changed || err != nil {
+		t.Fatalf("Expected a reload, got %v, %v", changed, err)
+	}
+	if _, ok := w.Registry().GetMessage("NewMessage"); !ok || w.Err() != nil {
+		t.Error("NewMessage was not loaded")
+	}
+	// Holders of the old snapshot are unaffected
+	if _, ok := old.GetMessage("TestMessage"); !ok {
+		t.Error("Old snapshot was modified")
+	}
+}
+
+func TestRegistryWatcherConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	set := filepath.Join(dir, "schemas.desc")
+	start := time.Now().Add(-time.Hour)
+	writeDescriptorSet(t, set, start, testMessageFile())
+
+	w, err := NewRegistryWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Start(time.Millisecond, func(err error) { t.Error(err) })
+	defer w.Stop()
+
+	buf, err := w.Registry().Encode("TestMessage", []FieldValue{{Field: 1, Value: int32(5)}})
+	if err != nil {
+		t.Fatal("Error Encoding: " + err.Error())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if _, err := w.Registry().Decode("TestMessage", buf); err != nil {
+					t.Error("Error Decoding: " + err.Error())
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 5; i++ {
+		writeDescriptorSet(t, set, start.Add(time.Duration(i)*time.Minute), testMessageFile())
+		time.Sleep(2 * time.Millisecond)
+	}
+	wg.Wait()
+}
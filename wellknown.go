@@ -0,0 +1,311 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds built-in schemas for the well-known types Timestamp,
+// Duration and the wrappers like Int64Value. Fields of these types, whether
+// added with AddWellKnown or loaded from a schema, decode to and encode from
+// native Go values, instead of []FieldValue.
+
+package dproto
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Names of the supported well-known types
+const (
+	TimestampName   = "google.protobuf.Timestamp"
+	DurationName    = "google.protobuf.Duration"
+	DoubleValueName = "google.protobuf.DoubleValue"
+	FloatValueName  = "google.protobuf.FloatValue"
+	Int64ValueName  = "google.protobuf.Int64Value"
+	UInt64ValueName = "google.protobuf.UInt64Value"
+	Int32ValueName  = "google.protobuf.Int32Value"
+	UInt32ValueName = "google.protobuf.UInt32Value"
+	BoolValueName   = "google.protobuf.BoolValue"
+	StringValueName = "google.protobuf.StringValue"
+	BytesValueName  = "google.protobuf.BytesValue"
+)
+
+// Ranges documented for Timestamp and Duration
+const (
+	minTimestampSeconds = -62135596800 // 0001-01-01T00:00:00Z
+	maxTimestampSeconds = 253402300799 // 9999-12-31T23:59:59Z
+	maxDurationSeconds  = 315576000000 // about 10,000 years
+	maxNanos            = 999999999
+)
+
+// wellKnownType converts between a well-known message and its Go value
+type wellKnownType struct {
+	schema *ProtoFieldMap
+	decode func(m *WireMessage) (interface{}, error)
+	// encode returns false if value is not of the expected Go type, and a
+	// nil message if value is a nil pointer, which means the field is absent
+	encode func(value interface{}) (*WireMessage, bool, error)
+}
+
+// wellKnownTypes holds the supported well-known types by name
+var wellKnownTypes = newWellKnownTypes()
+
+func newWellKnownTypes() map[string]*wellKnownType {
+	byName := map[string]*wellKnownType{
+		TimestampName: {decode: decodeTimestamp, encode: encodeTimestamp},
+		DurationName:  {decode: decodeDuration, encode: encodeDuration},
+	}
+	for _, name := range []string{TimestampName, DurationName} {
+		fm := NewProtoFieldMap()
+		fm.SetName(name)
+		fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT64)
+		fm.SetFieldName(1, "seconds")
+		fm.Add(2, descriptor.FieldDescriptorProto_TYPE_INT32)
+		fm.SetFieldName(2, "nanos")
+		byName[name].schema = fm.Freeze()
+	}
+
+	wrappers := map[string]descriptor.FieldDescriptorProto_Type{
+		DoubleValueName: descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		FloatValueName:  descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		Int64ValueName:  descriptor.FieldDescriptorProto_TYPE_INT64,
+		UInt64ValueName: descriptor.FieldDescriptorProto_TYPE_UINT64,
+		Int32ValueName:  descriptor.FieldDescriptorProto_TYPE_INT32,
+		UInt32ValueName: descriptor.FieldDescriptorProto_TYPE_UINT32,
+		BoolValueName:   descriptor.FieldDescriptorProto_TYPE_BOOL,
+		StringValueName: descriptor.FieldDescriptorProto_TYPE_STRING,
+		BytesValueName:  descriptor.FieldDescriptorProto_TYPE_BYTES,
+	}
+	for name, typ := range wrappers {
+		fm := NewProtoFieldMap()
+		fm.SetName(name)
+		fm.Add(1, typ)
+		fm.SetFieldName(1, "value")
+		byName[name] = newWrapperType(fm.Freeze(), typ)
+	}
+
+	// Any has no Go value of its own, see ExpandAny and UnpackAny
+	byName[AnyName] = &wellKnownType{schema: newAnySchema()}
+
+	structFM, valueFM, listFM := newStructSchemas()
+	byName[StructName] = &wellKnownType{schema: structFM, decode: decodeStructValue, encode: encodeStructValue}
+	byName[ValueName] = &wellKnownType{schema: valueFM, decode: WireToValue, encode: encodeValue}
+	byName[ListValueName] = &wellKnownType{schema: listFM, decode: decodeList, encode: encodeList}
+	return byName
+}
+
+// WellKnownSchema returns the built-in schema of the well-known type name,
+// like "google.protobuf.Timestamp". The schema is frozen.
+func WellKnownSchema(name string) (*ProtoFieldMap, bool) {
+	wkt, ok := wellKnownTypes[name]
+	if !ok {
+		return nil, false
+	}
+	return wkt.schema, true
+}
+
+// AddWellKnown adds a field holding the well-known type name, like
+// "google.protobuf.Timestamp". It returns false if the type is not supported.
+//
+// The field decodes to a time.Time for Timestamp, a time.Duration for
+// Duration and a pointer, like *int64 for Int64Value, for the wrappers.
+// Struct, ListValue and Value decode to a map[string]interface{}, a
+// []interface{} and any of the values WireToValue returns.
+// The same Go values, or the plain values of wrappers, are accepted when
+// encoding. Timestamps and Durations outside their documented ranges
+// return ErrOverflow. Any fields decode to a []FieldValue, like other
+// nested messages, which ExpandAny can resolve.
+func (fm *ProtoFieldMap) AddWellKnown(field FieldNum, name string) bool {
+	wkt, ok := wellKnownTypes[name]
+	if !ok {
+		return false
+	}
+	return fm.AddMessage(field, wkt.schema)
+}
+
+// decodeWellKnown converts the decoded wire message value of a well-known
+// type. It returns false if sub does not name a well-known type.
+func decodeWellKnown(sub *ProtoFieldMap, value interface{}) (interface{}, bool, error) {
+	wkt, ok := wellKnownTypes[normalizeTypeName(sub.name)]
+	if !ok || wkt.decode == nil {
+		return nil, false, nil
+	}
+	wm, ok := value.(*WireMessage)
+	if !ok {
+		return nil, false, nil
+	}
+	v, err := wkt.decode(wm)
+	return v, true, err
+}
+
+// encodeWellKnown converts a Go value into the wire message of a well-known
+// type. It returns false if sub does not name a well-known type, or value
+// is not of its Go type.
+func encodeWellKnown(sub *ProtoFieldMap, value interface{}) (*WireMessage, bool, error) {
+	wkt, ok := wellKnownTypes[normalizeTypeName(sub.name)]
+	if !ok || wkt.encode == nil {
+		return nil, false, nil
+	}
+	return wkt.encode(value)
+}
+
+// decodeSecondsNanos reads the two fields shared by Timestamp and Duration
+func decodeSecondsNanos(m *WireMessage) (int64, int32, error) {
+	seconds, err := m.DecodeAs(1, descriptor.FieldDescriptorProto_TYPE_INT64)
+	if err == ErrMessageFieldMissing {
+		seconds, err = int64(0), nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	nanos, err := m.DecodeAs(2, descriptor.FieldDescriptorProto_TYPE_INT32)
+	if err == ErrMessageFieldMissing {
+		nanos, err = int32(0), nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return seconds.(int64), nanos.(int32), nil
+}
+
+// encodeSecondsNanos writes the two fields shared by Timestamp and
+// Duration, leaving out zero values like proto3 does
+func encodeSecondsNanos(seconds int64, nanos int32) *WireMessage {
+	m := NewWireMessage()
+	if seconds != 0 {
+		m.EncodeInt64(1, seconds)
+	}
+	if nanos != 0 {
+		m.EncodeInt32(2, nanos)
+	}
+	return m
+}
+
+// checkTimestamp validates a Timestamp against its documented range
+func checkTimestamp(seconds int64, nanos int32) error {
+	if seconds < minTimestampSeconds || seconds > maxTimestampSeconds {
+		return fmt.Errorf("%w: timestamp seconds %d outside of years 1 to 9999", ErrOverflow, seconds)
+	}
+	if nanos < 0 || nanos > maxNanos {
+		return fmt.Errorf("%w: timestamp nanos %d outside of 0 to %d", ErrOverflow, nanos, maxNanos)
+	}
+	return nil
+}
+
+func decodeTimestamp(m *WireMessage) (interface{}, error) {
+	seconds, nanos, err := decodeSecondsNanos(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTimestamp(seconds, nanos); err != nil {
+		return nil, err
+	}
+	return time.Unix(seconds, int64(nanos)).UTC(), nil
+}
+
+func encodeTimestamp(value interface{}) (*WireMessage, bool, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return nil, true, nil
+		}
+		t = *v
+	default:
+		return nil, false, nil
+	}
+	seconds, nanos := t.Unix(), int32(t.Nanosecond())
+	if err := checkTimestamp(seconds, nanos); err != nil {
+		return nil, true, err
+	}
+	return encodeSecondsNanos(seconds, nanos), true, nil
+}
+
+// checkDuration validates a Duration against its documented range
+func checkDuration(seconds int64, nanos int32) error {
+	if seconds < -maxDurationSeconds || seconds > maxDurationSeconds {
+		return fmt.Errorf("%w: duration seconds %d outside of ±%d", ErrOverflow, seconds, int64(maxDurationSeconds))
+	}
+	if nanos < -maxNanos || nanos > maxNanos || (seconds > 0 && nanos < 0) || (seconds < 0 && nanos > 0) {
+		return fmt.Errorf("%w: duration nanos %d invalid for seconds %d", ErrOverflow, nanos, seconds)
+	}
+	return nil
+}
+
+func decodeDuration(m *WireMessage) (interface{}, error) {
+	seconds, nanos, err := decodeSecondsNanos(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDuration(seconds, nanos); err != nil {
+		return nil, err
+	}
+	// time.Duration only spans about 292 years
+	const maxSeconds = int64(1<<63-1) / int64(time.Second)
+	if seconds > maxSeconds || seconds < -maxSeconds {
+		return nil, fmt.Errorf("%w: duration of %d seconds does not fit in a time.Duration", ErrOverflow, seconds)
+	}
+	return time.Duration(seconds)*time.Second + time.Duration(nanos), nil
+}
+
+func encodeDuration(value interface{}) (*WireMessage, bool, error) {
+	var d time.Duration
+	switch v := value.(type) {
+	case time.Duration:
+		d = v
+	case *time.Duration:
+		if v == nil {
+			return nil, true, nil
+		}
+		d = *v
+	default:
+		return nil, false, nil
+	}
+	// Any time.Duration is within the documented range, and Go's truncated
+	// division gives seconds and nanos the same sign
+	return encodeSecondsNanos(int64(d/time.Second), int32(d%time.Second)), true, nil
+}
+
+// newWrapperType creates the conversions of a wrapper holding a single
+// value of the Protobuf type typ
+func newWrapperType(schema *ProtoFieldMap, typ descriptor.FieldDescriptorProto_Type) *wellKnownType {
+	goType := reflect.TypeOf(zeroValue(typ))
+	return &wellKnownType{
+		schema: schema,
+		decode: func(m *WireMessage) (interface{}, error) {
+			v, err := m.DecodeAs(1, typ)
+			if err == ErrMessageFieldMissing {
+				v, err = zeroValue(typ), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			ptr := reflect.New(goType)
+			ptr.Elem().Set(reflect.ValueOf(v))
+			return ptr.Interface(), nil
+		},
+		encode: func(value interface{}) (*WireMessage, bool, error) {
+			rv := reflect.ValueOf(value)
+			if rv.Kind() == reflect.Ptr && rv.Type().Elem() == goType {
+				if rv.IsNil() {
+					return nil, true, nil
+				}
+				rv = rv.Elem()
+			}
+			if !rv.IsValid() || rv.Type() != goType {
+				return nil, false, nil
+			}
+			m := NewWireMessage()
+			// Like proto3, the default value is left out
+			if !(rv.IsZero() || (rv.Kind() == reflect.Slice && rv.Len() == 0)) {
+				if err := m.EncodeAs(1, rv.Interface(), typ); err != nil {
+					return nil, true, err
+				}
+			}
+			return m, true, nil
+		},
+	}
+}
@@ -0,0 +1,212 @@
+package dproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func newWellKnownTestMap(t *testing.T) *ProtoFieldMap {
+	fm := NewProtoFieldMap()
+	for field, name := range map[FieldNum]string{
+		1: TimestampName,
+		2: DurationName,
+		3: Int64ValueName,
+		4: StringValueName,
+		5: BytesValueName,
+	} {
+		if !fm.AddWellKnown(field, name) {
+			t.Fatalf("Failed to add %s", name)
+		}
+	}
+	return fm
+}
+
+func TestWellKnownRoundTrip(t *testing.T) {
+	fm := newWellKnownTestMap(t)
+	i, s := int64(-5), ""
+	values := []FieldValue{
+		{1, time.Unix(1500000000, 123).UTC()},
+		{2, -1500 * time.Millisecond},
+		{3, &i},
+		{4, &s},
+		{5, []byte{1, 2}},
+	}
+	buf, err := fm.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wrappers always decode to pointers
+	data := []byte{1, 2}
+	values[4].Value = &data
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("Expected %v, got %v", values, decoded)
+	}
+}
+
+func TestWellKnownLayout(t *testing.T) {
+	fm := newWellKnownTestMap(t)
+	buf, err := fm.EncodeBuffer([]FieldValue{
+		{1, time.Unix(1, 2)},
+		{2, -1500 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duration seconds and nanos share the same sign
+	expected := []byte{
+		0x0A, 0x04, 0x08, 0x01, 0x10, 0x02,
+		0x12, 0x16,
+		0x08, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01,
+		0x10, 0x80, 0xB6, 0xCA, 0x91, 0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0x01,
+	}
+	// Fields may be marshalled in any order
+	if len(buf) != len(expected) || !bytes.Contains(buf, expected[:6]) || !bytes.Contains(buf, expected[6:]) {
+		t.Errorf("Expected %# x, got %# x", expected, buf)
+	}
+}
+
+func TestWellKnownNilOmitted(t *testing.T) {
+	fm := newWellKnownTestMap(t)
+	buf, err := fm.EncodeBuffer([]FieldValue{
+		{1, (*time.Time)(nil)},
+		{3, (*int64)(nil)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != 0 {
+		t.Errorf("Expected an empty buffer, got %# x", buf)
+	}
+
+	// A present but empty wrapper still decodes to a pointer
+	buf, err = fm.EncodeBuffer([]FieldValue{{3, int64(0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || *decoded[0].Value.(*int64) != 0 {
+		t.Errorf("Expected a pointer to 0, got %v", decoded)
+	}
+}
+
+func TestWellKnownRange(t *testing.T) {
+	fm := newWellKnownTestMap(t)
+	if _, err := fm.EncodeBuffer([]FieldValue{{1, time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)}}); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow for year 10000, got %v", err)
+	}
+
+	tests := []struct {
+		field          FieldNum
+		seconds, nanos int64
+	}{
+		{1, minTimestampSeconds - 1, 0},
+		{1, 0, -1},
+		{1, 0, 1000000000},
+		{2, maxDurationSeconds + 1, 0},
+		{2, 1, -1},
+		{2, -1, 1},
+		// Within the documented range, but too long for a time.Duration
+		{2, 300 * 365 * 24 * 60 * 60, 0},
+	}
+	for i, test := range tests {
+		sub := NewWireMessage()
+		sub.EncodeInt64(1, test.seconds)
+		sub.EncodeInt64(2, test.nanos)
+		m := NewWireMessage()
+		if err := m.EncodeMessage(test.field, sub); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fm.DecodeMessage(m); !errors.Is(err, ErrOverflow) {
+			t.Errorf("Test %d: expected ErrOverflow, got %v", i, err)
+		}
+	}
+}
+
+func TestWellKnownDynamicMessage(t *testing.T) {
+	fm := newWellKnownTestMap(t)
+	fm.SetFieldName(1, "created")
+	d := NewDynamicMessage(fm)
+	now := time.Unix(1700000000, 42).UTC()
+	if err := d.SetByName("created", now); err != nil {
+		t.Fatal(err)
+	}
+	v, err := d.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := v.(time.Time); !ok || !got.Equal(now) {
+		t.Errorf("Expected %v, got %v", now, v)
+	}
+}
+
+func TestWellKnownUnsupported(t *testing.T) {
+	fm := NewProtoFieldMap()
+	if fm.AddWellKnown(1, "google.protobuf.Nothing") {
+		t.Error("Added an unsupported well-known type")
+	}
+	if _, ok := WellKnownSchema("google.protobuf.Nothing"); ok {
+		t.Error("Found a schema for an unsupported well-known type")
+	}
+	schema, ok := WellKnownSchema(DurationName)
+	if !ok {
+		t.Fatal("Missing the Duration schema")
+	}
+	if typ, _ := schema.Get(1); typ != descriptor.FieldDescriptorProto_TYPE_INT64 {
+		t.Errorf("Expected seconds to be an int64, got %v", typ)
+	}
+}
+
+func TestWellKnownLoadedSchema(t *testing.T) {
+	// Schemas from descriptors, .proto files or schema JSON are detected
+	// by name, like the built-in ones
+	const schema = `{
+  "name": "test.Event",
+  "fields": [
+    {"number": 1, "name": "at", "type": "message", "message": "google.protobuf.Timestamp"},
+    {"number": 2, "name": "count", "type": "message", "message": ".google.protobuf.Int64Value"}
+  ],
+  "messages": [
+    {"name": "google.protobuf.Timestamp", "fields": [
+      {"number": 1, "name": "seconds", "type": "int64"},
+      {"number": 2, "name": "nanos", "type": "int32"}
+    ]},
+    {"name": ".google.protobuf.Int64Value", "fields": [
+      {"number": 1, "name": "value", "type": "int64"}
+    ]}
+  ]
+}`
+	fm := NewProtoFieldMap()
+	if err := json.Unmarshal([]byte(schema), fm); err != nil {
+		t.Fatal(err)
+	}
+	if sub, _ := fm.GetMessage(1); sub == wellKnownTypes[TimestampName].schema {
+		t.Fatal("Expected a schema of its own")
+	}
+
+	count := int64(3)
+	values := []FieldValue{{1, time.Unix(1500000000, 5).UTC()}, {2, &count}}
+	buf, err := fm.EncodeBuffer(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := fm.DecodeBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("Expected %v, got %v", values, decoded)
+	}
+}
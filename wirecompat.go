@@ -0,0 +1,131 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file tells a missing field apart from a field that arrived with the
+// wrong wire type, and holds the compatibility mode, which follows
+// Protobuf's more forgiving parsing rules.
+
+package dproto
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// ErrWireTypeMismatch is returned when a field is present, but with a wire
+// type that does not match the requested Protobuf type
+var ErrWireTypeMismatch = errors.New("Wire type mismatch")
+
+// ErrInvalidInt32 is returned when decoding an int32 field whose varint
+// is not a sign extended 32 bit value
+var ErrInvalidInt32 = errors.New("Invalid int32 varint")
+
+// wireMode holds the decoding and encoding settings of a WireMessage
+type wireMode struct {
+	compat      bool
+	legacyInt32 bool
+	utf8        UTF8Policy
+	utf8Warn    func(error)
+}
+
+func (w WireType) String() string {
+	switch w {
+	case proto.WireVarint:
+		return "varint"
+	case proto.WireFixed64:
+		return "fixed64"
+	case proto.WireBytes:
+		return "bytes"
+	case proto.WireStartGroup:
+		return "start group"
+	case proto.WireEndGroup:
+		return "end group"
+	case proto.WireFixed32:
+		return "fixed32"
+	}
+	return fmt.Sprintf("WireType(%d)", uint8(w))
+}
+
+// SetCompatMode enables or disables the compatibility mode of m, which is
+// off by default. Embedded messages decoded from m inherit the mode.
+//
+// In compatibility mode, decoding follows Protobuf's more forgiving parsing
+// rules: a field with an unexpected wire type is treated as an unknown
+// field, so it is reported as missing rather than as ErrWireTypeMismatch,
+// and int32 varints are truncated to 32 bits without being validated.
+// Packed repeated fields are accepted in either mode, see DecodeRepeatedAs.
+func (m *WireMessage) SetCompatMode(enabled bool) {
+	m.mode.compat = enabled
+}
+
+// CompatMode indicates if the compatibility mode of m is enabled
+func (m *WireMessage) CompatMode() bool {
+	return m.mode.compat
+}
+
+// SetLegacyInt32 enables or disables the legacy int32 decode mode of m,
+// which is off by default. Embedded messages decoded from m inherit the mode.
+//
+// Older versions of dproto wrote negative int32 values as 5 byte varints,
+// instead of sign extending them to 10 bytes. Decoding such a value as an
+// int32 fails with ErrInvalidInt32, unless the legacy mode is enabled.
+func (m *WireMessage) SetLegacyInt32(enabled bool) {
+	m.mode.legacyInt32 = enabled
+}
+
+// LegacyInt32 indicates if the legacy int32 decode mode of m is enabled
+func (m *WireMessage) LegacyInt32() bool {
+	return m.mode.legacyInt32
+}
+
+// checkInt32 validates the varint v of field before decoding it as an int32
+func (m *WireMessage) checkInt32(field FieldNum, v WireVarint) error {
+	if m.mode.compat || v.ValidInt32() || (m.mode.legacyInt32 && v.isLegacyInt32()) {
+		return nil
+	}
+	return fmt.Errorf("%w: field %d holds %#x, which is not a sign extended int32", ErrInvalidInt32, field, uint64(v))
+}
+
+// wireTypes returns the wire types field is present with in m
+func (m *WireMessage) wireTypes(field FieldNum) []WireType {
+	var types []WireType
+	if len(m.varint[field]) > 0 {
+		types = append(types, proto.WireVarint)
+	}
+	if len(m.fixed64[field]) > 0 {
+		types = append(types, proto.WireFixed64)
+	}
+	if len(m.bytes[field]) > 0 {
+		types = append(types, proto.WireBytes)
+	}
+	if len(m.fixed32[field]) > 0 {
+		types = append(types, proto.WireFixed32)
+	}
+	return types
+}
+
+// missingError explains why field could not be found in m as pbtype.
+// It is ErrWireTypeMismatch if the field is present with another wire type,
+// unless m is in compatibility mode, and ErrMessageFieldMissing otherwise.
+func (m *WireMessage) missingError(field FieldNum, pbtype descriptor.FieldDescriptorProto_Type) error {
+	types := m.wireTypes(field)
+	if len(types) == 0 || m.mode.compat {
+		return ErrMessageFieldMissing
+	}
+	expected := protoType2WireType[pbtype]
+	if types[0] == proto.WireBytes && expected != proto.WireBytes {
+		return fmt.Errorf("%w: field %d expected %s for %s, got bytes (packed?)", ErrWireTypeMismatch, field, expected, typeString(pbtype))
+	}
+	return fmt.Errorf("%w: field %d expected %s for %s, got %s", ErrWireTypeMismatch, field, expected, typeString(pbtype), types[0])
+}
+
+// inherit passes the mode of m on to an embedded message decoded from it,
+// or encoded into it
+func (m *WireMessage) inherit(v interface{}) {
+	if sub, ok := v.(*WireMessage); ok {
+		sub.mode = m.mode
+	}
+}
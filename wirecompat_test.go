@@ -0,0 +1,127 @@
+package dproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestWireTypeMismatch(t *testing.T) {
+	m := NewWireMessage()
+	m.EncodeFixed32(3, 7)
+	m.EncodeString(4, "text")
+
+	_, err := m.DecodeAs(3, descriptor.FieldDescriptorProto_TYPE_INT32)
+	if !errors.Is(err, ErrWireTypeMismatch) {
+		t.Fatalf("Expected ErrWireTypeMismatch, got %v", err)
+	}
+	if err.Error() != "Wire type mismatch: field 3 expected varint for int32, got fixed32" {
+		t.Errorf("Unexpected message %q", err)
+	}
+	if _, err := m.DecodeAs(4, descriptor.FieldDescriptorProto_TYPE_DOUBLE); !errors.Is(err, ErrWireTypeMismatch) {
+		t.Errorf("Expected ErrWireTypeMismatch, got %v", err)
+	}
+	if _, err := m.DecodeRepeatedAs(3, descriptor.FieldDescriptorProto_TYPE_SINT64); !errors.Is(err, ErrWireTypeMismatch) {
+		t.Errorf("Expected ErrWireTypeMismatch for repeated field, got %v", err)
+	}
+	if _, err := m.DecodeAs(5, descriptor.FieldDescriptorProto_TYPE_INT32); err != ErrMessageFieldMissing {
+		t.Errorf("Expected ErrMessageFieldMissing, got %v", err)
+	}
+
+	// Compatibility mode treats the field as unknown
+	m.SetCompatMode(true)
+	if _, err := m.DecodeAs(3, descriptor.FieldDescriptorProto_TYPE_INT32); err != ErrMessageFieldMissing {
+		t.Errorf("Expected ErrMessageFieldMissing in compatibility mode, got %v", err)
+	}
+}
+
+func TestWireTypeString(t *testing.T) {
+	if s := WireType(proto.WireFixed32).String(); s != "fixed32" {
+		t.Errorf("Unexpected name %q", s)
+	}
+	if s := WireType(6).String(); s != "WireType(6)" {
+		t.Errorf("Unexpected name %q", s)
+	}
+}
+
+func TestPackedRepeated(t *testing.T) {
+	// Field 1 holds the zigzag varints 2, 300 and 1, which are the sint32
+	// values 1, 150 and -1, followed by an unpacked 2
+	packedVarints := []byte{0x02, 0xAC, 0x02, 0x01}
+	packedFixed := []byte{0x01, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}
+
+	m := NewWireMessage()
+	m.AppendBytes(1, packedVarints)
+	m.AppendVarint(1, 4)
+	m.AppendFixed32(2, 7)
+	m.AppendBytes(2, packedFixed)
+	m.AppendFixed32(2, 8)
+	buf, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Packed and unpacked values are merged in wire order, without
+	// needing compatibility mode
+	m = NewWireMessage()
+	if err := m.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	vals, err := m.DecodeRepeatedAs(1, descriptor.FieldDescriptorProto_TYPE_SINT32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{int32(1), int32(150), int32(-1), int32(2)}; !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Expected %v, got %v", expected, vals)
+	}
+	vals, err = m.DecodeRepeatedAs(2, descriptor.FieldDescriptorProto_TYPE_SFIXED32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{int32(7), int32(1), int32(-1), int32(8)}; !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Expected %v, got %v", expected, vals)
+	}
+
+	// Marshal keeps the wire order
+	if again, err := m.Marshal(); err != nil || !reflect.DeepEqual(again, buf) {
+		t.Errorf("Expected % x, got % x (%v)", buf, again, err)
+	}
+
+	m.AppendBytes(3, []byte{0x01, 0x02})
+	if _, err := m.DecodeRepeatedAs(3, descriptor.FieldDescriptorProto_TYPE_FIXED32); err != ErrMalformedProtoBuf {
+		t.Errorf("Expected ErrMalformedProtoBuf for a truncated packed field, got %v", err)
+	}
+}
+
+func TestCompatModeInherited(t *testing.T) {
+	inner := NewWireMessage()
+	inner.AppendBytes(1, []byte{0x01, 0x02})
+	m := NewWireMessage()
+	m.EncodeMessage(1, inner)
+	m.SetCompatMode(true)
+
+	sub, err := m.DecodeMessage(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sub.CompatMode() {
+		t.Fatal("Embedded message did not inherit compatibility mode")
+	}
+
+	fm := NewProtoFieldMap()
+	fm.Add(1, descriptor.FieldDescriptorProto_TYPE_INT64)
+	fm.SetLabel(1, descriptor.FieldDescriptorProto_LABEL_REPEATED)
+	outer := NewProtoFieldMap()
+	outer.AddMessage(1, fm)
+	values, err := outer.DecodeMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FieldValue{{1, []FieldValue{{1, []interface{}{int64(1), int64(2)}}}}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
@@ -46,11 +46,27 @@ var ErrInvalidProtoBufType = errors.New("Invalid protobuf type")
 // order. For this reason, this implementation may be changed out in a later
 // date. Sending fields in numerical order is recommended on the Protobuf
 // website.
+//
+// Each field holds every occurrence seen on the wire, in order, so that
+// repeated fields survive a round trip. The singular accessors follow
+// Protobuf's "last one wins" rule and only look at the final occurrence.
 type WireMessage struct {
-	varint  map[FieldNum]WireVarint
-	fixed32 map[FieldNum]WireFixed32
-	fixed64 map[FieldNum]WireFixed64
-	bytes   map[FieldNum][]byte
+	varint  map[FieldNum][]WireVarint
+	fixed32 map[FieldNum][]WireFixed32
+	fixed64 map[FieldNum][]WireFixed64
+	bytes   map[FieldNum][][]byte
+
+	// order records the wire type of each occurrence of a field, so that
+	// occurrences of different wire types keep their relative order
+	order map[FieldNum][]WireType
+
+	// last records the sequence number of each field's most recent
+	// occurrence, so that the relative wire order of fields is known
+	last map[FieldNum]uint64
+	seq  uint64
+
+	// mode holds the validation settings, which embedded messages inherit
+	mode wireMode
 }
 
 // NewWireMessage creates a new Wiremessage object.
@@ -62,34 +78,103 @@ func NewWireMessage() *WireMessage {
 
 // Reset clears the WireMessage m
 func (m *WireMessage) Reset() {
-	m.varint = make(map[FieldNum]WireVarint)
-	m.fixed32 = make(map[FieldNum]WireFixed32)
-	m.fixed64 = make(map[FieldNum]WireFixed64)
-	m.bytes = make(map[FieldNum][]byte)
+	m.varint = make(map[FieldNum][]WireVarint)
+	m.fixed32 = make(map[FieldNum][]WireFixed32)
+	m.fixed64 = make(map[FieldNum][]WireFixed64)
+	m.bytes = make(map[FieldNum][][]byte)
+	m.order = make(map[FieldNum][]WireType)
+	m.last = make(map[FieldNum]uint64)
+	m.seq = 0
+}
+
+// touch records that field was just added to m
+func (m *WireMessage) touch(field FieldNum) {
+	m.seq++
+	m.last[field] = m.seq
+}
+
+// record notes another occurrence of field with the wire type wire.
+// If replace is set, the earlier occurrences of that wire type are dropped.
+func (m *WireMessage) record(field FieldNum, wire WireType, replace bool) {
+	if replace {
+		kept := m.order[field][:0]
+		for _, w := range m.order[field] {
+			if w != wire {
+				kept = append(kept, w)
+			}
+		}
+		m.order[field] = kept
+	}
+	m.order[field] = append(m.order[field], wire)
+	m.touch(field)
+}
+
+// LastOccurrence returns the sequence number of the most recent occurrence
+// of field in m. Fields that were added or unmarshalled later have higher
+// sequence numbers. It returns false if the field is not present.
+func (m *WireMessage) LastOccurrence(field FieldNum) (uint64, bool) {
+	seq, ok := m.last[field]
+	return seq, ok
 }
 
 /*******************************************************
  *             Low-Level Wire Interface                *
  *******************************************************/
 
-// AddVarint adds a WireVarint wiretype to the wire message m
+// AddVarint adds a WireVarint wiretype to the wire message m.
+// Any previous occurrences of the field are replaced.
 func (m *WireMessage) AddVarint(field FieldNum, value WireVarint) {
-	m.varint[field] = value
+	m.varint[field] = []WireVarint{value}
+	m.record(field, proto.WireVarint, true)
 }
 
-// AddFixed32 adds a WireFixed32 wiretype to the wire message m
+// AddFixed32 adds a WireFixed32 wiretype to the wire message m.
+// Any previous occurrences of the field are replaced.
 func (m *WireMessage) AddFixed32(field FieldNum, value WireFixed32) {
-	m.fixed32[field] = value
+	m.fixed32[field] = []WireFixed32{value}
+	m.record(field, proto.WireFixed32, true)
 }
 
-// AddFixed64 adds a WireFixed64 wiretype to the wire message m
+// AddFixed64 adds a WireFixed64 wiretype to the wire message m.
+// Any previous occurrences of the field are replaced.
 func (m *WireMessage) AddFixed64(field FieldNum, value WireFixed64) {
-	m.fixed64[field] = value
+	m.fixed64[field] = []WireFixed64{value}
+	m.record(field, proto.WireFixed64, true)
 }
 
-// AddBytes adds a byte buffer wiretype to the wire message m
+// AddBytes adds a byte buffer wiretype to the wire message m.
+// Any previous occurrences of the field are replaced.
 func (m *WireMessage) AddBytes(field FieldNum, buf []byte) {
-	m.bytes[field] = buf
+	m.bytes[field] = [][]byte{buf}
+	m.record(field, proto.WireBytes, true)
+}
+
+// AppendVarint adds another occurrence of a WireVarint field to m,
+// keeping any previous occurrences (repeated fields)
+func (m *WireMessage) AppendVarint(field FieldNum, value WireVarint) {
+	m.varint[field] = append(m.varint[field], value)
+	m.record(field, proto.WireVarint, false)
+}
+
+// AppendFixed32 adds another occurrence of a WireFixed32 field to m,
+// keeping any previous occurrences (repeated fields)
+func (m *WireMessage) AppendFixed32(field FieldNum, value WireFixed32) {
+	m.fixed32[field] = append(m.fixed32[field], value)
+	m.record(field, proto.WireFixed32, false)
+}
+
+// AppendFixed64 adds another occurrence of a WireFixed64 field to m,
+// keeping any previous occurrences (repeated fields)
+func (m *WireMessage) AppendFixed64(field FieldNum, value WireFixed64) {
+	m.fixed64[field] = append(m.fixed64[field], value)
+	m.record(field, proto.WireFixed64, false)
+}
+
+// AppendBytes adds another occurrence of a byte buffer field to m,
+// keeping any previous occurrences (repeated fields)
+func (m *WireMessage) AppendBytes(field FieldNum, buf []byte) {
+	m.bytes[field] = append(m.bytes[field], buf)
+	m.record(field, proto.WireBytes, false)
 }
 
 // Remove removes the wiretype field previously added
@@ -98,6 +183,29 @@ func (m *WireMessage) Remove(field FieldNum) {
 	delete(m.fixed32, field)
 	delete(m.fixed64, field)
 	delete(m.bytes, field)
+	delete(m.order, field)
+	delete(m.last, field)
+}
+
+// copyField appends every occurrence of field in from to m
+func (m *WireMessage) copyField(from *WireMessage, field FieldNum) {
+	if _, ok := from.last[field]; !ok {
+		return
+	}
+	if vals, ok := from.varint[field]; ok {
+		m.varint[field] = append(m.varint[field], vals...)
+	}
+	if vals, ok := from.fixed32[field]; ok {
+		m.fixed32[field] = append(m.fixed32[field], vals...)
+	}
+	if vals, ok := from.fixed64[field]; ok {
+		m.fixed64[field] = append(m.fixed64[field], vals...)
+	}
+	if vals, ok := from.bytes[field]; ok {
+		m.bytes[field] = append(m.bytes[field], vals...)
+	}
+	m.order[field] = append(m.order[field], from.order[field]...)
+	m.touch(field)
 }
 
 // GetFieldCount gets the number of fields in the WireMessage
@@ -129,43 +237,75 @@ func (m *WireMessage) GetField(field FieldNum) (interface{}, bool) {
 
 	/* Check all data field types to find specified field */
 
-	if val, ok := m.varint[field]; ok {
+	if val, ok := m.GetVarint(field); ok {
 		return val, true
 	}
-	if val, ok := m.fixed32[field]; ok {
+	if val, ok := m.GetFixed32(field); ok {
 		return val, true
 	}
-	if val, ok := m.fixed64[field]; ok {
+	if val, ok := m.GetFixed64(field); ok {
 		return val, true
 	}
-	if val, ok := m.bytes[field]; ok {
+	if val, ok := m.GetBytes(field); ok {
 		return val, true
 	}
 	return nil, false
 }
 
-// GetVarint fetches a varint wire field from m
+// GetVarint fetches a varint wire field from m.
+// If the field occurred multiple times, the last occurrence is returned.
 func (m *WireMessage) GetVarint(field FieldNum) (WireVarint, bool) {
-	val, ok := m.varint[field]
-	return val, ok
+	if vals := m.varint[field]; len(vals) > 0 {
+		return vals[len(vals)-1], true
+	}
+	return 0, false
 }
 
-// GetFixed32 fetches a fixed32 wire field from m
+// GetFixed32 fetches a fixed32 wire field from m.
+// If the field occurred multiple times, the last occurrence is returned.
 func (m *WireMessage) GetFixed32(field FieldNum) (WireFixed32, bool) {
-	val, ok := m.fixed32[field]
-	return val, ok
+	if vals := m.fixed32[field]; len(vals) > 0 {
+		return vals[len(vals)-1], true
+	}
+	return 0, false
 }
 
-// GetFixed64 fetches a fixed64 wire field from m
+// GetFixed64 fetches a fixed64 wire field from m.
+// If the field occurred multiple times, the last occurrence is returned.
 func (m *WireMessage) GetFixed64(field FieldNum) (WireFixed64, bool) {
-	val, ok := m.fixed64[field]
-	return val, ok
+	if vals := m.fixed64[field]; len(vals) > 0 {
+		return vals[len(vals)-1], true
+	}
+	return 0, false
 }
 
-// GetBytes fetches a byte array wire field from m
+// GetBytes fetches a byte array wire field from m.
+// If the field occurred multiple times, the last occurrence is returned.
 func (m *WireMessage) GetBytes(field FieldNum) ([]byte, bool) {
-	val, ok := m.bytes[field]
-	return val, ok
+	if vals := m.bytes[field]; len(vals) > 0 {
+		return vals[len(vals)-1], true
+	}
+	return nil, false
+}
+
+// GetRepeatedVarint fetches all occurrences of a varint wire field from m
+func (m *WireMessage) GetRepeatedVarint(field FieldNum) []WireVarint {
+	return m.varint[field]
+}
+
+// GetRepeatedFixed32 fetches all occurrences of a fixed32 wire field from m
+func (m *WireMessage) GetRepeatedFixed32(field FieldNum) []WireFixed32 {
+	return m.fixed32[field]
+}
+
+// GetRepeatedFixed64 fetches all occurrences of a fixed64 wire field from m
+func (m *WireMessage) GetRepeatedFixed64(field FieldNum) []WireFixed64 {
+	return m.fixed64[field]
+}
+
+// GetRepeatedBytes fetches all occurrences of a byte array wire field from m
+func (m *WireMessage) GetRepeatedBytes(field FieldNum) [][]byte {
+	return m.bytes[field]
 }
 
 /*******************************************************
@@ -216,6 +356,12 @@ func (m *WireMessage) DecodeBool(field FieldNum) (bool, bool) {
 	return val.AsBool(), ok
 }
 
+// DecodeEnum fetches the field from m and decodes it as a Protobuf enum
+func (m *WireMessage) DecodeEnum(field FieldNum) (uint64, bool) {
+	val, ok := m.GetVarint(field)
+	return val.AsEnum(), ok
+}
+
 // DecodeFixed32 fetches the field from m and decodes it as a Protobuf fixed32
 func (m *WireMessage) DecodeFixed32(field FieldNum) (uint32, bool) {
 	val, ok := m.GetFixed32(field)
@@ -252,9 +398,10 @@ func (m *WireMessage) DecodeDouble(field FieldNum) (float64, bool) {
 	return val.AsDouble(), ok
 }
 
-// DecodeString fetches the field from m and decodes it as a Protobuf string
+// DecodeString fetches the field from m and decodes it as a Protobuf string.
+// The string is not validated. DecodeAs and StringField apply the UTF-8
+// policy of m, see SetUTF8Policy.
 func (m *WireMessage) DecodeString(field FieldNum) (string, bool) {
-	// TODO: Check correctness for unicode/7bit ASCII text
 	if val, ok := m.GetBytes(field); ok {
 		return string(val), true
 	}
@@ -271,13 +418,18 @@ func (m *WireMessage) DecodeBytes(field FieldNum) ([]byte, bool) {
 func (m *WireMessage) DecodeMessage(field FieldNum) (*WireMessage, error) {
 	if bytes, ok := m.GetBytes(field); ok {
 		emmsg := NewWireMessage()
+		m.inherit(emmsg)
 		return emmsg, emmsg.Unmarshal(bytes)
 	}
-	return nil, ErrMessageFieldMissing
+	return nil, m.missingError(field, descriptor.FieldDescriptorProto_TYPE_MESSAGE)
 }
 
 // DecodeAs fetches the field from m and decodes it as the specified
 // Protobuf type
+//
+// ErrMessageFieldMissing is returned if the field is not present and
+// ErrWireTypeMismatch if it is only present with a different wire type.
+// Int32 fields that are not properly sign extended return ErrInvalidInt32.
 func (m *WireMessage) DecodeAs(field FieldNum, pbtype descriptor.FieldDescriptorProto_Type) (val interface{}, err error) {
 	val = 0
 	err = nil
@@ -285,7 +437,10 @@ func (m *WireMessage) DecodeAs(field FieldNum, pbtype descriptor.FieldDescriptor
 
 	switch pbtype {
 	case descriptor.FieldDescriptorProto_TYPE_INT32:
-		val, ok = m.DecodeInt32(field)
+		var v WireVarint
+		if v, ok = m.GetVarint(field); ok {
+			val, err = v.AsInt32(), m.checkInt32(field, v)
+		}
 	case descriptor.FieldDescriptorProto_TYPE_INT64:
 		val, ok = m.DecodeInt64(field)
 	case descriptor.FieldDescriptorProto_TYPE_UINT32:
@@ -298,6 +453,8 @@ func (m *WireMessage) DecodeAs(field FieldNum, pbtype descriptor.FieldDescriptor
 		val, ok = m.DecodeSint64(field)
 	case descriptor.FieldDescriptorProto_TYPE_BOOL:
 		val, ok = m.DecodeBool(field)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		val, ok = m.DecodeEnum(field)
 	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
 		val, ok = m.DecodeFixed32(field)
 	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
@@ -311,7 +468,10 @@ func (m *WireMessage) DecodeAs(field FieldNum, pbtype descriptor.FieldDescriptor
 	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
 		val, ok = m.DecodeDouble(field)
 	case descriptor.FieldDescriptorProto_TYPE_STRING:
-		val, ok = m.DecodeString(field)
+		var s string
+		if s, ok = m.DecodeString(field); ok {
+			val, err = m.checkUTF8(field, s)
+		}
 	case descriptor.FieldDescriptorProto_TYPE_BYTES:
 		val, ok = m.DecodeBytes(field)
 	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
@@ -321,11 +481,137 @@ func (m *WireMessage) DecodeAs(field FieldNum, pbtype descriptor.FieldDescriptor
 	}
 
 	if !ok {
-		err = ErrMessageFieldMissing
+		err = m.missingError(field, pbtype)
 	}
 	return
 }
 
+// DecodeRepeatedAs fetches every occurrence of the field from m and decodes
+// each one as the specified Protobuf type. This is used for repeated fields.
+// Like Protobuf parsers, scalar types accept both the packed and unpacked
+// forms, and the values are returned in the order they occurred on the wire.
+func (m *WireMessage) DecodeRepeatedAs(field FieldNum, pbtype descriptor.FieldDescriptorProto_Type) ([]interface{}, error) {
+	wire, ok := protoType2WireType[pbtype]
+	if !ok {
+		return nil, ErrInvalidProtoBufType
+	}
+
+	var vals []interface{}
+	if wire == proto.WireBytes {
+		for _, b := range m.bytes[field] {
+			v, err := bytesAs(b, pbtype)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := v.(string); ok {
+				if v, err = m.checkUTF8(field, s); err != nil {
+					return nil, err
+				}
+			}
+			m.inherit(v)
+			vals = append(vals, v)
+		}
+	} else {
+		// Walk the occurrences in wire order, each of which is either a
+		// single value or a packed run of values
+		var next, nextPacked int
+		for _, w := range m.order[field] {
+			var err error
+			switch w {
+			case wire:
+				vals, err = m.appendScalar(vals, field, wire, next, pbtype)
+				next++
+			case proto.WireBytes:
+				vals, err = m.appendPacked(vals, field, m.bytes[field][nextPacked], wire, pbtype)
+				nextPacked++
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(vals) == 0 {
+		return nil, m.missingError(field, pbtype)
+	}
+	return vals, nil
+}
+
+// appendScalar decodes the i-th occurrence of field with the wire type wire
+// as pbtype and appends it to vals
+func (m *WireMessage) appendScalar(vals []interface{}, field FieldNum, wire WireType, i int, pbtype descriptor.FieldDescriptorProto_Type) ([]interface{}, error) {
+	switch wire {
+	case proto.WireVarint:
+		v := m.varint[field][i]
+		if pbtype == descriptor.FieldDescriptorProto_TYPE_INT32 {
+			if err := m.checkInt32(field, v); err != nil {
+				return nil, err
+			}
+		}
+		return append(vals, varintAs(v, pbtype)), nil
+	case proto.WireFixed32:
+		return append(vals, fixed32As(m.fixed32[field][i], pbtype)), nil
+	case proto.WireFixed64:
+		return append(vals, fixed64As(m.fixed64[field][i], pbtype)), nil
+	}
+	return nil, ErrInvalidProtoBufType
+}
+
+// varintAs interprets a single varint as the Protobuf type pbtype
+func varintAs(v WireVarint, pbtype descriptor.FieldDescriptorProto_Type) interface{} {
+	switch pbtype {
+	case descriptor.FieldDescriptorProto_TYPE_INT32:
+		return v.AsInt32()
+	case descriptor.FieldDescriptorProto_TYPE_INT64:
+		return v.AsInt64()
+	case descriptor.FieldDescriptorProto_TYPE_UINT32:
+		return v.AsUint32()
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return v.AsSint32()
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return v.AsSint64()
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v.AsBool()
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return v.AsEnum()
+	}
+	return v.AsUint64()
+}
+
+// fixed32As interprets a single fixed32 as the Protobuf type pbtype
+func fixed32As(v WireFixed32, pbtype descriptor.FieldDescriptorProto_Type) interface{} {
+	switch pbtype {
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return v.AsSfixed32()
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return v.AsFloat()
+	}
+	return v.AsFixed32()
+}
+
+// fixed64As interprets a single fixed64 as the Protobuf type pbtype
+func fixed64As(v WireFixed64, pbtype descriptor.FieldDescriptorProto_Type) interface{} {
+	switch pbtype {
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return v.AsSfixed64()
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return v.AsDouble()
+	}
+	return v.AsFixed64()
+}
+
+// bytesAs interprets a single length delimited buffer as the Protobuf
+// type pbtype
+func bytesAs(b []byte, pbtype descriptor.FieldDescriptorProto_Type) (interface{}, error) {
+	switch pbtype {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return string(b), nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return Unmarshal(b)
+	}
+	return b, nil
+}
+
 /////////////////////////////// Encoding /////////////////////////////////////
 
 // EncodeInt32 adds value to the WireMessage encoded as a Protobuf int32
@@ -363,6 +649,11 @@ func (m *WireMessage) EncodeBool(field FieldNum, value bool) {
 	m.AddVarint(field, new(WireVarint).FromBool(value))
 }
 
+// EncodeEnum adds value to the WireMessage encoded as a Protobuf enum
+func (m *WireMessage) EncodeEnum(field FieldNum, value uint64) {
+	m.AddVarint(field, new(WireVarint).FromEnum(value))
+}
+
 // EncodeFixed32 adds value to the WireMessage encoded as a Protobuf fixed32
 func (m *WireMessage) EncodeFixed32(field FieldNum, value uint32) {
 	m.AddFixed32(field, new(WireFixed32).FromFixed32(value))
@@ -393,9 +684,10 @@ func (m *WireMessage) EncodeDouble(field FieldNum, value float64) {
 	m.AddFixed64(field, new(WireFixed64).FromDouble(value))
 }
 
-// EncodeString adds value to the WireMessage encoded as a Protobuf string
+// EncodeString adds value to the WireMessage encoded as a Protobuf string.
+// The string is not validated. EncodeAs and StringField apply the UTF-8
+// policy of m, see SetUTF8Policy.
 func (m *WireMessage) EncodeString(field FieldNum, value string) {
-	// TODO: Check correctness for unicode/7bit ASCII text
 	m.AddBytes(field, []byte(value))
 }
 
@@ -417,7 +709,7 @@ func (m *WireMessage) EncodeMessage(field FieldNum, value *WireMessage) error {
 // EncodeAs adds value to the WireMessage encoded as the specified Protobuf type
 //
 // Errors will ensue if the generic type is not compatible with the specified
-// Protobuf type.
+// Protobuf type. Strings are validated according to SetUTF8Policy.
 func (m *WireMessage) EncodeAs(field FieldNum, value interface{}, pbtype descriptor.FieldDescriptorProto_Type) error {
 	err := ErrInvalidProtoBufType
 
@@ -457,6 +749,11 @@ func (m *WireMessage) EncodeAs(field FieldNum, value interface{}, pbtype descrip
 			m.EncodeBool(field, v)
 			err = nil
 		}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if v, ok := value.(uint64); ok {
+			m.EncodeEnum(field, v)
+			err = nil
+		}
 	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
 		if v, ok := value.(uint32); ok {
 			m.EncodeFixed32(field, v)
@@ -489,8 +786,9 @@ func (m *WireMessage) EncodeAs(field FieldNum, value interface{}, pbtype descrip
 		}
 	case descriptor.FieldDescriptorProto_TYPE_STRING:
 		if v, ok := value.(string); ok {
-			m.EncodeString(field, v)
-			err = nil
+			if v, err = m.checkUTF8(field, v); err == nil {
+				m.EncodeString(field, v)
+			}
 		}
 	case descriptor.FieldDescriptorProto_TYPE_BYTES:
 		if v, ok := value.([]byte); ok {
@@ -505,6 +803,30 @@ func (m *WireMessage) EncodeAs(field FieldNum, value interface{}, pbtype descrip
 	return err
 }
 
+// AppendAs adds another occurrence of value to the WireMessage encoded as
+// the specified Protobuf type, keeping any previous occurrences.
+// This is used for repeated fields.
+func (m *WireMessage) AppendAs(field FieldNum, value interface{}, pbtype descriptor.FieldDescriptorProto_Type) error {
+	single := NewWireMessage()
+	single.mode = m.mode
+	if err := single.EncodeAs(field, value, pbtype); err != nil {
+		return err
+	}
+	for _, v := range single.varint[field] {
+		m.AppendVarint(field, v)
+	}
+	for _, v := range single.fixed32[field] {
+		m.AppendFixed32(field, v)
+	}
+	for _, v := range single.fixed64[field] {
+		m.AppendFixed64(field, v)
+	}
+	for _, b := range single.bytes[field] {
+		m.AppendBytes(field, b)
+	}
+	return nil
+}
+
 // Unmarshal sorts a ProtoBuf message into it's constituent
 // parts to be such that it's field can be accessed in constant time
 //
@@ -566,7 +888,7 @@ out:
 				// break out
 				return err
 			}
-			m.AddBytes(field, r)
+			m.AppendBytes(field, r)
 
 		case proto.WireFixed32:
 			u, err = pbuf.DecodeFixed32()
@@ -576,7 +898,7 @@ out:
 				return ErrMalformedProtoBuf
 			}
 			// fmt.Printf("%3d: t=%3d fix32 %d\n", index, tag, u)
-			m.AddFixed32(field, WireFixed32(u))
+			m.AppendFixed32(field, WireFixed32(u))
 
 		case proto.WireFixed64:
 			u, err = pbuf.DecodeFixed64()
@@ -586,7 +908,7 @@ out:
 				return ErrMalformedProtoBuf
 			}
 			// fmt.Printf("%3d: t=%3d fix64 %d\n", index, tag, u)
-			m.AddFixed64(field, WireFixed64(u))
+			m.AppendFixed64(field, WireFixed64(u))
 
 		case proto.WireVarint:
 			u, err = pbuf.DecodeVarint()
@@ -596,7 +918,7 @@ out:
 				return ErrMalformedProtoBuf
 			}
 			// fmt.Printf("%3d: t=%3d varint %d\n", index, tag, u)
-			m.AddVarint(field, WireVarint(u))
+			m.AppendVarint(field, WireVarint(u))
 
 		case proto.WireStartGroup:
 			// fmt.Printf("%3d: t=%3d start\n", index, tag)
@@ -633,71 +955,34 @@ func (m *WireMessage) Marshal() ([]byte, error) {
 	pbuf := proto.NewBuffer(make([]byte, 0, 1))
 
 	// Add all fields in the previously created sorted order
-	for _, fnum := range []FieldNum(fields) {
-
-		field, ok := m.GetField(fnum)
-		if !ok {
-			return nil, ErrMessageFieldMissing
+	for index, fnum := range []FieldNum(fields) {
+		// A field may be listed once per wire type it holds
+		if index > 0 && fields[index-1] == fnum {
+			continue
 		}
 
-		switch field.(type) {
-		case WireVarint:
-			// Make field the appropriate type
-			f := field.(WireVarint)
-			// Write tag header
-			var tag WireVarint
-			tag.FromTag(fnum, proto.WireVarint)
-			err := pbuf.EncodeVarint(uint64(tag))
-			if err != nil {
+		// Write each occurrence in the order it was added, tag header
+		// followed by the field data
+		var tag WireVarint
+		var next [8]int
+		for _, wire := range m.order[fnum] {
+			i := next[wire]
+			next[wire]++
+			tag.FromTag(fnum, wire)
+			if err := pbuf.EncodeVarint(uint64(tag)); err != nil {
 				return nil, err
 			}
-			// Write the field data
-			err = pbuf.EncodeVarint(uint64(f))
-			if err != nil {
-				return nil, err
-			}
-		case WireFixed32:
-			// Make field the appropriate type
-			f := field.(WireFixed32)
-			// Write tag header
-			var tag WireVarint
-			tag.FromTag(fnum, proto.WireFixed32)
-			err := pbuf.EncodeVarint(uint64(tag))
-			if err != nil {
-				return nil, err
-			}
-			// Write the field data
-			err = pbuf.EncodeFixed32(uint64(f))
-			if err != nil {
-				return nil, err
-			}
-		case WireFixed64:
-			// Make field the appropriate type
-			f := field.(WireFixed64)
-			// Write tag header
-			var tag WireVarint
-			tag.FromTag(fnum, proto.WireFixed64)
-			err := pbuf.EncodeVarint(uint64(tag))
-			if err != nil {
-				return nil, err
-			}
-			// Write the field data
-			err = pbuf.EncodeFixed64(uint64(f))
-			if err != nil {
-				return nil, err
-			}
-		case []byte:
-			// Make field the appropriate type
-			f := field.([]byte)
-			// Write tag header
-			var tag WireVarint
-			tag.FromTag(fnum, proto.WireBytes)
-			err := pbuf.EncodeVarint(uint64(tag))
-			if err != nil {
-				return nil, err
+			var err error
+			switch wire {
+			case proto.WireVarint:
+				err = pbuf.EncodeVarint(uint64(m.varint[fnum][i]))
+			case proto.WireFixed32:
+				err = pbuf.EncodeFixed32(uint64(m.fixed32[fnum][i]))
+			case proto.WireFixed64:
+				err = pbuf.EncodeFixed64(uint64(m.fixed64[fnum][i]))
+			case proto.WireBytes:
+				err = pbuf.EncodeRawBytes(m.bytes[fnum][i])
 			}
-			// Write the field data
-			err = pbuf.EncodeRawBytes(f)
 			if err != nil {
 				return nil, err
 			}
@@ -0,0 +1,94 @@
+// Craig Hesling <craig@hesling.com>
+// Started October 19, 2026
+//
+// This file holds WireOptions, which apply the settings of a WireMessage,
+// like its UTF-8 policy, to the schema level decode and encode functions.
+
+package dproto
+
+import "fmt"
+
+// WireOptions configures the WireMessages used by the decode and encode
+// functions of a ProtoFieldMap or Registry. The zero value matches the
+// defaults of NewWireMessage.
+type WireOptions struct {
+	// Compat enables the compatibility mode, see SetCompatMode
+	Compat bool
+	// LegacyInt32 accepts int32 values written as 5 byte varints,
+	// see SetLegacyInt32
+	LegacyInt32 bool
+	// UTF8 and UTF8Warn select the UTF-8 policy, see SetUTF8Policy
+	UTF8     UTF8Policy
+	UTF8Warn func(error)
+}
+
+// mode returns the WireMessage settings selected by o
+func (o WireOptions) mode() wireMode {
+	return wireMode{
+		compat:      o.Compat,
+		legacyInt32: o.LegacyInt32,
+		utf8:        o.UTF8,
+		utf8Warn:    o.UTF8Warn,
+	}
+}
+
+// unmarshal unmarshals buf into a new WireMessage using the settings of o
+func (o WireOptions) unmarshal(buf []byte) (*WireMessage, error) {
+	m := NewWireMessage()
+	m.mode = o.mode()
+	if err := m.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DecodeBuffer is like fm.DecodeBuffer, using the settings of o
+func (o WireOptions) DecodeBuffer(fm *ProtoFieldMap, buf []byte) ([]FieldValue, error) {
+	m, err := o.unmarshal(buf)
+	if err != nil {
+		return nil, err
+	}
+	return fm.DecodeMessage(m)
+}
+
+// DecodeBufferResult is like fm.DecodeBufferResult, using the settings of o
+func (o WireOptions) DecodeBufferResult(fm *ProtoFieldMap, buf []byte, policy UnknownFieldPolicy) DecodeResult {
+	m, err := o.unmarshal(buf)
+	if err != nil {
+		return DecodeResult{Err: err}
+	}
+	return fm.DecodeMessageResult(m, policy)
+}
+
+// EncodeMessage is like fm.EncodeMessage, using the settings of o.
+// The returned message and its embedded messages keep the settings.
+func (o WireOptions) EncodeMessage(fm *ProtoFieldMap, values []FieldValue) (*WireMessage, error) {
+	return fm.encodeMessage(values, o.mode())
+}
+
+// EncodeBuffer is like fm.EncodeBuffer, using the settings of o
+func (o WireOptions) EncodeBuffer(fm *ProtoFieldMap, values []FieldValue) ([]byte, error) {
+	m, err := o.EncodeMessage(fm, values)
+	if err != nil {
+		return nil, err
+	}
+	return m.Marshal()
+}
+
+// Decode is like r.Decode, using the settings of o
+func (o WireOptions) Decode(r *Registry, typeName string, buf []byte) ([]FieldValue, error) {
+	fm, ok := r.GetMessage(typeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolvedType, typeName)
+	}
+	return o.DecodeBuffer(fm, buf)
+}
+
+// Encode is like r.Encode, using the settings of o
+func (o WireOptions) Encode(r *Registry, typeName string, values []FieldValue) ([]byte, error) {
+	fm, ok := r.GetMessage(typeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolvedType, typeName)
+	}
+	return o.EncodeBuffer(fm, values)
+}
@@ -49,7 +49,8 @@ func (v *WireVarint) FromTag(field FieldNum, wire WireType) {
 	*v = WireVarint((uint64(field) << 3) | (uint64(wire) & 7))
 }
 
-// AsInt32 returns the wiretype interpreted as a Protobuf int32
+// AsInt32 returns the wiretype interpreted as a Protobuf int32.
+// The upper 32 bits are dropped, see ValidInt32 to check them.
 func (v WireVarint) AsInt32() int32 {
 	return int32(v)
 }
@@ -93,9 +94,23 @@ func (v WireVarint) AsEnum() uint64 {
 	return uint64(v)
 }
 
-// FromInt32 sets the wiretype from a Protobuf int32
+// ValidInt32 indicates if v holds a Protobuf int32 as the spec requires,
+// which means negative values are sign extended to 64 bits
+func (v WireVarint) ValidInt32() bool {
+	return int64(v) == int64(int32(v))
+}
+
+// isLegacyInt32 indicates if v holds a negative int32 that was only
+// extended to 32 bits, as older versions of dproto wrote them
+func (v WireVarint) isLegacyInt32() bool {
+	return uint64(v)>>31 == 1
+}
+
+// FromInt32 sets the wiretype from a Protobuf int32.
+// Negative values are sign extended, so they take up 10 bytes on the wire,
+// like they do in every official Protobuf library.
 func (v *WireVarint) FromInt32(i int32) WireVarint {
-	*v = WireVarint(uint32(i))
+	*v = WireVarint(int64(i))
 	return *v
 }
 
@@ -121,7 +136,7 @@ func (v *WireVarint) FromUint64(i uint64) WireVarint {
 func (v *WireVarint) FromSint32(i int32) WireVarint {
 	//TODO: Confirm correctness
 	// Taken from protobuff web page on encoding
-	*v = WireVarint((i << 1) ^ (i >> 31))
+	*v = WireVarint(uint32((i << 1) ^ (i >> 31)))
 	return *v
 }
 
@@ -246,6 +261,7 @@ var protoType2WireType = map[descriptor.FieldDescriptorProto_Type]WireType{
 	descriptor.FieldDescriptorProto_TYPE_FIXED32: proto.WireFixed32,
 	descriptor.FieldDescriptorProto_TYPE_BOOL:    proto.WireVarint,
 	descriptor.FieldDescriptorProto_TYPE_STRING:  proto.WireBytes,
+	descriptor.FieldDescriptorProto_TYPE_BYTES:   proto.WireBytes,
 	// descriptor.FieldDescriptorProto_TYPE_GROUP: proto.WireStartGroup
 	descriptor.FieldDescriptorProto_TYPE_MESSAGE:  proto.WireBytes,
 	descriptor.FieldDescriptorProto_TYPE_ENUM:     proto.WireVarint,